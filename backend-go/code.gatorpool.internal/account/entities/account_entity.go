@@ -21,6 +21,17 @@ type AccountEntity struct {
 
 	TwoFAEnabled		*bool 					`json:"two_fa_enabled,omitempty" bson:"two_fa_enabled,omitempty"`
 	TwoFARequests     []*TwoFARequest      `json:"two_fa_requests" bson:"two_fa_requests"`
+
+	// email or totp, email if it was never set
+	TwoFAMethod			*string 				`json:"two_fa_method,omitempty" bson:"two_fa_method,omitempty"`
+
+	// The authenticator app in use, and one being set up that hasn't been confirmed with a code yet
+	TOTP				*TOTPSecret 			`json:"-" bson:"totp,omitempty"`
+	PendingTOTP			*TOTPSecret 			`json:"-" bson:"pending_totp,omitempty"`
+
+	// Single use codes for signing in without the usual second factor
+	RecoveryCodes		[]*RecoveryCode 		`json:"-" bson:"recovery_codes,omitempty"`
+
 	Gender  			*string 				`json:"gender" bson:"gender"`
 	Sessions			[]*Session 				`json:"sessions,omitempty" bson:"sessions,omitempty"`
 	LastLogin           *time.Time 				`json:"last_login,omitempty" bson:"last_login,omitempty"`
@@ -34,10 +45,49 @@ type AccountEntity struct {
 	Phone				*string 				`json:"phone,omitempty" bson:"phone,omitempty"`
 	RiderUUID			*string 				`json:"rider_uuid,omitempty" bson:"rider_uuid,omitempty"`
 	DriverUUID			*string 				`json:"driver_uuid,omitempty" bson:"driver_uuid,omitempty"`
+	Roles				[]string 				`json:"roles,omitempty" bson:"roles,omitempty"`
+	Lockout				*Lockout 				`json:"lockout,omitempty" bson:"lockout,omitempty"`
 	CreatedAt   		*time.Time 				`json:"created_at,omitempty" bson:"created_at,omitempty"`
 	UpdatedAt   		*time.Time 				`json:"updated_at,omitempty" bson:"updated_at,omitempty"`
 }
 
+// MARK: TOTPSecret struct
+type TOTPSecret struct {
+	// Encrypted with the symmetric key of KeyVersion
+	EncryptedSecret		*string 				`json:"-" bson:"encrypted_secret,omitempty"`
+	KeyVersion			*int64 					`json:"-" bson:"key_version,omitempty"`
+
+	// The time step of the last code accepted, so a code can't be used twice
+	LastUsedStep		*int64 					`json:"-" bson:"last_used_step,omitempty"`
+
+	CreatedAt			*time.Time 				`json:"created_at,omitempty" bson:"created_at,omitempty"`
+	ConfirmedAt			*time.Time 				`json:"confirmed_at,omitempty" bson:"confirmed_at,omitempty"`
+}
+
+// MARK: RecoveryCode struct
+type RecoveryCode struct {
+	// Hashed and peppered like a password, with the pepper of EncryptedVersion
+	Hash 				*string 				`json:"-" bson:"hash,omitempty"`
+	EncryptedVersion	*int64 					`json:"-" bson:"encrypted_version,omitempty"`
+
+	UsedAt				*time.Time 				`json:"used_at,omitempty" bson:"used_at,omitempty"`
+	CreatedAt			*time.Time 				`json:"created_at,omitempty" bson:"created_at,omitempty"`
+}
+
+// MARK: Lockout struct
+// Wrong passwords since the last successful sign in, and how long the account has to wait because of them
+type Lockout struct {
+	FailedAttempts		*int64 					`json:"failed_attempts,omitempty" bson:"failed_attempts,omitempty"`
+	LastFailedAt		*time.Time 				`json:"last_failed_at,omitempty" bson:"last_failed_at,omitempty"`
+
+	// No password is checked before then
+	NextAttemptAt		*time.Time 				`json:"next_attempt_at,omitempty" bson:"next_attempt_at,omitempty"`
+	LockedUntil			*time.Time 				`json:"locked_until,omitempty" bson:"locked_until,omitempty"`
+
+	// Hash of the token in the unlock link emailed when the account was locked
+	UnlockTokenHash		*string 				`json:"-" bson:"unlock_token_hash,omitempty"`
+}
+
 type ProfilePicture struct {
 	ImageGCSPath     *string `json:"image_gcs_path" bson:"image_gcs_path"`
 	ImageURL         *string `json:"image_url" bson:"image_url"`
@@ -11,11 +11,13 @@ import (
 	"time"
 
 	accountEntities "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/account/roles"
 	"code.gatorpool.internal/account/validator"
 	configEntities "code.gatorpool.internal/config/entities"
 	datastores "code.gatorpool.internal/datastores/mongo"
 	"code.gatorpool.internal/guardian/encryption"
 	passwords "code.gatorpool.internal/guardian/password"
+	"code.gatorpool.internal/notify"
 	riderEntities "code.gatorpool.internal/rider/entities"
 	"code.gatorpool.internal/util"
 	"code.gatorpool.internal/util/ptr"
@@ -110,6 +112,7 @@ func SignUpV1(req *http.Request, res http.ResponseWriter, ctx context.Context) *
 		Phone:          nil,
 		RiderUUID:      nil,
 		DriverUUID:     nil,
+		Roles:          []string{roles.Rider},
 		TwoFAEnabled:   ptr.Bool(false),
 		ProfilePicture: ptr.Bool(false),
 		Password: &accountEntities.Password{
@@ -223,10 +226,9 @@ func SignUpV1(req *http.Request, res http.ResponseWriter, ctx context.Context) *
 
 	fmt.Println("Debug Link: " + link)
 
-	emailErr := SendEmail(EmailRequestBody{
-		Email:    email,
-		Subject:  "GatorPool - Finish signing up",
-		Template: "verify-create-account",
+	emailErr := notify.Enqueue(ctx, notify.Event{
+		Type:  notify.EventVerifyAccount,
+		Email: email,
 		Data: map[string]string{
 			"EMAIL": email,
 			"URL":   link,
@@ -507,10 +509,9 @@ func ResendVerificationEmail(req *http.Request, res http.ResponseWriter, ctx con
 			link = "https://gatorpool.netlify.app/verify?id=" + stringObjectID + "&signature=" + emailVerificationData.EncryptedCode
 		}
 
-		emailErr := SendEmail(EmailRequestBody{
-			Email:    email,
-			Subject:  "GatorPool - Finish signing up",
-			Template: "verify-create-account",
+		emailErr := notify.Enqueue(ctx, notify.Event{
+			Type:  notify.EventVerifyAccount,
+			Email: email,
 			Data: map[string]string{
 				"EMAIL": email,
 				"URL":   link,
@@ -16,8 +16,11 @@ import (
 	"code.gatorpool.internal/datastores/gcs"
 	datastores "code.gatorpool.internal/datastores/mongo"
 	driverEntities "code.gatorpool.internal/driver/entities"
+	"code.gatorpool.internal/ledger"
+	"code.gatorpool.internal/notify"
 	riderEntities "code.gatorpool.internal/rider/entities"
 	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/lifecycle"
 	"code.gatorpool.internal/util"
 	"code.gatorpool.internal/util/ptr"
 	"github.com/pborman/uuid"
@@ -151,6 +154,12 @@ func LoadIn(req *http.Request, res http.ResponseWriter, ctx context.Context) *ht
 	dashboardStats := HydrateCards(account, *rider)
 	defaultReturn["dashboard_stats"] = dashboardStats
 
+	unreadNotifications, err := notify.UnreadCount(ctx, *account.UserUUID)
+	if err != nil {
+		fmt.Println("Error counting unread notifications: ", err)
+	}
+	defaultReturn["unread_notifications"] = unreadNotifications
+
 	return util.JSONResponse(res, http.StatusOK, defaultReturn)
 }
 
@@ -158,6 +167,7 @@ type DashboardStats struct {
 	UpcomingTrips int `json:"upcoming_trips"`
 	PastTrips int `json:"past_trips"`
 	AccountType string `json:"account_type"`
+	Balance ledger.Balance `json:"balance"`
 }
 
 func HydrateCards(account accountEntities.AccountEntity, rider riderEntities.RiderEntity) DashboardStats {
@@ -170,12 +180,15 @@ func HydrateCards(account accountEntities.AccountEntity, rider riderEntities.Rid
 
 	// PAST TRIPS
 
+	participantQuery := bson.A{
+		bson.D{{Key: "riders.user_uuid", Value: *rider.RiderUUID}},
+		bson.D{{Key: "assigned_driver.user_uuid", Value: *rider.RiderUUID}},
+	}
+
+	// Only trips that were actually completed count as past trips
 	riderQuery := bson.D{
-		{Key: "$or", Value: bson.A{
-			bson.D{{Key: "riders.user_uuid", Value: *rider.RiderUUID}},
-			bson.D{{Key: "assigned_driver.user_uuid", Value: *rider.RiderUUID}},
-		}},
-		{Key: "datetime", Value: bson.D{{Key: "$lte", Value: time.Now()}}},
+		{Key: "$or", Value: participantQuery},
+		{Key: "status", Value: lifecycle.StatusCompleted},
 	}
 
 	cursor, err := tripsCollection.Find(context.Background(), riderQuery)
@@ -190,12 +203,18 @@ func HydrateCards(account accountEntities.AccountEntity, rider riderEntities.Rid
 
 	// UPCOMING TRIPS
 
+	// Trips in progress, or pending trips that haven't started yet
 	riderQuery = bson.D{
-		{Key: "$or", Value: bson.A{
-			bson.D{{Key: "riders.user_uuid", Value: *rider.RiderUUID}},
-			bson.D{{Key: "assigned_driver.user_uuid", Value: *rider.RiderUUID}},
+		{Key: "$and", Value: bson.A{
+			bson.D{{Key: "$or", Value: participantQuery}},
+			bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "status", Value: lifecycle.StatusActive}},
+				bson.D{
+					{Key: "status", Value: lifecycle.StatusPending},
+					{Key: "datetime", Value: bson.D{{Key: "$gte", Value: time.Now()}}},
+				},
+			}}},
 		}},
-		{Key: "datetime", Value: bson.D{{Key: "$gte", Value: time.Now()}}},
 	}
 
 	cursor, err = tripsCollection.Find(context.Background(), riderQuery)
@@ -216,11 +235,18 @@ func HydrateCards(account accountEntities.AccountEntity, rider riderEntities.Rid
 		fmt.Println("Error fetching driver: ", err)
 	}
 
+	// What they owe and are owed for trips that haven't been settled
+	balance, balanceErr := ledger.Balances(context.Background(), *rider.RiderUUID)
+	if balanceErr != nil {
+		fmt.Println("Error fetching balances: ", balanceErr)
+	}
+
 	if err != nil && err == mongo.ErrNoDocuments {
 		return DashboardStats{
 			UpcomingTrips: len(upcomingTrips),
 			PastTrips: len(pastTrips),
 			AccountType: "Rider",
+			Balance: balance,
 		}
 	}
 
@@ -228,6 +254,7 @@ func HydrateCards(account accountEntities.AccountEntity, rider riderEntities.Rid
 		UpcomingTrips: len(upcomingTrips),
 		PastTrips: len(pastTrips),
 		AccountType: "Rider and Driver",
+		Balance: balance,
 	}
 }
 
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"code.gatorpool.internal/account/lockout"
+	"code.gatorpool.internal/util"
+	"github.com/go-chi/chi"
+)
+
+// UnlockAccount lifts a lock from too many wrong passwords, using the link emailed when it was locked
+func UnlockAccount(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	id := req.URL.Query().Get("id")
+	token := req.URL.Query().Get("token")
+
+	if id == "" || token == "" {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "id and token are required",
+		})
+	}
+
+	err := lockout.Unlock(ctx, id, token)
+	if err == lockout.ErrInvalidUnlockToken {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	} else if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// ClearAccountLockout forgets the account's wrong passwords and lifts any lock on it
+func ClearAccountLockout(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	userUUID := chi.URLParam(req, "user_uuid")
+
+	cleared, err := lockout.Clear(ctx, userUUID)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"user_uuid": userUUID,
+		"cleared":   cleared,
+		"success":   true,
+	})
+}
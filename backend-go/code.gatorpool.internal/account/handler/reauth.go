@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/account/lockout"
+	"code.gatorpool.internal/account/twofactor"
+	passwords "code.gatorpool.internal/guardian/password"
+	"code.gatorpool.internal/util"
+)
+
+// ReauthBody proves it's really the account owner, not just someone holding their session
+type ReauthBody struct {
+	Password *string `json:"password"`
+	Code     string  `json:"code"`
+}
+
+// checkLockout turns the request away if the account is locked or has to wait after failed sign ins
+func checkLockout(res http.ResponseWriter, account *accountEntities.AccountEntity) *http.Response {
+	wait, err := lockout.Check(account.Lockout, time.Now())
+	if err == nil {
+		return nil
+	}
+
+	status := http.StatusTooManyRequests
+	if err == lockout.ErrLocked {
+		status = http.StatusLocked
+	}
+
+	seconds := int(math.Ceil(wait.Seconds()))
+	res.Header().Set("Retry-After", strconv.Itoa(seconds))
+	return util.JSONResponse(res, status, map[string]interface{}{
+		"error":       err.Error(),
+		"retry_after": seconds,
+	})
+}
+
+// reauthenticate checks a code from the authenticator app, or the password, before something that
+// could take over the account. Wrong ones count towards the lockout like they do when signing in. It
+// returns the response to send if the check failed.
+func reauthenticate(res http.ResponseWriter, ctx context.Context, account *accountEntities.AccountEntity, body ReauthBody) *http.Response {
+	if locked := checkLockout(res, account); locked != nil {
+		return locked
+	}
+
+	switch {
+	case body.Code != "" && twofactor.Method(account) == twofactor.MethodTOTP:
+		err := twofactor.Verify(ctx, account, body.Code, time.Now())
+		if err == twofactor.ErrInvalidCode {
+			lockout.Record(ctx, account)
+			return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+				"error": err.Error(),
+			})
+		} else if err != nil {
+			return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+
+	case body.Password != nil && *body.Password != "" && account.Password != nil:
+		verified, err := passwords.VerifyPassword(body.Password, account.Password.Hash, account.Password.EncryptedVersion)
+		if err != nil {
+			return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		if !verified {
+			lockout.Record(ctx, account)
+			return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+				"error": "invalid credentials",
+			})
+		}
+
+	default:
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "password or authenticator code is required",
+		})
+	}
+
+	return nil
+}
@@ -3,7 +3,6 @@ package handler
 import (
 	"context"
 	"net/http"
-	"net/smtp"
 	"strconv"
 	"strings"
 	"time"
@@ -11,7 +10,7 @@ import (
 	accountEntities "code.gatorpool.internal/account/entities"
 	datastores "code.gatorpool.internal/datastores/mongo"
 	passwordEntity "code.gatorpool.internal/guardian/password"
-	"code.gatorpool.internal/guardian/secrets"
+	"code.gatorpool.internal/notify"
 	"code.gatorpool.internal/util"
 	"code.gatorpool.internal/util/ptr"
 	"code.gatorpool.internal/util/requesthydrator"
@@ -94,23 +93,12 @@ func RequestPasswordReset(req *http.Request, res http.ResponseWriter, ctx contex
 	// Make code a string
 	strCode := strconv.Itoa(int(*code))
 
-	message := "Subject: Password Reset\n\nYou have requested to reset your password. Your code is " + strCode + ". This code will expire in 15 minutes."
-
-	auth := smtp.PlainAuth(
-		"",
-		"noreply@gatorpool.app",
-		secrets.EmailSecretValue,
-		"smtp.gmail.com",
-	)
-
-	err = smtp.SendMail(
-		"smtp.gmail.com:587",
-		auth,
-		"noreply@gatorpool.app",
-		[]string{email},
-		[]byte(message),
-	)
-
+	err = notify.Enqueue(ctx, notify.Event{
+		Type:     notify.EventPasswordReset,
+		UserUUID: *account.UserUUID,
+		Email:    email,
+		Data:     map[string]string{"CODE": strCode},
+	})
 	if err != nil {
 		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{"error": err.Error()})
 	}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/account/roles"
+	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/util"
+	"github.com/go-chi/chi"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GrantAccountRole makes the account a moderator or admin
+func GrantAccountRole(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+	return changeAccountRole(req, res, ctx, true)
+}
+
+// RevokeAccountRole takes a moderator or admin role away from the account
+func RevokeAccountRole(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+	return changeAccountRole(req, res, ctx, false)
+}
+
+func changeAccountRole(req *http.Request, res http.ResponseWriter, ctx context.Context, grant bool) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	userUUID := chi.URLParam(req, "user_uuid")
+	role := chi.URLParam(req, "role")
+
+	if err := roles.Assignable(role); err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	// Admins can't lock themselves out, another admin has to do it
+	if !grant && role == roles.Admin && userUUID == *account.UserUUID {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "you can't revoke your own admin role",
+		})
+	}
+
+	// Accounts from before roles were stored get rider added along with the change
+	update := bson.M{"$addToSet": bson.M{"roles": bson.M{"$each": []string{roles.Rider, role}}}}
+	if !grant {
+		update = bson.M{"$pull": bson.M{"roles": role}}
+	}
+
+	var updated *accountEntities.AccountEntity
+	err := datastores.GetMongoDatabase(ctx).Collection(datastores.Accounts).FindOneAndUpdate(ctx, bson.M{"user_uuid": userUUID}, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return util.JSONResponse(res, http.StatusNotFound, map[string]interface{}{
+				"error": "account not found",
+			})
+		}
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"user_uuid": userUUID,
+		"roles":     roles.Of(updated),
+		"success":   true,
+	})
+}
@@ -2,10 +2,13 @@ package handler
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"fmt"
+	"time"
 
 	accountEntities "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/account/twofactor"
 	datastores "code.gatorpool.internal/datastores/mongo"
 	"code.gatorpool.internal/util"
 	"code.gatorpool.internal/util/ptr"
@@ -36,8 +39,71 @@ func ToggleTwoFA(req *http.Request, res http.ResponseWriter, ctx context.Context
 		})
 	}
 
+	// Turning 2FA on hands out recovery codes, and turning it off throws them away
+	if !*account.TwoFAEnabled {
+		if err := twofactor.ClearRecoveryCodes(ctx, &account); err != nil {
+			fmt.Println("Error clearing recovery codes: ", err)
+		}
+
+		return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+			"success": true,
+			"two_fa_enabled": *account.TwoFAEnabled,
+		})
+	}
+
+	codes, err := twofactor.NewRecoveryCodes(ctx, &account, time.Now())
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
 	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
 		"success": true,
 		"two_fa_enabled": *account.TwoFAEnabled,
+		"recovery_codes": codes,
+	})
+}
+
+// RegenerateRecoveryCodes replaces the account's recovery codes, used or not, with a new set
+func RegenerateRecoveryCodes(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	if account.TwoFAEnabled == nil || !*account.TwoFAEnabled {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "two-factor authentication is not enabled",
+		})
+	}
+
+	// A session alone isn't enough, or a stolen one could mint a way around 2FA for good
+	var body ReauthBody
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid body",
+		})
+	}
+
+	if failed := reauthenticate(res, ctx, &account, body); failed != nil {
+		return failed
+	}
+
+	codes, err := twofactor.NewRecoveryCodes(ctx, &account, time.Now())
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"recovery_codes": codes,
 	})
 }
\ No newline at end of file
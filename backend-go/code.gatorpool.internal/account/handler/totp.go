@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/account/lockout"
+	"code.gatorpool.internal/account/twofactor"
+	"code.gatorpool.internal/util"
+)
+
+type TOTPCodeBody struct {
+	Code string `json:"code"`
+}
+
+// ConfirmTOTPBody is the code from the app being set up, and when 2FA is already on, the password
+// or a code from the app it replaces
+type ConfirmTOTPBody struct {
+	Code        string  `json:"code"`
+	Password    *string `json:"password"`
+	CurrentCode string  `json:"current_code"`
+}
+
+// reauthenticateTwoFA makes sure it's the account owner changing their second factor, when they
+// already have one that a stolen session could otherwise replace
+func reauthenticateTwoFA(res http.ResponseWriter, ctx context.Context, account *accountEntities.AccountEntity, body ReauthBody) *http.Response {
+	if account.TwoFAEnabled == nil || !*account.TwoFAEnabled {
+		return nil
+	}
+	return reauthenticate(res, ctx, account, body)
+}
+
+// EnrollTOTP starts setting up an authenticator app and returns what the app needs to scan
+func EnrollTOTP(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	// The body is only needed when 2FA is already on
+	var body ReauthBody
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil && err != io.EOF {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid body",
+		})
+	}
+
+	if failed := reauthenticateTwoFA(res, ctx, &account, body); failed != nil {
+		return failed
+	}
+
+	secret, uri, err := twofactor.Enroll(ctx, &account, time.Now())
+	if err != nil {
+		fmt.Println("Error enrolling authenticator app: ", err)
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"secret":           secret,
+		"provisioning_uri": uri,
+		"success":          true,
+	})
+}
+
+// ConfirmTOTP finishes setting up an authenticator app with a code from it
+func ConfirmTOTP(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	var body ConfirmTOTPBody
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.Code == "" {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "code is required",
+		})
+	}
+
+	if failed := reauthenticateTwoFA(res, ctx, &account, ReauthBody{Password: body.Password, Code: body.CurrentCode}); failed != nil {
+		return failed
+	}
+
+	if locked := checkLockout(res, &account); locked != nil {
+		return locked
+	}
+
+	err := twofactor.Confirm(ctx, &account, body.Code, time.Now())
+	if err == twofactor.ErrInvalidCode {
+		lockout.Record(ctx, &account)
+	}
+	if err == twofactor.ErrNoPendingTOTP || err == twofactor.ErrInvalidCode {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	} else if err != nil {
+		fmt.Println("Error confirming authenticator app: ", err)
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	response := map[string]interface{}{
+		"two_fa_method":  twofactor.MethodTOTP,
+		"two_fa_enabled": true,
+		"success":        true,
+	}
+
+	// Confirming the app can be what turns 2FA on, which hands out recovery codes
+	if twofactor.RemainingRecoveryCodes(&account) == 0 {
+		codes, err := twofactor.NewRecoveryCodes(ctx, &account, time.Now())
+		if err != nil {
+			fmt.Println("Error generating recovery codes: ", err)
+		} else {
+			response["recovery_codes"] = codes
+		}
+	}
+
+	return util.JSONResponse(res, http.StatusOK, response)
+}
+
+// DisableTOTP removes the authenticator app, with a code from it, and goes back to emailed codes
+func DisableTOTP(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	var body TOTPCodeBody
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil || body.Code == "" {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "code is required",
+		})
+	}
+
+	if locked := checkLockout(res, &account); locked != nil {
+		return locked
+	}
+
+	err := twofactor.Disable(ctx, &account, body.Code, time.Now())
+	if err == twofactor.ErrInvalidCode {
+		lockout.Record(ctx, &account)
+	}
+	if err == twofactor.ErrTOTPNotEnabled || err == twofactor.ErrInvalidCode {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	} else if err != nil {
+		fmt.Println("Error disabling authenticator app: ", err)
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"two_fa_method": twofactor.MethodEmail,
+		"success":       true,
+	})
+}
@@ -0,0 +1,59 @@
+package housekeeping
+
+import (
+	"context"
+	"time"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/guardian/session"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Sign-up verifications don't expire on their own, so ones nobody finished are dropped after this
+const VerificationLifetime = time.Hour * 24 * 7
+
+// PurgeExpired deletes sign-in codes, password resets and sign-up verifications that can no longer
+// be used, and returns how many it deleted
+func PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	db := datastores.GetMongoDatabase(ctx)
+
+	purges := []struct {
+		Collection string
+		Filter     bson.M
+	}{
+		{datastores.AccountsMFA, bson.M{"expires_at": bson.M{"$lt": now}}},
+		{datastores.AccountsPasswordReset, bson.M{"expires_at": bson.M{"$lt": now}}},
+		{datastores.AccountsCreationVerification, bson.M{"created_at": bson.M{"$lt": now.Add(-VerificationLifetime)}}},
+	}
+
+	var deleted int64
+	for _, purge := range purges {
+		result, err := db.Collection(purge.Collection).DeleteMany(ctx, purge.Filter)
+		if err != nil {
+			return deleted, err
+		}
+		deleted += result.DeletedCount
+	}
+
+	return deleted, nil
+}
+
+// PurgeSessions removes sessions whose refresh token has expired, along with signed out sessions
+// that haven't been used since, and returns how many accounts it cleaned up
+func PurgeSessions(ctx context.Context, now time.Time) (int64, error) {
+	cutoff := now.Add(-session.RefreshTokenLifetime)
+
+	expired := bson.M{"$or": []bson.M{
+		{"refresh_issued_at": bson.M{"$lt": cutoff}},
+		{"refresh_issued_at": nil, "issued_at": bson.M{"$lt": cutoff}},
+	}}
+
+	result, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Accounts).UpdateMany(ctx,
+		bson.M{"sessions": bson.M{"$elemMatch": expired}},
+		bson.M{"$pull": bson.M{"sessions": expired}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,56 @@
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/notify"
+)
+
+// Record counts a failed sign in against the account, and emails an unlock link if it locked the
+// account. Failures are logged rather than returned, since the caller is already turning the user away.
+func Record(ctx context.Context, account *accountEntities.AccountEntity) {
+	_, token, err := RecordFailure(ctx, account, time.Now())
+	if err != nil {
+		fmt.Println("Error recording failed sign in: ", err)
+		return
+	}
+
+	if token == "" {
+		return
+	}
+
+	link := "https://gatorpool.app/unlock?id=" + *account.UserUUID + "&token=" + token
+	if os.Getenv("ENV") == "development" {
+		link = "http://localhost:3000/unlock?id=" + *account.UserUUID + "&token=" + token
+	}
+
+	err = notify.Enqueue(ctx, notify.Event{
+		Type:     notify.EventAccountLocked,
+		UserUUID: *account.UserUUID,
+		Data: map[string]string{
+			"URL":     link,
+			"MINUTES": strconv.Itoa(int(LockFor.Minutes())),
+		},
+	})
+	if err != nil {
+		fmt.Println("Error queueing "+notify.EventAccountLocked+" notification: ", err)
+	}
+}
+
+// Forget clears the account's failed sign ins after a successful one
+func Forget(ctx context.Context, account *accountEntities.AccountEntity) {
+	if account.Lockout == nil {
+		return
+	}
+
+	if _, err := Clear(ctx, *account.UserUUID); err != nil {
+		fmt.Println("Error clearing lockout: ", err)
+		return
+	}
+	account.Lockout = nil
+}
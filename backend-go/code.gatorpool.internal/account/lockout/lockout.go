@@ -0,0 +1,218 @@
+package lockout
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/util/ptr"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// Wrong passwords in a row before each attempt has to wait
+	FreeAttempts = 3
+
+	// The first wait, doubling with every wrong password after that
+	BaseDelay = time.Second * 2
+	MaxDelay  = time.Minute * 5
+
+	// Wrong passwords in a row that lock the account, and for how long
+	MaxAttempts = 10
+	LockFor     = time.Minute * 30
+
+	// Wrong passwords are forgotten after this long without another
+	ForgetAfter = time.Hour * 24
+
+	// How many times RecordFailure tries again when another attempt was recorded first
+	maxAttempts = 3
+)
+
+var (
+	ErrLocked             = errors.New("account is locked after too many wrong passwords")
+	ErrTooSoon            = errors.New("too many wrong passwords, wait before trying again")
+	ErrInvalidUnlockToken = errors.New("unlock link is invalid or has already been used")
+)
+
+// Check says whether a password can be tried now. If not, it returns ErrLocked or ErrTooSoon and how
+// long until it can.
+func Check(lockout *accountEntities.Lockout, now time.Time) (time.Duration, error) {
+	if lockout == nil {
+		return 0, nil
+	}
+	if lockout.LockedUntil != nil && now.Before(*lockout.LockedUntil) {
+		return lockout.LockedUntil.Sub(now), ErrLocked
+	}
+	if lockout.NextAttemptAt != nil && now.Before(*lockout.NextAttemptAt) {
+		return lockout.NextAttemptAt.Sub(now), ErrTooSoon
+	}
+	return 0, nil
+}
+
+// Delay is how long to wait before trying again after this many wrong passwords in a row
+func Delay(failures int64) time.Duration {
+	if failures <= FreeAttempts {
+		return 0
+	}
+
+	delay := BaseDelay
+	for i := int64(FreeAttempts + 1); i < failures && delay < MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > MaxDelay {
+		delay = MaxDelay
+	}
+	return delay
+}
+
+// Fail records a wrong password. It returns the new lockout, and whether this wrong password
+// locked the account.
+func Fail(lockout *accountEntities.Lockout, now time.Time) (*accountEntities.Lockout, bool) {
+	var failures int64
+	if lockout != nil && lockout.FailedAttempts != nil && !stale(lockout, now) {
+		failures = *lockout.FailedAttempts
+	}
+	failures++
+
+	next := &accountEntities.Lockout{
+		FailedAttempts: ptr.Int64(failures),
+		LastFailedAt:   ptr.Time(now),
+	}
+
+	if failures >= MaxAttempts {
+		next.LockedUntil = ptr.Time(now.Add(LockFor))
+		return next, true
+	}
+
+	if delay := Delay(failures); delay > 0 {
+		next.NextAttemptAt = ptr.Time(now.Add(delay))
+	}
+	return next, false
+}
+
+// stale reports whether the wrong passwords so far no longer count, because the lock they caused
+// is over or it's been a while since the last one
+func stale(lockout *accountEntities.Lockout, now time.Time) bool {
+	if lockout.LockedUntil != nil && !now.Before(*lockout.LockedUntil) {
+		return true
+	}
+	return lockout.LastFailedAt != nil && now.Sub(*lockout.LastFailedAt) >= ForgetAfter
+}
+
+// NewUnlockToken makes the token for an unlock link, and the hash of it that's stored on the account
+func NewUnlockToken() (string, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+
+	token := hex.EncodeToString(raw)
+	return token, hashToken(token), nil
+}
+
+// ValidUnlockToken reports whether the token is the one emailed for the lockout
+func ValidUnlockToken(lockout *accountEntities.Lockout, token string) bool {
+	if lockout == nil || lockout.UnlockTokenHash == nil || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(*lockout.UnlockTokenHash)) == 1
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// RecordFailure saves a wrong password against the account. When it locks the account, it also
+// returns the token for the unlock link.
+func RecordFailure(ctx context.Context, account *accountEntities.AccountEntity, now time.Time) (*accountEntities.Lockout, string, error) {
+	accountsCollection := datastores.GetMongoDatabase(ctx).Collection(datastores.Accounts)
+	current := account.Lockout
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		next, locked := Fail(current, now)
+
+		token := ""
+		if locked {
+			var hash string
+			var err error
+			if token, hash, err = NewUnlockToken(); err != nil {
+				return nil, "", err
+			}
+			next.UnlockTokenHash = ptr.String(hash)
+		}
+
+		// Only if no other wrong password was recorded since this one was read
+		filter := bson.M{"user_uuid": account.UserUUID, "lockout": nil}
+		if current != nil {
+			filter = bson.M{"user_uuid": account.UserUUID, "lockout.failed_attempts": current.FailedAttempts, "lockout.last_failed_at": current.LastFailedAt}
+		}
+
+		result, err := accountsCollection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"lockout": next}})
+		if err != nil {
+			return nil, "", err
+		}
+		if result.MatchedCount == 1 {
+			return next, token, nil
+		}
+
+		var reloaded *accountEntities.AccountEntity
+		if err := accountsCollection.FindOne(ctx, bson.M{"user_uuid": account.UserUUID}).Decode(&reloaded); err != nil {
+			return nil, "", err
+		}
+		current = reloaded.Lockout
+	}
+
+	// Every try lost to another wrong password, which is plenty recorded already
+	return current, "", nil
+}
+
+// Clear forgets the account's wrong passwords and lifts any lock. It returns whether there was
+// anything to clear.
+func Clear(ctx context.Context, userUUID string) (bool, error) {
+	result, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Accounts).UpdateOne(ctx,
+		bson.M{"user_uuid": userUUID, "lockout": bson.M{"$exists": true}},
+		bson.M{"$unset": bson.M{"lockout": ""}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount == 1, nil
+}
+
+// Unlock lifts the lock on the account with the token from the unlock link
+func Unlock(ctx context.Context, userUUID string, token string) error {
+	accountsCollection := datastores.GetMongoDatabase(ctx).Collection(datastores.Accounts)
+
+	var account *accountEntities.AccountEntity
+	err := accountsCollection.FindOne(ctx, bson.M{"user_uuid": userUUID}).Decode(&account)
+	if err == mongo.ErrNoDocuments {
+		return ErrInvalidUnlockToken
+	} else if err != nil {
+		return err
+	}
+
+	if !ValidUnlockToken(account.Lockout, token) {
+		return ErrInvalidUnlockToken
+	}
+
+	// The token goes with the lockout, so the link only works once
+	result, err := accountsCollection.UpdateOne(ctx,
+		bson.M{"user_uuid": userUUID, "lockout.unlock_token_hash": account.Lockout.UnlockTokenHash},
+		bson.M{"$unset": bson.M{"lockout": ""}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrInvalidUnlockToken
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package lockout
+
+import (
+	"testing"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelay(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Failures int64
+		Expected time.Duration
+	}{
+		{"First wrong password", 1, 0},
+		{"Last free attempt", FreeAttempts, 0},
+		{"First wait", FreeAttempts + 1, BaseDelay},
+		{"Doubles", FreeAttempts + 2, BaseDelay * 2},
+		{"Keeps doubling", FreeAttempts + 4, BaseDelay * 8},
+		{"Capped", 100, MaxDelay},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, Delay(tt.Failures))
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		Name    string
+		Lockout *accountEntities.Lockout
+		Wait    time.Duration
+		Err     error
+	}{
+		{"No wrong passwords", nil, 0, nil},
+		{"Free attempts left", &accountEntities.Lockout{FailedAttempts: ptr.Int64(2)}, 0, nil},
+		{"Waiting", &accountEntities.Lockout{NextAttemptAt: ptr.Time(now.Add(time.Second * 4))}, time.Second * 4, ErrTooSoon},
+		{"Waited long enough", &accountEntities.Lockout{NextAttemptAt: ptr.Time(now.Add(-time.Second))}, 0, nil},
+		{"Locked", &accountEntities.Lockout{LockedUntil: ptr.Time(now.Add(time.Minute))}, time.Minute, ErrLocked},
+		{"Lock is over", &accountEntities.Lockout{LockedUntil: ptr.Time(now.Add(-time.Minute))}, 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			wait, err := Check(tt.Lockout, now)
+			assert.Equal(t, tt.Err, err)
+			assert.Equal(t, tt.Wait, wait)
+		})
+	}
+}
+
+func TestFail(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		Name     string
+		Lockout  *accountEntities.Lockout
+		Failures int64
+		Wait     *time.Time
+		Locked   bool
+	}{
+		{"First wrong password", nil, 1, nil, false},
+		{"Starts waiting", &accountEntities.Lockout{FailedAttempts: ptr.Int64(FreeAttempts), LastFailedAt: ptr.Time(now)}, FreeAttempts + 1, ptr.Time(now.Add(BaseDelay)), false},
+		{"Locks", &accountEntities.Lockout{FailedAttempts: ptr.Int64(MaxAttempts - 1), LastFailedAt: ptr.Time(now)}, MaxAttempts, nil, true},
+		{"Forgotten after a while", &accountEntities.Lockout{FailedAttempts: ptr.Int64(MaxAttempts - 1), LastFailedAt: ptr.Time(now.Add(-ForgetAfter))}, 1, nil, false},
+		{"Starts over after a lock", &accountEntities.Lockout{
+			FailedAttempts: ptr.Int64(MaxAttempts),
+			LastFailedAt:   ptr.Time(now.Add(-LockFor)),
+			LockedUntil:    ptr.Time(now.Add(-time.Second)),
+		}, 1, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			next, locked := Fail(tt.Lockout, now)
+			assert.Equal(t, tt.Locked, locked)
+			assert.Equal(t, tt.Failures, *next.FailedAttempts)
+			assert.Equal(t, tt.Wait, next.NextAttemptAt)
+			assert.Equal(t, now, *next.LastFailedAt)
+			if tt.Locked {
+				assert.Equal(t, now.Add(LockFor), *next.LockedUntil)
+			} else {
+				assert.Nil(t, next.LockedUntil)
+			}
+		})
+	}
+}
+
+func TestValidUnlockToken(t *testing.T) {
+	token, hash, err := NewUnlockToken()
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, hash)
+
+	lockout := &accountEntities.Lockout{UnlockTokenHash: ptr.String(hash)}
+
+	assert.True(t, ValidUnlockToken(lockout, token))
+	assert.False(t, ValidUnlockToken(lockout, token+"0"))
+	assert.False(t, ValidUnlockToken(lockout, ""))
+	assert.False(t, ValidUnlockToken(&accountEntities.Lockout{}, token))
+	assert.False(t, ValidUnlockToken(nil, token))
+}
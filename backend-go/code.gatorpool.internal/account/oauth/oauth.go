@@ -4,17 +4,19 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"math"
 	"net/http"
-	"net/smtp"
 	"os"
 	"strconv"
 	"time"
 
 	accountEntities "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/account/lockout"
+	"code.gatorpool.internal/account/twofactor"
 	datastores "code.gatorpool.internal/datastores/mongo"
 	passwordEntity "code.gatorpool.internal/guardian/password"
-	"code.gatorpool.internal/guardian/secrets"
 	"code.gatorpool.internal/guardian/session"
+	"code.gatorpool.internal/notify"
 	"code.gatorpool.internal/util"
 	"code.gatorpool.internal/util/ptr"
 	"github.com/charmbracelet/log"
@@ -29,6 +31,9 @@ type OAuthBody struct {
 	GrantType *string `json:"grant_type"`
 	Scope     *string `json:"scope"`
 	MFACode   *string `json:"mfa_code"`
+
+	// Instead of the MFA code, for when the usual second factor isn't available
+	RecoveryCode *string `json:"recovery_code"`
 }
 
 // https://gatorpool.com/oauth2/token
@@ -118,12 +123,25 @@ func OAuthToken(req *http.Request, res http.ResponseWriter, ctx context.Context)
 
 	if grantType == "password" {
 
+		// Wrong passwords slow down further attempts, and too many lock the account
+		if wait, err := lockout.Check(account.Lockout, time.Now()); err != nil {
+			status := http.StatusTooManyRequests
+			if err == lockout.ErrLocked {
+				status = http.StatusLocked
+			}
+
+			seconds := int(math.Ceil(wait.Seconds()))
+			res.Header().Set("Retry-After", strconv.Itoa(seconds))
+			return util.JSONResponse(res, status, map[string]interface{}{"error": err.Error(), "retry_after": seconds})
+		}
+
 		verified, err := passwordEntity.VerifyPassword(body.Password, account.Password.Hash, account.Password.EncryptedVersion)
 		if err != nil {
 			return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{"error": err.Error()})
 		}
 
 		if !verified {
+			lockout.Record(ctx, account)
 			return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{"error": "invalid credentials"})
 		}
 
@@ -132,14 +150,25 @@ func OAuthToken(req *http.Request, res http.ResponseWriter, ctx context.Context)
 			// Check 2FA settings
 			err = OAuthTwoFactorAuthentication(req, &body, account, ctx)
 			if err != nil {
+				// Wrong authenticator and recovery codes count like wrong passwords, so someone who has the
+				// password can't keep guessing them
+				if err == twofactor.ErrInvalidCode || err == twofactor.ErrInvalidRecoveryCode {
+					lockout.Record(ctx, account)
+				}
 				return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{"error": err.Error()})
 			}
 
+			// Signing in forgets the failures before it, only once the second factor is right too
+			lockout.Forget(ctx, account)
+
 			// Account has 2FA enabled
 			return IssueOAuthResponse(true, &body, req, res, ctx)
 
 		} else {
 
+			// Signing in forgets the wrong passwords before it
+			lockout.Forget(ctx, account)
+
 			if !*account.IsComplete {
 				return IssueOAuthResponse(false, &body, req, res, ctx)
 			}
@@ -185,7 +214,7 @@ func OAuthToken(req *http.Request, res http.ResponseWriter, ctx context.Context)
 		}
 
 		// Check if the refresh token is expired
-		if foundSession.RefreshIssuedAt.Add(time.Hour * 24 * 28).Before(time.Now()) {
+		if foundSession.RefreshIssuedAt.Add(session.RefreshTokenLifetime).Before(time.Now()) {
 			return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{"error": "refresh token expired"})
 		}
 
@@ -327,6 +356,23 @@ func IssueOAuthResponse(accountComplete bool, body *OAuthBody, req *http.Request
 
 func OAuthTwoFactorAuthentication(req *http.Request, body *OAuthBody, account *accountEntities.AccountEntity, ctx context.Context) error {
 
+	if body.RecoveryCode != nil && *body.RecoveryCode != "" {
+		if err := twofactor.UseRecoveryCode(ctx, account, *body.RecoveryCode, time.Now()); err != nil {
+			return err
+		}
+
+		alertRecoveryCodeUsed(ctx, account)
+		return nil
+	}
+
+	// Accounts with an authenticator app use codes from it instead of emailed ones
+	if twofactor.Method(account) == twofactor.MethodTOTP {
+		if body.MFACode == nil || *body.MFACode == "" {
+			return errors.New("totp_required")
+		}
+		return twofactor.Verify(ctx, account, *body.MFACode, time.Now())
+	}
+
 	accountsMFACollection := datastores.GetMongoDatabase(ctx).Collection(datastores.AccountsMFA)
 
 	mfaQuery := bson.D{{Key: "user_uuid", Value: *account.UserUUID}}
@@ -360,23 +406,12 @@ func OAuthTwoFactorAuthentication(req *http.Request, body *OAuthBody, account *a
 		// Make code a string
 		strCode := strconv.Itoa(int(*code))
 
-		message := "Subject: GatorPool MFA Code\n\nYou have requested to sign in. Your code is " + strCode + ". This code will expire in 5 minutes."
-
-		auth := smtp.PlainAuth(
-			"",
-			"noreply@gatorpool.app",
-			secrets.EmailSecretValue,
-			"smtp.gmail.com",
-		)
-
-		err = smtp.SendMail(
-			"smtp.gmail.com:587",
-			auth,
-			"noreply@gatorpool.app",
-			[]string{req.Header.Get("X-GatorPool-Username")},
-			[]byte(message),
-		)
-
+		err = notify.Enqueue(ctx, notify.Event{
+			Type:     notify.EventMFACode,
+			UserUUID: *account.UserUUID,
+			Email:    req.Header.Get("X-GatorPool-Username"),
+			Data:     map[string]string{"CODE": strCode},
+		})
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,25 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/account/twofactor"
+	"code.gatorpool.internal/notify"
+)
+
+// alertRecoveryCodeUsed emails the account owner that one of their recovery codes was used to sign in
+func alertRecoveryCodeUsed(ctx context.Context, account *accountEntities.AccountEntity) {
+	err := notify.Enqueue(ctx, notify.Event{
+		Type:     notify.EventRecoveryCodeUsed,
+		UserUUID: *account.UserUUID,
+		Data: map[string]string{
+			"REMAINING": strconv.Itoa(twofactor.RemainingRecoveryCodes(account)),
+		},
+	})
+	if err != nil {
+		fmt.Println("Error queueing "+notify.EventRecoveryCodeUsed+" notification: ", err)
+	}
+}
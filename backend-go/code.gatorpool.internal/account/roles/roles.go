@@ -0,0 +1,70 @@
+package roles
+
+import (
+	"errors"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+)
+
+const (
+	Rider     = "rider"
+	Driver    = "driver"
+	Moderator = "moderator"
+	Admin     = "admin"
+)
+
+var (
+	ErrUnknownRole   = errors.New("unknown role")
+	ErrNotAssignable = errors.New("only staff roles can be granted or revoked")
+)
+
+var all = []string{Rider, Driver, Moderator, Admin}
+
+// Of is the account's roles. Everyone with an account can ride, so rider is always included, even
+// for accounts from before roles were stored
+func Of(account *accountEntities.AccountEntity) []string {
+	roles := []string{Rider}
+	for _, role := range account.Roles {
+		if role != Rider && Valid(role) == nil {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// Has reports whether the account holds any of the roles. Admins can do anything anyone else can
+func Has(account *accountEntities.AccountEntity, want ...string) bool {
+	for _, role := range Of(account) {
+		if role == Admin {
+			return true
+		}
+		for _, wanted := range want {
+			if role == wanted {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Valid checks the role is one we know about
+func Valid(role string) error {
+	for _, known := range all {
+		if role == known {
+			return nil
+		}
+	}
+	return ErrUnknownRole
+}
+
+// Assignable checks the role can be handed out by an admin. Rider and driver come from signing up
+// and having a driver application approved
+func Assignable(role string) error {
+	if err := Valid(role); err != nil {
+		return err
+	}
+	if role != Moderator && role != Admin {
+		return ErrNotAssignable
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package roles
+
+import (
+	"testing"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOf(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Roles    []string
+		Expected []string
+	}{
+		{"Account from before roles", nil, []string{Rider}},
+		{"Rider", []string{Rider}, []string{Rider}},
+		{"Driver", []string{Driver}, []string{Rider, Driver}},
+		{"Unknown roles are dropped", []string{Rider, "superuser", Moderator}, []string{Rider, Moderator}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, Of(&accountEntities.AccountEntity{Roles: tt.Roles}))
+		})
+	}
+}
+
+func TestHas(t *testing.T) {
+	rider := &accountEntities.AccountEntity{}
+	moderator := &accountEntities.AccountEntity{Roles: []string{Rider, Moderator}}
+	admin := &accountEntities.AccountEntity{Roles: []string{Admin}}
+
+	assert.True(t, Has(rider, Rider))
+	assert.False(t, Has(rider, Moderator, Admin))
+	assert.True(t, Has(moderator, Moderator))
+	assert.False(t, Has(moderator, Admin))
+	assert.True(t, Has(admin, Moderator))
+	assert.True(t, Has(admin, Admin))
+}
+
+func TestAssignable(t *testing.T) {
+	assert.NoError(t, Assignable(Moderator))
+	assert.NoError(t, Assignable(Admin))
+	assert.Equal(t, ErrNotAssignable, Assignable(Driver))
+	assert.Equal(t, ErrNotAssignable, Assignable(Rider))
+	assert.Equal(t, ErrUnknownRole, Assignable("owner"))
+}
@@ -0,0 +1,157 @@
+package twofactor
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/guardian/password"
+	"code.gatorpool.internal/util/ptr"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// How many codes are handed out at a time
+	RecoveryCodeCount = 10
+
+	// Characters in a code, split into two groups with a dash
+	recoveryCodeLength = 10
+
+	// No 0, 1, I or O, which get mixed up when written down
+	recoveryCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+var ErrInvalidRecoveryCode = errors.New("invalid recovery code")
+
+// GenerateRecoveryCodes makes a new set of codes, formatted like ABCDE-FGHJK
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+
+	for i := 0; i < RecoveryCodeCount; i++ {
+		raw := make([]byte, recoveryCodeLength)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+
+		// 256 is a multiple of the alphabet's 32 characters, so every character is equally likely
+		code := make([]byte, recoveryCodeLength)
+		for j, b := range raw {
+			code[j] = recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)]
+		}
+
+		half := recoveryCodeLength / 2
+		codes = append(codes, string(code[:half])+"-"+string(code[half:]))
+	}
+
+	return codes, nil
+}
+
+// NormalizeRecoveryCode puts a code back in the form it was handed out in, however it was typed
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.NewReplacer(" ", "", "-", "").Replace(code)
+
+	if len(code) != recoveryCodeLength {
+		return code
+	}
+
+	half := recoveryCodeLength / 2
+	return code[:half] + "-" + code[half:]
+}
+
+// RemainingRecoveryCodes is how many of the account's codes haven't been used
+func RemainingRecoveryCodes(account *accountEntities.AccountEntity) int {
+	remaining := 0
+	for _, code := range account.RecoveryCodes {
+		if code != nil && code.UsedAt == nil {
+			remaining++
+		}
+	}
+	return remaining
+}
+
+// NewRecoveryCodes replaces the account's recovery codes with a new set, and returns them. Only their
+// hashes are kept, so this is the one time they can be shown.
+func NewRecoveryCodes(ctx context.Context, account *accountEntities.AccountEntity, now time.Time) ([]string, error) {
+	codes, err := GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	hashed := make([]*accountEntities.RecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		hash, version, err := password.HashSecret(ptr.String(code))
+		if err != nil {
+			return nil, err
+		}
+
+		hashed = append(hashed, &accountEntities.RecoveryCode{
+			Hash:             hash,
+			EncryptedVersion: version,
+			CreatedAt:        ptr.Time(now),
+		})
+	}
+
+	_, err = datastores.GetMongoDatabase(ctx).Collection(datastores.Accounts).UpdateOne(ctx, bson.M{"user_uuid": account.UserUUID}, bson.M{
+		"$set": bson.M{"recovery_codes": hashed, "updated_at": now},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	account.RecoveryCodes = hashed
+	return codes, nil
+}
+
+// ClearRecoveryCodes removes the account's recovery codes
+func ClearRecoveryCodes(ctx context.Context, account *accountEntities.AccountEntity) error {
+	_, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Accounts).UpdateOne(ctx, bson.M{"user_uuid": account.UserUUID}, bson.M{
+		"$unset": bson.M{"recovery_codes": ""},
+	})
+	if err != nil {
+		return err
+	}
+
+	account.RecoveryCodes = nil
+	return nil
+}
+
+// UseRecoveryCode checks a recovery code and marks it used, so it can't be used again
+func UseRecoveryCode(ctx context.Context, account *accountEntities.AccountEntity, code string, now time.Time) error {
+	code = NormalizeRecoveryCode(code)
+
+	for _, recovery := range account.RecoveryCodes {
+		if recovery == nil || recovery.UsedAt != nil {
+			continue
+		}
+
+		matches, err := password.VerifyPassword(ptr.String(code), recovery.Hash, recovery.EncryptedVersion)
+		if err != nil {
+			return err
+		}
+		if !matches {
+			continue
+		}
+
+		// Only if it's still unused, in case the same code is being used somewhere else at once
+		result, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Accounts).UpdateOne(ctx, bson.M{
+			"user_uuid":      account.UserUUID,
+			"recovery_codes": bson.M{"$elemMatch": bson.M{"hash": recovery.Hash, "used_at": nil}},
+		}, bson.M{"$set": bson.M{"recovery_codes.$.used_at": now}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return ErrInvalidRecoveryCode
+		}
+
+		recovery.UsedAt = ptr.Time(now)
+		return nil
+	}
+
+	return ErrInvalidRecoveryCode
+}
@@ -0,0 +1,188 @@
+package twofactor
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/guardian/encryption"
+	"code.gatorpool.internal/guardian/secrets"
+	"code.gatorpool.internal/guardian/totp"
+	"code.gatorpool.internal/util/ptr"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	MethodEmail = "email"
+	MethodTOTP  = "totp"
+
+	// Shown next to the account in authenticator apps
+	Issuer = "GatorPool"
+)
+
+var (
+	ErrNoPendingTOTP  = errors.New("start setting up an authenticator app first")
+	ErrTOTPNotEnabled = errors.New("no authenticator app is set up")
+	ErrInvalidCode    = errors.New("invalid authenticator code")
+)
+
+// Method is how the account gets its second factor. Accounts only use an authenticator app once
+// one has been confirmed.
+func Method(account *accountEntities.AccountEntity) string {
+	if account.TwoFAMethod != nil && *account.TwoFAMethod == MethodTOTP && account.TOTP != nil {
+		return MethodTOTP
+	}
+	return MethodEmail
+}
+
+// Enroll starts setting up an authenticator app. It returns the secret and the URI for the QR code.
+// Nothing changes for the account until the app is confirmed with a code from it.
+func Enroll(ctx context.Context, account *accountEntities.AccountEntity, now time.Time) (string, string, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+
+	pending, err := seal(secret, now)
+	if err != nil {
+		return "", "", err
+	}
+
+	_, err = datastores.GetMongoDatabase(ctx).Collection(datastores.Accounts).UpdateOne(ctx, bson.M{"user_uuid": account.UserUUID}, bson.M{
+		"$set": bson.M{"pending_totp": pending, "updated_at": now},
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return secret, totp.ProvisioningURI(Issuer, *account.Email, secret), nil
+}
+
+// Confirm checks a code from the app being set up, then switches the account over to it
+func Confirm(ctx context.Context, account *accountEntities.AccountEntity, code string, now time.Time) error {
+	if account.PendingTOTP == nil {
+		return ErrNoPendingTOTP
+	}
+
+	secret, err := open(account.PendingTOTP)
+	if err != nil {
+		return err
+	}
+
+	step, ok := totp.Validate(secret, code, now)
+	if !ok {
+		return ErrInvalidCode
+	}
+
+	active := account.PendingTOTP
+	active.LastUsedStep = ptr.Int64(step)
+	active.ConfirmedAt = ptr.Time(now)
+
+	// Only the secret that was checked, in case the user started over on another device meanwhile
+	result, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Accounts).UpdateOne(ctx, bson.M{
+		"user_uuid":                     account.UserUUID,
+		"pending_totp.encrypted_secret": account.PendingTOTP.EncryptedSecret,
+	}, bson.M{
+		"$set": bson.M{
+			"totp":           active,
+			"two_fa_method":  MethodTOTP,
+			"two_fa_enabled": true,
+			"updated_at":     now,
+		},
+		"$unset": bson.M{"pending_totp": ""},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrNoPendingTOTP
+	}
+
+	account.TOTP = active
+	account.PendingTOTP = nil
+	account.TwoFAMethod = ptr.String(MethodTOTP)
+	account.TwoFAEnabled = ptr.Bool(true)
+	return nil
+}
+
+// Verify checks a code from the account's authenticator app. Each code only works once.
+func Verify(ctx context.Context, account *accountEntities.AccountEntity, code string, now time.Time) error {
+	if account.TOTP == nil {
+		return ErrTOTPNotEnabled
+	}
+
+	secret, err := open(account.TOTP)
+	if err != nil {
+		return err
+	}
+
+	step, ok := totp.Validate(secret, code, now)
+	if !ok {
+		return ErrInvalidCode
+	}
+
+	// Taking the step only if it's newer than the last one used stops the same code being replayed
+	result, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Accounts).UpdateOne(ctx, bson.M{
+		"user_uuid": account.UserUUID,
+		"$or": []bson.M{
+			{"totp.last_used_step": nil},
+			{"totp.last_used_step": bson.M{"$lt": step}},
+		},
+	}, bson.M{"$set": bson.M{"totp.last_used_step": step}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrInvalidCode
+	}
+
+	account.TOTP.LastUsedStep = ptr.Int64(step)
+	return nil
+}
+
+// Disable removes the authenticator app after checking a code from it. Two-factor stays on if it was,
+// with codes going back to email.
+func Disable(ctx context.Context, account *accountEntities.AccountEntity, code string, now time.Time) error {
+	if err := Verify(ctx, account, code, now); err != nil {
+		return err
+	}
+
+	_, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Accounts).UpdateOne(ctx, bson.M{"user_uuid": account.UserUUID}, bson.M{
+		"$set":   bson.M{"two_fa_method": MethodEmail, "updated_at": now},
+		"$unset": bson.M{"totp": "", "pending_totp": ""},
+	})
+	if err != nil {
+		return err
+	}
+
+	account.TOTP = nil
+	account.PendingTOTP = nil
+	account.TwoFAMethod = ptr.String(MethodEmail)
+	return nil
+}
+
+// seal encrypts the secret with the latest symmetric key
+func seal(secret string, now time.Time) (*accountEntities.TOTPSecret, error) {
+	version := secrets.SymmetricKeyValueLatestVersion
+
+	encrypted, err := encryption.SymmetricEncryption(secret, version, "encrypt")
+	if err != nil {
+		return nil, err
+	}
+
+	return &accountEntities.TOTPSecret{
+		EncryptedSecret: ptr.String(encrypted),
+		KeyVersion:      ptr.Int64(int64(version)),
+		CreatedAt:       ptr.Time(now),
+	}, nil
+}
+
+// open decrypts the secret with the key it was encrypted with
+func open(sealed *accountEntities.TOTPSecret) (string, error) {
+	if sealed.EncryptedSecret == nil || sealed.KeyVersion == nil {
+		return "", ErrTOTPNotEnabled
+	}
+	return encryption.SymmetricEncryption(*sealed.EncryptedSecret, int32(*sealed.KeyVersion), "decrypt")
+}
@@ -0,0 +1,84 @@
+package twofactor
+
+import (
+	"testing"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMethod(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Account  *accountEntities.AccountEntity
+		Expected string
+	}{
+		{"Never set", &accountEntities.AccountEntity{}, MethodEmail},
+		{"Email", &accountEntities.AccountEntity{TwoFAMethod: ptr.String(MethodEmail)}, MethodEmail},
+		{"Authenticator app", &accountEntities.AccountEntity{TwoFAMethod: ptr.String(MethodTOTP), TOTP: &accountEntities.TOTPSecret{}}, MethodTOTP},
+		{"Still setting up", &accountEntities.AccountEntity{PendingTOTP: &accountEntities.TOTPSecret{}}, MethodEmail},
+		{"Method without a secret", &accountEntities.AccountEntity{TwoFAMethod: ptr.String(MethodTOTP)}, MethodEmail},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, Method(tt.Account))
+		})
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes()
+	assert.Nil(t, err)
+	assert.Len(t, codes, RecoveryCodeCount)
+
+	seen := map[string]bool{}
+	for _, code := range codes {
+		assert.Regexp(t, `^[A-HJ-NP-Z2-9]{5}-[A-HJ-NP-Z2-9]{5}$`, code)
+		assert.Equal(t, code, NormalizeRecoveryCode(code))
+		assert.False(t, seen[code])
+		seen[code] = true
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Code     string
+		Expected string
+	}{
+		{"As handed out", "ABCDE-FGHJK", "ABCDE-FGHJK"},
+		{"Lowercase", "abcde-fghjk", "ABCDE-FGHJK"},
+		{"No dash", "ABCDEFGHJK", "ABCDE-FGHJK"},
+		{"Spaces", " abcde fghjk ", "ABCDE-FGHJK"},
+		{"Too short", "abc-de", "ABCDE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, NormalizeRecoveryCode(tt.Code))
+		})
+	}
+}
+
+func TestRemainingRecoveryCodes(t *testing.T) {
+	used := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		Name     string
+		Account  *accountEntities.AccountEntity
+		Expected int
+	}{
+		{"No codes", &accountEntities.AccountEntity{}, 0},
+		{"None used", &accountEntities.AccountEntity{RecoveryCodes: []*accountEntities.RecoveryCode{{}, {}, {}}}, 3},
+		{"Some used", &accountEntities.AccountEntity{RecoveryCodes: []*accountEntities.RecoveryCode{{UsedAt: &used}, {}, {UsedAt: &used}}}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, RemainingRecoveryCodes(tt.Account))
+		})
+	}
+}
@@ -3,10 +3,20 @@ package entities
 type ConfigEntity struct {
 	AppID	   		*string    				`json:"app_id" bson:"app_id"`
 	Announcement 	*AnnouncementEntity 	`json:"announcement" bson:"announcement"`
+	Fare			*FareConfigEntity		`json:"fare" bson:"fare"`
 }
 
 type AnnouncementEntity struct {
 	Version    		*int64     	`json:"version" bson:"version"`
 	Announcement    *string    	`json:"announcement" bson:"announcement"`
 	Type   			*string    	`json:"type" bson:"type"` // general, warning,
+}
+
+// Inputs for suggested fares, anything left unset uses the defaults in trip/fare
+type FareConfigEntity struct {
+	FuelPrice		*float64	`json:"fuel_price" bson:"fuel_price"` // Dollars per gallon
+	MPG				*float64	`json:"mpg" bson:"mpg"` // Used when the vehicle doesn't have one
+	MaxPerMile		*float64	`json:"max_per_mile" bson:"max_per_mile"` // Most a trip's gas and trip fare can add up to, per mile
+	RouteFactor		*float64	`json:"route_factor" bson:"route_factor"` // Road miles per straight line mile
+	CapAction		*string		`json:"cap_action" bson:"cap_action"` // warn, reject
 }
\ No newline at end of file
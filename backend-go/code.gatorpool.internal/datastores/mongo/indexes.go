@@ -0,0 +1,221 @@
+package mongo
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MARK: EnsureIndexes
+// EnsureIndexes creates the indexes the queries rely on. Creating an index that already exists is a no-op.
+func EnsureIndexes(ctx context.Context) error {
+	db := GetMongoDatabase(ctx)
+
+	// Geospatial matching of trip waypoints
+	_, err := db.Collection(Trips).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "waypoints.location", Value: "2dsphere"}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Matching riders along a driver's route
+	_, err = db.Collection(Trips).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "route", Value: "2dsphere"}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Trip UUIDs are unique, which also keeps a recurring trip's occurrences from being created twice
+	_, err = db.Collection(Trips).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "trip_uuid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// Occurrences of a recurring trip
+	_, err = db.Collection(Trips).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "series_uuid", Value: 1}, {Key: "datetime", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// A trip's latest location ping
+	_, err = db.Collection(TripLocations).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "trip_uuid", Value: 1}, {Key: "recorded_at", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Pings are deleted when the trip ends, this catches trips that never did
+	_, err = db.Collection(TripLocations).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "received_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(60 * 60 * 24),
+	})
+	if err != nil {
+		return err
+	}
+
+	// A trip's chat history, newest first
+	_, err = db.Collection(TripMessages).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "trip_uuid", Value: 1}, {Key: "created_at", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Chat messages are deleted once the retention window after the trip passes
+	_, err = db.Collection(TripMessages).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+
+	// A trip's timeline, oldest first
+	_, err = db.Collection(TripEvents).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "trip_uuid", Value: 1}, {Key: "created_at", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// The review queue of driver applications, oldest first
+	_, err = db.Collection(DriverApplications).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "created_at", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Picking up outbox entries that are due
+	_, err = db.Collection(Outbox).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Sent entries are kept for a month, then cleaned up
+	_, err = db.Collection(Outbox).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "sent_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(60 * 60 * 24 * 30),
+	})
+	if err != nil {
+		return err
+	}
+
+	// A user's notifications, newest first
+	_, err = db.Collection(Notifications).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_uuid", Value: 1}, {Key: "created_at", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Counting a user's unread notifications
+	_, err = db.Collection(Notifications).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_uuid", Value: 1}, {Key: "read", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection(Notifications).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "notification_uuid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// One debt per rider per trip, so recording a trip twice doesn't charge anyone twice
+	_, err = db.Collection(Ledger).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "trip_uuid", Value: 1}, {Key: "rider_uuid", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	// What a user owes and is owed
+	_, err = db.Collection(Ledger).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "rider_uuid", Value: 1}, {Key: "status", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection(Ledger).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "driver_uuid", Value: 1}, {Key: "status", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Finding overdue debts
+	_, err = db.Collection(Ledger).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "due_at", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// A user's standing, the ban check on every request, and resolving expired warnings
+	_, err = db.Collection(Warnings).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_uuid", Value: 1}, {Key: "resolved", Value: 1}, {Key: "type", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection(Warnings).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "resolved", Value: 1}, {Key: "resolves_at", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Appeals waiting for a moderator
+	_, err = db.Collection(Warnings).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "appeal.status", Value: 1}, {Key: "appeal.submitted_at", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Finding trips nobody finished
+	_, err = db.Collection(Trips).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "status", Value: 1}, {Key: "datetime", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// One bucket per key, deleted once it has filled back up
+	_, err = db.Collection(RateLimits).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "key", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection(RateLimits).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
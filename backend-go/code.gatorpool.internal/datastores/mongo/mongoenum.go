@@ -9,6 +9,16 @@ const (
 	Riders 							= "riders"
 	Config 							= "config"
 	Trips 							= "trips"
+	TripSeries 						= "trip_series"
+	TripLocations 					= "trip_locations"
+	TripMessages 					= "trip_messages"
+	TripEvents 						= "trip_events"
 	Drivers 						= "drivers"
 	DriverApplications 				= "driver-applications"
+	Outbox 							= "outbox"
+	Notifications 					= "notifications"
+	Ledger 							= "ledger"
+	JobLeases 						= "job_leases"
+	JobRuns 						= "job_runs"
+	RateLimits 						= "rate_limits"
 )
\ No newline at end of file
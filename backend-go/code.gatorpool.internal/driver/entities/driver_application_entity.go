@@ -31,11 +31,18 @@ type DriverApplicationEntity struct {
 	State				*string						`json:"state" bson:"state"`
 	ZipCode				*string						`json:"zip_code" bson:"zip_code"`
 
+	// pending, approved, rejected, changes_requested
+	Status				*string						`json:"status" bson:"status"`
+
 	Accepted			*bool						`json:"accepted" bson:"accepted"`
 	AcceptedAt			*time.Time					`json:"accepted_at" bson:"accepted_at"`
 	Message				*string						`json:"message" bson:"message"`
 	Closed				*bool						`json:"closed" bson:"closed"`
 
+	// The moderator or admin who last reviewed the application, and when
+	ReviewedBy			*string						`json:"reviewed_by" bson:"reviewed_by"`
+	ReviewedAt			*time.Time					`json:"reviewed_at" bson:"reviewed_at"`
+
 	CreatedAt			*time.Time					`json:"created_at" bson:"created_at"`
 	UpdatedAt			*time.Time					`json:"updated_at" bson:"updated_at"`
 }
\ No newline at end of file
@@ -14,6 +14,9 @@ type DriverEntity struct {
 	// The average rating of the driver given by riders
 	Rating				*float64					`json:"rating" bson:"rating"`
 
+	// The number of ratings the average is made of
+	RatingCount			*int64						`json:"rating_count" bson:"rating_count"`
+
 	// Any ongoing or past warnings/bans/complaints, etc.
 	Disceplanary 		*DriverDisceplanaryEntity	`json:"disceplanary" bson:"disceplanary"`
 
@@ -19,4 +19,5 @@ type RequestDriverApplyEntity struct {
 	LicenseState	*string		`json:"license_state" bson:"license_state"`
 	Seats			*int		`json:"seats" bson:"seats"`
 	Lugroom			*int		`json:"lugroom" bson:"lugroom"`
+	MPG				*float64	`json:"mpg" bson:"mpg"` // Optional
 }
\ No newline at end of file
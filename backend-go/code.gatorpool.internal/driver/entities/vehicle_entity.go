@@ -32,5 +32,8 @@ type VehicleEntity struct {
 	// The amount of luggage room in the vehicle
 	Lugroom				*int			`json:"lugroom" bson:"lugroom"`
 
+	// Miles per gallon, used to suggest the fare for gas
+	MPG					*float64		`json:"mpg,omitempty" bson:"mpg,omitempty"`
+
 	CreatedAt 			*time.Time		`json:"created_at" bson:"created_at"`
 }
\ No newline at end of file
@@ -11,6 +11,7 @@ import (
 	accountEntities "code.gatorpool.internal/account/entities"
 	datastores "code.gatorpool.internal/datastores/mongo"
 	driverEntities "code.gatorpool.internal/driver/entities"
+	"code.gatorpool.internal/driver/review"
 	driverValidator "code.gatorpool.internal/driver/validator"
 	"code.gatorpool.internal/util"
 	"code.gatorpool.internal/util/ptr"
@@ -18,6 +19,7 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type LoadInRequestBody struct {
@@ -55,7 +57,7 @@ func DriverApply(req *http.Request, res http.ResponseWriter, ctx context.Context
 
 	// Check if driver application already exists
 	var driverApplication *driverEntities.DriverApplicationEntity
-	err = driverApplicationCollection.FindOne(ctx, bson.M{"user_uuid": *account.UserUUID}).Decode(&driverApplication)
+	err = driverApplicationCollection.FindOne(ctx, bson.M{"user_uuid": *account.UserUUID, "closed": false}).Decode(&driverApplication)
 	if err != nil {
 		if err != mongo.ErrNoDocuments {
 			return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
@@ -64,10 +66,34 @@ func DriverApply(req *http.Request, res http.ResponseWriter, ctx context.Context
 		}
 	}
 
-	if driverApplication != nil && driverApplication.Closed != nil && !*driverApplication.Closed {
-		return util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
-			"error": "driver application already exists",
-		})
+	if driverApplication != nil {
+		if review.Status(driverApplication) != review.StatusChangesRequested {
+			return util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
+				"error": "driver application already exists",
+			})
+		}
+
+		// The applicant was asked for changes, so this application replaces the old one
+		_, err = driverApplicationCollection.UpdateOne(ctx, bson.M{"application_uuid": driverApplication.ApplicationUUID}, bson.M{"$set": bson.M{
+			"closed":     true,
+			"updated_at": time.Now(),
+		}})
+		if err != nil {
+			return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+
+		if driverApplication.Vehicle != nil {
+			_, err = db.Collection(datastores.Drivers).UpdateOne(ctx, bson.M{"driver_uuid": account.UserUUID}, bson.M{"$pull": bson.M{
+				"vehicles": bson.M{"vehicle_uuid": driverApplication.Vehicle.VehicleUUID},
+			}})
+			if err != nil {
+				return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+					"error": err.Error(),
+				})
+			}
+		}
 	}
 
 	applicationUUID := uuid.NewRandom().String()
@@ -85,6 +111,7 @@ func DriverApply(req *http.Request, res http.ResponseWriter, ctx context.Context
 		City: 		   		requestBody.City,
 		State: 		   		requestBody.State,
 		ZipCode: 	   		requestBody.Zip,
+		Status: 			ptr.String(review.StatusPending),
 		Accepted: 	 		ptr.Bool(false),
 		AcceptedAt: 		nil,
 		Message: 			nil,
@@ -101,6 +128,7 @@ func DriverApply(req *http.Request, res http.ResponseWriter, ctx context.Context
 			State: 			requestBody.LicenseState,
 			Seats: 			requestBody.Seats,
 			Lugroom: 		requestBody.Lugroom,
+			MPG: 			requestBody.MPG,
 			CreatedAt: 		ptr.Time(time.Now()),
 		},
 	}
@@ -112,25 +140,23 @@ func DriverApply(req *http.Request, res http.ResponseWriter, ctx context.Context
 		})
 	}
 
-	newDriverEntity := &driverEntities.DriverEntity{
-		DriverUUID: account.UserUUID,
-		PastTrips: []*string{},
-		Rating: nil,
-		Disceplanary: nil,
-		Applications: []*driverEntities.DriverApplicationEntity{
-			newDriverApplication,
+	// Applicants who applied before already have a driver
+	driverCollection := db.Collection(datastores.Drivers)
+	_, err = driverCollection.UpdateOne(ctx, bson.M{"driver_uuid": account.UserUUID}, bson.M{
+		"$push": bson.M{
+			"applications": newDriverApplication,
+			"vehicles":     newDriverApplication.Vehicle,
 		},
-		Vehicles: []*driverEntities.VehicleEntity{
-			newDriverApplication.Vehicle,
+		"$set": bson.M{"updated_at": time.Now()},
+		"$setOnInsert": bson.M{
+			"past_trips":   []*string{},
+			"rating":       nil,
+			"disceplanary": nil,
+			"verified":     false,
+			"verified_at":  nil,
+			"created_at":   time.Now(),
 		},
-		Verified: ptr.Bool(false),
-		VerifiedAt: nil,
-		CreatedAt: ptr.Time(time.Now()),
-		UpdatedAt: ptr.Time(time.Now()),
-	}
-
-	driverCollection := db.Collection(datastores.Drivers)
-	_, err = driverCollection.InsertOne(ctx, newDriverEntity)
+	}, options.Update().SetUpsert(true))
 	if err != nil {
 		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
 			"error": err.Error(),
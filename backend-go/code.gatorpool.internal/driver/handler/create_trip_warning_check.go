@@ -7,10 +7,8 @@ import (
 	"time"
 
 	accountEntities "code.gatorpool.internal/account/entities"
-	datastores "code.gatorpool.internal/datastores/mongo"
-	warningEntities "code.gatorpool.internal/fulfillment/entities"
+	"code.gatorpool.internal/fulfillment/warnings"
 	"code.gatorpool.internal/util"
-	"go.mongodb.org/mongo-driver/bson"
 )
 
 func CreateTripWarningCheck(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
@@ -24,54 +22,18 @@ func CreateTripWarningCheck(req *http.Request, res http.ResponseWriter, ctx cont
 		})
 	}
 
-	db := datastores.GetMongoDatabase(ctx)
-	warningsCollection := db.Collection(datastores.Warnings)
-
-	var warnings []*warningEntities.WarningEntity
-	cursor, err := warningsCollection.Find(ctx, bson.M{"user_uuid": account.UserUUID})
+	userWarnings, err := warnings.ForUser(ctx, *account.UserUUID)
 	if err != nil {
 		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
-	defer cursor.Close(ctx)
-
-	// decode all the warnings
-	if err := cursor.All(ctx, &warnings); err != nil {
-		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
-			"error": err.Error(),
-		})
-	}
-
-	if warnings == nil {
-		warnings = []*warningEntities.WarningEntity{}
-
-		return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
-			"warnings": warnings,
-			"can_create_trip": true,
-			"success": true,
-		})
-	}
 
-	aggregatedUsablePoints := 0
-	for _, warning := range warnings {
-		// time check, if the warning's resolves at is in the past, then it is usable
-		if (warning.ResolvesAt != nil && warning.ResolvesAt.After(time.Now())) || (warning.Resolved != nil && !*warning.Resolved) {
-			aggregatedUsablePoints += *warning.Points
-		}
-	}
-
-	if aggregatedUsablePoints >= 3 {
-		return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
-			"warnings": warnings,
-			"can_create_trip": false,
-			"success": true,
-		})
-	}
+	standing := warnings.Assess(userWarnings, time.Now())
 
 	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
-		"warnings": warnings,
-		"can_create_trip": true,
+		"warnings": userWarnings,
+		"can_create_trip": standing.CanCreateTrip,
 		"success": true,
 	})
 }
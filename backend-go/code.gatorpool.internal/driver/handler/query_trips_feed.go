@@ -4,13 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	// "math"
 	"net/http"
 	"time"
 
 	accountEntities "code.gatorpool.internal/account/entities"
 	datastores "code.gatorpool.internal/datastores/mongo"
 	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/geo"
 	tripHandler "code.gatorpool.internal/trip/handler"
 	"code.gatorpool.internal/util"
 
@@ -30,7 +30,8 @@ type QueryTripsBodyRequest struct {
 		Lat float64 `json:"latitude"`
 		Lng float64 `json:"longitude"`
 	} `json:"to"`
-	Datetime string `json:"datetime"`
+	Datetime string   `json:"datetime"`
+	Radius   *float64 `json:"radius"` // Miles, defaults to geo.DefaultRadiusMiles
 }
 
 func QueryTripsFeed(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
@@ -72,67 +73,36 @@ func QueryTripsFeed(req *http.Request, res http.ResponseWriter, ctx context.Cont
 		"flow_type": "rider_requests_driver",
 	}
 
-	// // Find trips where the driver's destination waypoint is within 50 miles
-	// round := func(val float64) float64 {
-	// 	return math.Round(val*1e6) / 1e6
-	// }
-
-	// lat := round(body.To.Lat)
-	// lng := round(body.To.Lng)
-
-	// query["waypoints"] = bson.M{
-	// 	"$elemMatch": bson.M{
-	// 		"type": "destination",
-	// 		"for":  "driver",
-	// 		"latitude": bson.M{
-	// 			"$gte": lat - 0.725,
-	// 			"$lte": lat + 0.725,
-	// 		},
-	// 		"longitude": bson.M{
-	// 			"$gte": lng - 0.725,
-	// 			"$lte": lng + 0.725,
-	// 		},
-	// 	},
-	// }
-
-	// assigned_driver is null or nonexistent
-	// query["$or"] = []bson.M{
-	// 	{ "assigned_driver": bson.M{"$exists": false} },
-	// 	{ "assigned_driver": nil },
-	// }	
+	from := geo.LatLng{Lat: body.From.Lat, Lng: body.From.Lng}
+	to := geo.LatLng{Lat: body.To.Lat, Lng: body.To.Lng}
+	if !geo.Valid(from.Lat, from.Lng) || !geo.Valid(to.Lat, to.Lng) {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid coordinates",
+		})
+	}
+
+	// Find rider requests where the pickup and destination are within the driver's radius,
+	// the rider's max drop off radius is checked after the query
+	radius := geo.Radius(body.Radius)
 
 	query["waypoints"] = bson.M{
 		"$all": []interface{}{
 			bson.M{
 				"$elemMatch": bson.M{
-					"type": "pickup",
-					"for":  "driver",
-					"latitude": bson.M{
-						"$gte": body.From.Lat - 0.725,
-						"$lte": body.From.Lat + 0.725,
-					},
-					"longitude": bson.M{
-						"$gte": body.From.Lng - 0.725,
-						"$lte": body.From.Lng + 0.725,
-					},
+					"type":     "pickup",
+					"for":      "driver",
+					"location": geo.WithinMiles(from, radius),
 				},
 			},
 			bson.M{
 				"$elemMatch": bson.M{
-					"type": "destination",
-					"for":  "driver",
-					"latitude": bson.M{
-						"$gte": body.To.Lat - 0.725,
-						"$lte": body.To.Lat + 0.725,
-					},
-					"longitude": bson.M{
-						"$gte": body.To.Lng - 0.725,
-						"$lte": body.To.Lng + 0.725,
-					},
+					"type":     "destination",
+					"for":      "driver",
+					"location": geo.WithinMiles(to, radius),
 				},
 			},
 		},
-	}	
+	}
 
 	// Filter the trips that are within 48 hours of the datetime
 	query["datetime"] = bson.M{
@@ -162,13 +132,24 @@ func QueryTripsFeed(req *http.Request, res http.ResponseWriter, ctx context.Cont
 	}
 
 	var newTrips []tripEntities.TripEntity
+	distances := map[string]geo.Match{}
 	// check if any trip is in the past
 	now := time.Now().UTC()
 	for _, trip := range trips {
-		if trip.Datetime.After(now) {
-			newTrips = append(newTrips, trip)
+		if !trip.Datetime.After(now) {
+			continue
 		}
-	}	
+
+		match, ok := geo.MatchTrip(&trip, from, to, radius)
+		if !ok {
+			continue
+		}
+
+		distances[*trip.TripUUID] = match
+		newTrips = append(newTrips, trip)
+	}
+
+	geo.SortByDistance(newTrips, distances)
 
 	if newTrips == nil {
 		newTrips = []tripEntities.TripEntity{}
@@ -178,6 +159,7 @@ func QueryTripsFeed(req *http.Request, res http.ResponseWriter, ctx context.Cont
 		return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
 			"trips":   newTrips,
 			"driverProfiles": []string{},
+			"distances": distances,
 			"success": true,
 		})
 	}
@@ -198,6 +180,7 @@ func QueryTripsFeed(req *http.Request, res http.ResponseWriter, ctx context.Cont
 	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
 		"trips":   newTrips,
 		"riderProfiles": riderProfiles,
+		"distances": distances,
 		"success": true,
 	})
 }
@@ -0,0 +1,188 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/driver/review"
+	"code.gatorpool.internal/notify"
+	"code.gatorpool.internal/util"
+	"github.com/go-chi/chi"
+)
+
+type ReviewDriverApplicationBody struct {
+	// Shown to the applicant, required when rejecting or asking for changes
+	Message string `json:"message"`
+}
+
+// ListDriverApplications returns a page of driver applications for staff to review, pending
+// ones by default
+func ListDriverApplications(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	query := req.URL.Query()
+
+	// Parse page number from query parameters
+	page := 1
+	if pageStr := query.Get("page"); pageStr != "" {
+		if parsedPage, err := strconv.Atoi(pageStr); err == nil && parsedPage > 0 {
+			page = parsedPage
+		}
+	}
+
+	filter := review.Filter{
+		Status: query.Get("status"),
+		Search: query.Get("q"),
+		State:  query.Get("state"),
+	}
+
+	var err error
+	if filter.Since, err = parseDateParam(query.Get("since")); err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "since must be an RFC 3339 date",
+		})
+	}
+	if filter.Until, err = parseDateParam(query.Get("until")); err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "until must be an RFC 3339 date",
+		})
+	}
+
+	applications, total, err := review.List(ctx, filter, page)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"applications": applications,
+		"currentPage":  page,
+		"totalPages":   int(math.Ceil(float64(total) / float64(review.ApplicationsPerPage))),
+		"success":      true,
+	})
+}
+
+// ApproveDriverApplication verifies the applicant as a driver with the vehicle they applied with
+func ApproveDriverApplication(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+	return reviewDriverApplication(req, res, ctx, review.ActionApprove)
+}
+
+// RejectDriverApplication closes the application, telling the applicant why
+func RejectDriverApplication(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+	return reviewDriverApplication(req, res, ctx, review.ActionReject)
+}
+
+// RequestDriverApplicationChanges asks the applicant to fix something and apply again
+func RequestDriverApplicationChanges(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+	return reviewDriverApplication(req, res, ctx, review.ActionRequestChanges)
+}
+
+// reviewDriverApplication records the reviewer's decision on the application and lets the applicant know
+func reviewDriverApplication(req *http.Request, res http.ResponseWriter, ctx context.Context, action string) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	var body ReviewDriverApplicationBody
+	if req.ContentLength != 0 {
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+				"error": "invalid body",
+			})
+		}
+	}
+
+	message, err := review.Message(action, body.Message)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	application, err := review.Find(ctx, chi.URLParam(req, "application_uuid"))
+	if err == review.ErrApplicationNotFound {
+		return util.JSONResponse(res, http.StatusNotFound, map[string]interface{}{
+			"error": err.Error(),
+		})
+	} else if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	err = review.Decide(application, action, message, *account.UserUUID, time.Now())
+	if err == review.ErrApplicationClosed {
+		return util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
+			"error": err.Error(),
+		})
+	} else if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	// Verify the driver before closing the application, so if it fails the application is still open
+	// to approve again. Approving twice is harmless.
+	if action == review.ActionApprove {
+		if err := review.Approve(ctx, application); err != nil {
+			return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	err = review.Save(ctx, application)
+	if err == review.ErrApplicationClosed {
+		return util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
+			"error": err.Error(),
+		})
+	} else if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	eventType := map[string]string{
+		review.ActionApprove:        notify.EventApplicationApproved,
+		review.ActionReject:         notify.EventApplicationRejected,
+		review.ActionRequestChanges: notify.EventApplicationChangesRequested,
+	}[action]
+
+	event := notify.Event{
+		Type:     eventType,
+		UserUUID: *application.UserUUID,
+		Data:     map[string]string{"MESSAGE": message},
+	}
+	if err := notify.Enqueue(ctx, event); err != nil {
+		fmt.Println("Error queueing "+eventType+" notification: ", err)
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"application": application,
+		"success":     true,
+	})
+}
+
+// parseDateParam parses an optional date from the query string
+func parseDateParam(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &parsed, nil
+}
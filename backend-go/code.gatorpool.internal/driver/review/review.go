@@ -0,0 +1,286 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"code.gatorpool.internal/account/roles"
+	datastores "code.gatorpool.internal/datastores/mongo"
+	driverEntities "code.gatorpool.internal/driver/entities"
+	"code.gatorpool.internal/util/ptr"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	StatusPending          = "pending"
+	StatusApproved         = "approved"
+	StatusRejected         = "rejected"
+	StatusChangesRequested = "changes_requested"
+
+	ActionApprove        = "approve"
+	ActionReject         = "reject"
+	ActionRequestChanges = "request_changes"
+
+	// Longest message to the applicant, in characters
+	MaxMessageLength = 1000
+
+	ApplicationsPerPage = 25
+)
+
+var (
+	ErrApplicationNotFound = errors.New("application not found")
+	ErrApplicationClosed   = errors.New("application has already been closed")
+	ErrUnknownAction       = errors.New("unknown review action")
+	ErrMessageRequired     = errors.New("a message for the applicant is required")
+	ErrMessageTooLong      = errors.New("message is too long")
+)
+
+// Filter narrows down the applications a reviewer is looking at
+type Filter struct {
+	// Defaults to pending
+	Status string
+
+	// Matches the applicant's name or email
+	Search string
+
+	// Two letter state from the applicant's address
+	State string
+
+	// Only applications submitted in this window
+	Since *time.Time
+	Until *time.Time
+}
+
+// Status is where the application is in review. Applications from before reviews had a status
+// are worked out from Accepted and Closed
+func Status(application *driverEntities.DriverApplicationEntity) string {
+	switch {
+	case application.Status != nil:
+		return *application.Status
+	case application.Accepted != nil && *application.Accepted:
+		return StatusApproved
+	case application.Closed != nil && *application.Closed:
+		return StatusRejected
+	default:
+		return StatusPending
+	}
+}
+
+// Open reports whether the application can still be reviewed
+func Open(application *driverEntities.DriverApplicationEntity) bool {
+	return application.Closed == nil || !*application.Closed
+}
+
+// Message trims the message to the applicant and checks it isn't too long. Rejecting or asking
+// for changes needs one, so the applicant knows why
+func Message(action string, message string) (string, error) {
+	message = strings.TrimSpace(message)
+	if message == "" && action != ActionApprove {
+		return "", ErrMessageRequired
+	}
+	if utf8.RuneCountInString(message) > MaxMessageLength {
+		return "", ErrMessageTooLong
+	}
+	return message, nil
+}
+
+// Decide applies the reviewer's decision to the application. Approving or rejecting closes it, asking
+// for changes leaves it open for the applicant to apply again
+func Decide(application *driverEntities.DriverApplicationEntity, action string, message string, reviewerUUID string, now time.Time) error {
+	if !Open(application) {
+		return ErrApplicationClosed
+	}
+
+	switch action {
+	case ActionApprove:
+		application.Status = ptr.String(StatusApproved)
+		application.Accepted = ptr.Bool(true)
+		application.AcceptedAt = ptr.Time(now)
+		application.Closed = ptr.Bool(true)
+	case ActionReject:
+		application.Status = ptr.String(StatusRejected)
+		application.Accepted = ptr.Bool(false)
+		application.Closed = ptr.Bool(true)
+	case ActionRequestChanges:
+		application.Status = ptr.String(StatusChangesRequested)
+		application.Accepted = ptr.Bool(false)
+	default:
+		return ErrUnknownAction
+	}
+
+	application.Message = nil
+	if message != "" {
+		application.Message = ptr.String(message)
+	}
+	application.ReviewedBy = ptr.String(reviewerUUID)
+	application.ReviewedAt = ptr.Time(now)
+	application.UpdatedAt = ptr.Time(now)
+	return nil
+}
+
+// Query turns the filter into a Mongo query on the applications collection
+func Query(filter Filter) bson.M {
+	query := bson.M{}
+
+	switch filter.Status {
+	case "", StatusPending:
+		query["$or"] = bson.A{
+			bson.M{"status": StatusPending},
+			bson.M{"status": nil, "closed": false},
+		}
+	default:
+		query["status"] = filter.Status
+	}
+
+	if search := strings.TrimSpace(filter.Search); search != "" {
+		pattern := searchPattern(search)
+		query["$and"] = bson.A{bson.M{"$or": bson.A{
+			bson.M{"full_name": pattern},
+			bson.M{"email": pattern},
+		}}}
+	}
+
+	if filter.State != "" {
+		query["state"] = strings.ToUpper(filter.State)
+	}
+
+	if filter.Since != nil || filter.Until != nil {
+		createdAt := bson.M{}
+		if filter.Since != nil {
+			createdAt["$gte"] = *filter.Since
+		}
+		if filter.Until != nil {
+			createdAt["$lt"] = *filter.Until
+		}
+		query["created_at"] = createdAt
+	}
+
+	return query
+}
+
+// List returns a page of the applications matching the filter, oldest first so the ones that have
+// waited longest are reviewed first, and how many match in total
+func List(ctx context.Context, filter Filter, page int) ([]*driverEntities.DriverApplicationEntity, int64, error) {
+	applicationsCollection := datastores.GetMongoDatabase(ctx).Collection(datastores.DriverApplications)
+
+	query := Query(filter)
+
+	total, err := applicationsCollection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: 1}}).
+		SetSkip(int64((page - 1) * ApplicationsPerPage)).
+		SetLimit(ApplicationsPerPage)
+
+	cursor, err := applicationsCollection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	applications := []*driverEntities.DriverApplicationEntity{}
+	if err := cursor.All(ctx, &applications); err != nil {
+		return nil, 0, err
+	}
+
+	return applications, total, nil
+}
+
+// Find looks up an application
+func Find(ctx context.Context, applicationUUID string) (*driverEntities.DriverApplicationEntity, error) {
+	var application *driverEntities.DriverApplicationEntity
+	err := datastores.GetMongoDatabase(ctx).Collection(datastores.DriverApplications).FindOne(ctx, bson.M{"application_uuid": applicationUUID}).Decode(&application)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrApplicationNotFound
+	}
+	return application, err
+}
+
+// Save writes the decision, as long as nobody closed the application first, and keeps the copy
+// on the driver in step
+func Save(ctx context.Context, application *driverEntities.DriverApplicationEntity) error {
+	db := datastores.GetMongoDatabase(ctx)
+
+	result, err := db.Collection(datastores.DriverApplications).UpdateOne(ctx, bson.M{
+		"application_uuid": application.ApplicationUUID,
+		"closed":           bson.M{"$ne": true},
+	}, bson.M{"$set": bson.M{
+		"status":      application.Status,
+		"accepted":    application.Accepted,
+		"accepted_at": application.AcceptedAt,
+		"message":     application.Message,
+		"closed":      application.Closed,
+		"reviewed_by": application.ReviewedBy,
+		"reviewed_at": application.ReviewedAt,
+		"updated_at":  application.UpdatedAt,
+	}})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrApplicationClosed
+	}
+
+	_, err = db.Collection(datastores.Drivers).UpdateOne(ctx, bson.M{
+		"driver_uuid":                   application.UserUUID,
+		"applications.application_uuid": application.ApplicationUUID,
+	}, bson.M{"$set": bson.M{"applications.$": application}})
+	return err
+}
+
+// Approve verifies the applicant as a driver, creating the driver if they don't have one yet, and
+// adds the vehicle from the application to their vehicles
+func Approve(ctx context.Context, application *driverEntities.DriverApplicationEntity) error {
+	db := datastores.GetMongoDatabase(ctx)
+	driversCollection := db.Collection(datastores.Drivers)
+	now := time.Now()
+
+	_, err := db.Collection(datastores.Accounts).UpdateOne(ctx, bson.M{"user_uuid": application.UserUUID}, bson.M{
+		"$addToSet": bson.M{"roles": bson.M{"$each": []string{roles.Rider, roles.Driver}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = driversCollection.UpdateOne(ctx, bson.M{"driver_uuid": application.UserUUID}, bson.M{
+		"$set": bson.M{
+			"verified":    true,
+			"verified_at": now,
+			"updated_at":  now,
+		},
+		"$setOnInsert": bson.M{
+			"past_trips":   []*string{},
+			"applications": []*driverEntities.DriverApplicationEntity{application},
+			"vehicles":     []*driverEntities.VehicleEntity{},
+			"created_at":   now,
+		},
+	}, options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+
+	if application.Vehicle == nil || application.Vehicle.VehicleUUID == nil {
+		return nil
+	}
+
+	// Only add the vehicle if the driver doesn't already have it
+	_, err = driversCollection.UpdateOne(ctx, bson.M{
+		"driver_uuid":           application.UserUUID,
+		"vehicles.vehicle_uuid": bson.M{"$ne": *application.Vehicle.VehicleUUID},
+	}, bson.M{"$push": bson.M{"vehicles": application.Vehicle}})
+	return err
+}
+
+// searchPattern matches the text anywhere in a field, ignoring case
+func searchPattern(search string) bson.M {
+	return bson.M{"$regex": regexp.QuoteMeta(search), "$options": "i"}
+}
@@ -0,0 +1,118 @@
+package review
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	driverEntities "code.gatorpool.internal/driver/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestStatus(t *testing.T) {
+	tests := []struct {
+		Name        string
+		Application *driverEntities.DriverApplicationEntity
+		Expected    string
+	}{
+		{"New application", &driverEntities.DriverApplicationEntity{Status: ptr.String(StatusPending), Closed: ptr.Bool(false)}, StatusPending},
+		{"Changes requested", &driverEntities.DriverApplicationEntity{Status: ptr.String(StatusChangesRequested), Closed: ptr.Bool(false)}, StatusChangesRequested},
+		{"Legacy open", &driverEntities.DriverApplicationEntity{Accepted: ptr.Bool(false), Closed: ptr.Bool(false)}, StatusPending},
+		{"Legacy accepted", &driverEntities.DriverApplicationEntity{Accepted: ptr.Bool(true), Closed: ptr.Bool(true)}, StatusApproved},
+		{"Legacy closed", &driverEntities.DriverApplicationEntity{Accepted: ptr.Bool(false), Closed: ptr.Bool(true)}, StatusRejected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, Status(tt.Application))
+		})
+	}
+}
+
+func TestMessage(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Action   string
+		Message  string
+		Expected string
+		Err      error
+	}{
+		{"Approving without a message", ActionApprove, "", "", nil},
+		{"Rejecting with a message", ActionReject, "  License plate doesn't match the registration ", "License plate doesn't match the registration", nil},
+		{"Rejecting without a message", ActionReject, "   ", "", ErrMessageRequired},
+		{"Asking for changes without a message", ActionRequestChanges, "", "", ErrMessageRequired},
+		{"Too long", ActionReject, strings.Repeat("a", MaxMessageLength+1), "", ErrMessageTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			message, err := Message(tt.Action, tt.Message)
+			assert.Equal(t, tt.Err, err)
+			assert.Equal(t, tt.Expected, message)
+		})
+	}
+}
+
+func TestDecide(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	newApplication := func() *driverEntities.DriverApplicationEntity {
+		return &driverEntities.DriverApplicationEntity{
+			Status:   ptr.String(StatusPending),
+			Accepted: ptr.Bool(false),
+			Closed:   ptr.Bool(false),
+		}
+	}
+
+	tests := []struct {
+		Name     string
+		Action   string
+		Status   string
+		Accepted bool
+		Closed   bool
+	}{
+		{"Approve", ActionApprove, StatusApproved, true, true},
+		{"Reject", ActionReject, StatusRejected, false, true},
+		{"Request changes", ActionRequestChanges, StatusChangesRequested, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			application := newApplication()
+			assert.NoError(t, Decide(application, tt.Action, "note", "admin", now))
+			assert.Equal(t, tt.Status, *application.Status)
+			assert.Equal(t, tt.Accepted, *application.Accepted)
+			assert.Equal(t, tt.Closed, *application.Closed)
+			assert.Equal(t, "note", *application.Message)
+			assert.Equal(t, "admin", *application.ReviewedBy)
+			assert.Equal(t, now, *application.ReviewedAt)
+		})
+	}
+
+	application := newApplication()
+	assert.Equal(t, ErrUnknownAction, Decide(application, "ignore", "", "admin", now))
+
+	application.Closed = ptr.Bool(true)
+	assert.Equal(t, ErrApplicationClosed, Decide(application, ActionApprove, "", "admin", now))
+}
+
+func TestQuery(t *testing.T) {
+	since := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, bson.M{"$or": bson.A{
+		bson.M{"status": StatusPending},
+		bson.M{"status": nil, "closed": false},
+	}}, Query(Filter{}))
+
+	assert.Equal(t, bson.M{
+		"status": StatusRejected,
+		"$and": bson.A{bson.M{"$or": bson.A{
+			bson.M{"full_name": bson.M{"$regex": `a\.b`, "$options": "i"}},
+			bson.M{"email": bson.M{"$regex": `a\.b`, "$options": "i"}},
+		}}},
+		"state":      "FL",
+		"created_at": bson.M{"$gte": since},
+	}, Query(Filter{Status: StatusRejected, Search: " a.b ", State: "fl", Since: &since}))
+}
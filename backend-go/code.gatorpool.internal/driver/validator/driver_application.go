@@ -94,6 +94,11 @@ func ValidateRequestDriverApply(request *driverEntities.RequestDriverApplyEntity
 		return errors.New("seats is required")
 	}
 
+	// The driver takes one seat, so there has to be room for at least one rider
+	if *request.Seats < 2 || *request.Seats > 15 {
+		return errors.New("seats must be between 2 and 15")
+	}
+
 	if request.Lugroom == nil {
 		return errors.New("lugroom is required")
 	}
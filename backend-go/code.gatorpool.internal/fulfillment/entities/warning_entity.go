@@ -28,11 +28,30 @@ type WarningEntity struct {
 	// The account that issued the warning
 	IssuedBy		*string		`json:"issued_by,omitempty" bson:"issued_by,omitempty"`
 
-	// The datetime the warning was resolved
+	// Whether the warning still counts against the user. It resolves on its own at ResolvesAt, or when
+	// an appeal is overturned. Bans without a ResolvesAt are permanent
 	Resolved		*bool		`json:"resolved,omitempty" bson:"resolved,omitempty"`
 	ResolvesAt		*time.Time	`json:"resolves_at,omitempty" bson:"resolves_at,omitempty"`
 	ResolvedAt		*time.Time	`json:"resolved_at,omitempty" bson:"resolved_at,omitempty"`
 
+	// The user contesting the warning, and what a moderator decided
+	Appeal			*WarningAppealEntity	`json:"appeal,omitempty" bson:"appeal,omitempty"`
+
 	CreatedAt		*time.Time	`json:"created_at,omitempty" bson:"created_at,omitempty"`
 	UpdatedAt		*time.Time	`json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+type WarningAppealEntity struct {
+	// Why the user thinks the warning is wrong
+	Reason			*string		`json:"reason,omitempty" bson:"reason,omitempty"`
+
+	// pending, upheld, overturned
+	Status			*string		`json:"status,omitempty" bson:"status,omitempty"`
+
+	SubmittedAt		*time.Time	`json:"submitted_at,omitempty" bson:"submitted_at,omitempty"`
+
+	// The moderator who decided the appeal, and what they told the user
+	DecidedBy		*string		`json:"decided_by,omitempty" bson:"decided_by,omitempty"`
+	DecidedAt		*time.Time	`json:"decided_at,omitempty" bson:"decided_at,omitempty"`
+	Note			*string		`json:"note,omitempty" bson:"note,omitempty"`
 }
\ No newline at end of file
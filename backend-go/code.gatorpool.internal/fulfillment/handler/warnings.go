@@ -0,0 +1,314 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/fulfillment/dispatch"
+	warningEntities "code.gatorpool.internal/fulfillment/entities"
+	"code.gatorpool.internal/fulfillment/warnings"
+	"code.gatorpool.internal/notify"
+	"code.gatorpool.internal/util"
+	"github.com/go-chi/chi"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type AppealWarningBody struct {
+	Reason string `json:"reason"`
+}
+
+type DecideAppealBody struct {
+	// Shown to the user with the decision
+	Note string `json:"note"`
+}
+
+type IssueWarningBody struct {
+	// warning or ban
+	Type   string `json:"type"`
+	Points int    `json:"points"`
+	Reason string `json:"reason"`
+
+	// How long it counts against the user. Warnings default to 30 days, bans without one are permanent
+	Days int `json:"days"`
+}
+
+// GetMyWarnings returns every warning the user has had and where they stand because of them
+func GetMyWarnings(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	userWarnings, err := warnings.ForUser(ctx, *account.UserUUID)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
+		"warnings": userWarnings,
+		"standing": warnings.Assess(userWarnings, time.Now()),
+		"success":  true,
+	})
+}
+
+// AppealWarning asks a moderator to take another look at one of the user's warnings
+func AppealWarning(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	var body AppealWarningBody
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid body",
+		})
+	}
+
+	reason, err := warnings.Text(body.Reason)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	warning, err := warnings.Find(ctx, chi.URLParam(req, "warning_uuid"))
+	if err == nil && (warning.UserUUID == nil || *warning.UserUUID != *account.UserUUID) {
+		// Other people's warnings don't exist as far as the caller is concerned
+		err = warnings.ErrWarningNotFound
+	}
+	if err == warnings.ErrWarningNotFound {
+		return util.JSONResponse(res, http.StatusNotFound, map[string]interface{}{
+			"error": err.Error(),
+		})
+	} else if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	err = warnings.Appeal(warning, reason, time.Now())
+	if err == nil {
+		err = warnings.Save(ctx, warning, nil)
+	}
+	if err == warnings.ErrNotActive || err == warnings.ErrAlreadyAppealed {
+		return util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
+			"error": err.Error(),
+		})
+	} else if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"warning": warning,
+		"success": true,
+	})
+}
+
+// ListAppeals returns a page of appeals waiting for a moderator, oldest first
+func ListAppeals(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Parse page number from query parameters
+	page := 1
+	if pageStr := req.URL.Query().Get("page"); pageStr != "" {
+		if parsedPage, err := strconv.Atoi(pageStr); err == nil && parsedPage > 0 {
+			page = parsedPage
+		}
+	}
+
+	appeals, total, err := warnings.PendingAppeals(ctx, page)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"warnings":    appeals,
+		"currentPage": page,
+		"totalPages":  int(math.Ceil(float64(total) / float64(warnings.AppealsPerPage))),
+		"success":     true,
+	})
+}
+
+// UpholdAppeal keeps the warning in place
+func UpholdAppeal(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+	return decideAppeal(req, res, ctx, warnings.ActionUphold)
+}
+
+// OverturnAppeal resolves the warning so it no longer counts against the user
+func OverturnAppeal(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+	return decideAppeal(req, res, ctx, warnings.ActionOverturn)
+}
+
+// decideAppeal records the moderator's decision on the appeal and lets the user know
+func decideAppeal(req *http.Request, res http.ResponseWriter, ctx context.Context, action string) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	var body DecideAppealBody
+	if req.ContentLength != 0 {
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+				"error": "invalid body",
+			})
+		}
+	}
+
+	note, err := warnings.Text(body.Note)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	warning, err := warnings.Find(ctx, chi.URLParam(req, "warning_uuid"))
+	if err == warnings.ErrWarningNotFound {
+		return util.JSONResponse(res, http.StatusNotFound, map[string]interface{}{
+			"error": err.Error(),
+		})
+	} else if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	pending := warnings.AppealPending
+	err = warnings.Decide(warning, action, note, *account.UserUUID, time.Now())
+	if err == nil {
+		err = warnings.Save(ctx, warning, &pending)
+	}
+	if err == warnings.ErrNoPendingAppeal {
+		return util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
+			"error": err.Error(),
+		})
+	} else if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	eventType := notify.EventAppealUpheld
+	if action == warnings.ActionOverturn {
+		eventType = notify.EventAppealOverturned
+	}
+
+	event := notify.Event{
+		Type:     eventType,
+		UserUUID: *warning.UserUUID,
+		Data:     map[string]string{"TYPE": warningType(warning), "NOTE": note},
+	}
+	if err := notify.Enqueue(ctx, event); err != nil {
+		fmt.Println("Error queueing "+eventType+" notification: ", err)
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"warning": warning,
+		"success": true,
+	})
+}
+
+// IssueWarning gives the account a warning or bans it
+func IssueWarning(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	userUUID := chi.URLParam(req, "user_uuid")
+
+	var body IssueWarningBody
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid body",
+		})
+	}
+
+	if body.Days < 0 {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "days can't be negative",
+		})
+	}
+
+	warning, err := warnings.New(userUUID, body.Type, body.Points, body.Reason, time.Hour*24*time.Duration(body.Days), *account.UserUUID, time.Now())
+	if err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	var target *accountEntities.AccountEntity
+	err = datastores.GetMongoDatabase(ctx).Collection(datastores.Accounts).FindOne(ctx, bson.M{"user_uuid": userUUID}).Decode(&target)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return util.JSONResponse(res, http.StatusNotFound, map[string]interface{}{
+				"error": "account not found",
+			})
+		}
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if _, err := dispatch.DispatchWarningEvent(warning, userUUID); err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	event := notify.Event{
+		Type:     notify.EventWarningIssued,
+		UserUUID: userUUID,
+		Data:     map[string]string{"TYPE": warningType(warning), "REASON": *warning.Reason},
+	}
+	if err := notify.Enqueue(ctx, event); err != nil {
+		fmt.Println("Error queueing "+notify.EventWarningIssued+" notification: ", err)
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"warning": warning,
+		"success": true,
+	})
+}
+
+// warningType is the warning's type for notifications
+func warningType(warning *warningEntities.WarningEntity) string {
+	if warning.Type != nil && *warning.Type == warnings.TypeBan {
+		return warnings.TypeBan
+	}
+	return warnings.TypeWarning
+}
@@ -0,0 +1,336 @@
+package warnings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	warningEntities "code.gatorpool.internal/fulfillment/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/pborman/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	TypeWarning = "warning"
+	TypeBan     = "ban"
+
+	AppealPending    = "pending"
+	AppealUpheld     = "upheld"
+	AppealOverturned = "overturned"
+
+	ActionUphold   = "uphold"
+	ActionOverturn = "overturn"
+
+	// Users with this many points from active warnings can't post trips
+	BlockingPoints = 3
+
+	// How long a warning counts against the user unless it says otherwise
+	DefaultDuration = time.Hour * 24 * 30
+
+	// Longest appeal or moderator note, in characters
+	MaxTextLength = 1000
+
+	AppealsPerPage = 25
+)
+
+var (
+	ErrWarningNotFound = errors.New("warning not found")
+	ErrUnknownType     = errors.New("unknown warning type")
+	ErrInvalidPoints   = errors.New("warnings have to be worth at least one point")
+	ErrReasonRequired  = errors.New("a reason is required")
+	ErrTextTooLong     = errors.New("text is too long")
+	ErrNotActive       = errors.New("warning no longer counts against you")
+	ErrAlreadyAppealed = errors.New("warning has already been appealed")
+	ErrNoPendingAppeal = errors.New("warning has no appeal waiting for a decision")
+	ErrUnknownAction   = errors.New("unknown appeal action")
+	ErrAccountBanned   = errors.New("account is banned")
+	ErrTooManyWarnings = errors.New("you have too many warnings to post trips right now")
+)
+
+// Standing is where the user stands with their active warnings
+type Standing struct {
+	Points int `json:"points"`
+
+	// The ban in force, if there is one
+	Ban *warningEntities.WarningEntity `json:"ban"`
+
+	CanCreateTrip bool `json:"can_create_trip"`
+}
+
+// Active reports whether the warning still counts against the user
+func Active(warning *warningEntities.WarningEntity, now time.Time) bool {
+	if warning.Resolved != nil && *warning.Resolved {
+		return false
+	}
+	return warning.ResolvesAt == nil || now.Before(*warning.ResolvesAt)
+}
+
+// Assess works out the user's standing from their warnings
+func Assess(warnings []*warningEntities.WarningEntity, now time.Time) Standing {
+	standing := Standing{}
+	for _, warning := range warnings {
+		if !Active(warning, now) {
+			continue
+		}
+
+		if warning.Type != nil && *warning.Type == TypeBan {
+			// A permanent ban, or the one that lasts longest, is the one to show
+			if standing.Ban == nil || warning.ResolvesAt == nil || (standing.Ban.ResolvesAt != nil && warning.ResolvesAt.After(*standing.Ban.ResolvesAt)) {
+				standing.Ban = warning
+			}
+			continue
+		}
+
+		if warning.Points != nil {
+			standing.Points += *warning.Points
+		}
+	}
+
+	standing.CanCreateTrip = standing.Ban == nil && standing.Points < BlockingPoints
+	return standing
+}
+
+// BanError describes the ban to the user
+func BanError(ban *warningEntities.WarningEntity) error {
+	if ban.ResolvesAt == nil {
+		return ErrAccountBanned
+	}
+	return fmt.Errorf("%w until %s", ErrAccountBanned, ban.ResolvesAt.UTC().Format(time.RFC3339))
+}
+
+// Text trims an appeal reason or moderator note and checks it isn't empty or too long
+func Text(text string) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", ErrReasonRequired
+	}
+	if utf8.RuneCountInString(text) > MaxTextLength {
+		return "", ErrTextTooLong
+	}
+	return text, nil
+}
+
+// New builds a warning or ban against the user. A ban with no duration is permanent
+func New(userUUID string, warningType string, points int, reason string, duration time.Duration, issuedBy string, now time.Time) (*warningEntities.WarningEntity, error) {
+	if warningType != TypeWarning && warningType != TypeBan {
+		return nil, ErrUnknownType
+	}
+
+	if warningType == TypeWarning && points < 1 {
+		return nil, ErrInvalidPoints
+	}
+
+	reason, err := Text(reason)
+	if err != nil {
+		return nil, err
+	}
+
+	warning := &warningEntities.WarningEntity{
+		WarningUUID: ptr.String(uuid.NewRandom().String()),
+		UserUUID:    ptr.String(userUUID),
+		Type:        ptr.String(warningType),
+		Points:      ptr.Int(points),
+		IssuedAt:    ptr.Time(now),
+		Reason:      ptr.String(reason),
+		IssuedBy:    ptr.String(issuedBy),
+		Resolved:    ptr.Bool(false),
+		CreatedAt:   ptr.Time(now),
+		UpdatedAt:   ptr.Time(now),
+	}
+
+	if warningType == TypeWarning && duration <= 0 {
+		duration = DefaultDuration
+	}
+	if duration > 0 {
+		warning.ResolvesAt = ptr.Time(now.Add(duration))
+	}
+
+	return warning, nil
+}
+
+// Appeal contests the warning. Each warning can be appealed once, while it still counts
+func Appeal(warning *warningEntities.WarningEntity, reason string, now time.Time) error {
+	if !Active(warning, now) {
+		return ErrNotActive
+	}
+	if warning.Appeal != nil {
+		return ErrAlreadyAppealed
+	}
+
+	warning.Appeal = &warningEntities.WarningAppealEntity{
+		Reason:      ptr.String(reason),
+		Status:      ptr.String(AppealPending),
+		SubmittedAt: ptr.Time(now),
+	}
+	warning.UpdatedAt = ptr.Time(now)
+	return nil
+}
+
+// Decide records the moderator's decision on the appeal. Overturning it resolves the warning
+func Decide(warning *warningEntities.WarningEntity, action string, note string, moderatorUUID string, now time.Time) error {
+	if warning.Appeal == nil || warning.Appeal.Status == nil || *warning.Appeal.Status != AppealPending {
+		return ErrNoPendingAppeal
+	}
+
+	switch action {
+	case ActionUphold:
+		warning.Appeal.Status = ptr.String(AppealUpheld)
+	case ActionOverturn:
+		warning.Appeal.Status = ptr.String(AppealOverturned)
+		warning.Resolved = ptr.Bool(true)
+		warning.ResolvedAt = ptr.Time(now)
+	default:
+		return ErrUnknownAction
+	}
+
+	warning.Appeal.DecidedBy = ptr.String(moderatorUUID)
+	warning.Appeal.DecidedAt = ptr.Time(now)
+	warning.Appeal.Note = ptr.String(note)
+	warning.UpdatedAt = ptr.Time(now)
+	return nil
+}
+
+// ForUser returns every warning the user has had, newest first
+func ForUser(ctx context.Context, userUUID string) ([]*warningEntities.WarningEntity, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "issued_at", Value: -1}})
+
+	cursor, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Warnings).Find(ctx, bson.M{"user_uuid": userUUID}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings := []*warningEntities.WarningEntity{}
+	if err := cursor.All(ctx, &warnings); err != nil {
+		return nil, err
+	}
+
+	return warnings, nil
+}
+
+// StandingOf loads the user's active warnings and works out their standing
+func StandingOf(ctx context.Context, userUUID string, now time.Time) (Standing, error) {
+	cursor, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Warnings).Find(ctx, bson.M{
+		"user_uuid": userUUID,
+		"resolved":  bson.M{"$ne": true},
+	})
+	if err != nil {
+		return Standing{}, err
+	}
+
+	warnings := []*warningEntities.WarningEntity{}
+	if err := cursor.All(ctx, &warnings); err != nil {
+		return Standing{}, err
+	}
+
+	return Assess(warnings, now), nil
+}
+
+// ActiveBan returns the user's ban if they're banned, or nil. It only loads bans, since it runs on
+// every request.
+func ActiveBan(ctx context.Context, userUUID string, now time.Time) (*warningEntities.WarningEntity, error) {
+	cursor, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Warnings).Find(ctx, bson.M{
+		"user_uuid": userUUID,
+		"resolved":  bson.M{"$ne": true},
+		"type":      TypeBan,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bans := []*warningEntities.WarningEntity{}
+	if err := cursor.All(ctx, &bans); err != nil {
+		return nil, err
+	}
+
+	return Assess(bans, now).Ban, nil
+}
+
+// Find looks up a warning
+func Find(ctx context.Context, warningUUID string) (*warningEntities.WarningEntity, error) {
+	var warning *warningEntities.WarningEntity
+	err := datastores.GetMongoDatabase(ctx).Collection(datastores.Warnings).FindOne(ctx, bson.M{"warning_uuid": warningUUID}).Decode(&warning)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrWarningNotFound
+	}
+	return warning, err
+}
+
+// Save writes the appeal and resolution, as long as the appeal hasn't moved on since it was read
+func Save(ctx context.Context, warning *warningEntities.WarningEntity, fromAppealStatus *string) error {
+	filter := bson.M{"warning_uuid": warning.WarningUUID, "appeal": nil}
+	if fromAppealStatus != nil {
+		filter = bson.M{"warning_uuid": warning.WarningUUID, "appeal.status": *fromAppealStatus}
+	}
+
+	result, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Warnings).UpdateOne(ctx, filter, bson.M{"$set": bson.M{
+		"appeal":      warning.Appeal,
+		"resolved":    warning.Resolved,
+		"resolved_at": warning.ResolvedAt,
+		"updated_at":  warning.UpdatedAt,
+	}})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		if fromAppealStatus == nil {
+			return ErrAlreadyAppealed
+		}
+		return ErrNoPendingAppeal
+	}
+
+	return nil
+}
+
+// PendingAppeals returns a page of appeals waiting for a moderator, oldest first, and how many
+// there are in total
+func PendingAppeals(ctx context.Context, page int) ([]*warningEntities.WarningEntity, int64, error) {
+	warningsCollection := datastores.GetMongoDatabase(ctx).Collection(datastores.Warnings)
+
+	query := bson.M{"appeal.status": AppealPending}
+
+	total, err := warningsCollection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "appeal.submitted_at", Value: 1}}).
+		SetSkip(int64((page - 1) * AppealsPerPage)).
+		SetLimit(AppealsPerPage)
+
+	cursor, err := warningsCollection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	warnings := []*warningEntities.WarningEntity{}
+	if err := cursor.All(ctx, &warnings); err != nil {
+		return nil, 0, err
+	}
+
+	return warnings, total, nil
+}
+
+// ResolveExpired resolves every warning and ban whose time is up, and returns how many it resolved
+func ResolveExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Warnings).UpdateMany(ctx, bson.M{
+		"resolved":    bson.M{"$ne": true},
+		"resolves_at": bson.M{"$lte": now},
+	}, bson.M{"$set": bson.M{
+		"resolved":    true,
+		"resolved_at": now,
+		"updated_at":  now,
+	}})
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
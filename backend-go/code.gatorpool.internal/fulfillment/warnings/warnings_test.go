@@ -0,0 +1,218 @@
+package warnings
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	warningEntities "code.gatorpool.internal/fulfillment/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActive(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		Name     string
+		Warning  *warningEntities.WarningEntity
+		Expected bool
+	}{
+		{"Unresolved", &warningEntities.WarningEntity{Resolved: ptr.Bool(false), ResolvesAt: ptr.Time(now.Add(time.Hour))}, true},
+		{"Permanent", &warningEntities.WarningEntity{Resolved: ptr.Bool(false)}, true},
+		{"Resolved", &warningEntities.WarningEntity{Resolved: ptr.Bool(true), ResolvesAt: ptr.Time(now.Add(time.Hour))}, false},
+		{"Expired but not resolved yet", &warningEntities.WarningEntity{Resolved: ptr.Bool(false), ResolvesAt: ptr.Time(now.Add(-time.Minute))}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, Active(tt.Warning, now))
+		})
+	}
+}
+
+func TestAssess(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	warning := func(points int) *warningEntities.WarningEntity {
+		return &warningEntities.WarningEntity{Type: ptr.String(TypeWarning), Points: ptr.Int(points), Resolved: ptr.Bool(false), ResolvesAt: ptr.Time(now.Add(time.Hour))}
+	}
+	ban := func(resolvesAt *time.Time) *warningEntities.WarningEntity {
+		return &warningEntities.WarningEntity{Type: ptr.String(TypeBan), Points: ptr.Int(0), Resolved: ptr.Bool(false), ResolvesAt: resolvesAt}
+	}
+
+	resolved := warning(5)
+	resolved.Resolved = ptr.Bool(true)
+
+	shortBan := ban(ptr.Time(now.Add(time.Hour)))
+	longBan := ban(ptr.Time(now.Add(time.Hour * 48)))
+	permanentBan := ban(nil)
+
+	tests := []struct {
+		Name     string
+		Warnings []*warningEntities.WarningEntity
+		Expected Standing
+	}{
+		{"No warnings", nil, Standing{CanCreateTrip: true}},
+		{"Under the limit", []*warningEntities.WarningEntity{warning(1), warning(1)}, Standing{Points: 2, CanCreateTrip: true}},
+		{"At the limit", []*warningEntities.WarningEntity{warning(1), warning(2)}, Standing{Points: 3, CanCreateTrip: false}},
+		{"Resolved warnings don't count", []*warningEntities.WarningEntity{warning(1), resolved}, Standing{Points: 1, CanCreateTrip: true}},
+		{"Banned", []*warningEntities.WarningEntity{shortBan}, Standing{Ban: shortBan, CanCreateTrip: false}},
+		{"Longest ban wins", []*warningEntities.WarningEntity{shortBan, longBan}, Standing{Ban: longBan, CanCreateTrip: false}},
+		{"Permanent ban wins", []*warningEntities.WarningEntity{permanentBan, longBan}, Standing{Ban: permanentBan, CanCreateTrip: false}},
+		{"Expired ban", []*warningEntities.WarningEntity{ban(ptr.Time(now.Add(-time.Hour)))}, Standing{CanCreateTrip: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, Assess(tt.Warnings, now))
+		})
+	}
+}
+
+func TestBanError(t *testing.T) {
+	resolvesAt := time.Date(2025, 3, 8, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, ErrAccountBanned, BanError(&warningEntities.WarningEntity{}))
+
+	err := BanError(&warningEntities.WarningEntity{ResolvesAt: &resolvesAt})
+	assert.ErrorIs(t, err, ErrAccountBanned)
+	assert.Equal(t, "account is banned until 2025-03-08T12:00:00Z", err.Error())
+}
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Text     string
+		Expected string
+		Err      error
+	}{
+		{"Trimmed", "  I was stuck in traffic ", "I was stuck in traffic", nil},
+		{"Empty", "   ", "", ErrReasonRequired},
+		{"Too long", strings.Repeat("a", MaxTextLength+1), "", ErrTextTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			text, err := Text(tt.Text)
+			assert.Equal(t, tt.Err, err)
+			assert.Equal(t, tt.Expected, text)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		Name       string
+		Type       string
+		Points     int
+		Reason     string
+		Duration   time.Duration
+		ResolvesAt *time.Time
+		Err        error
+	}{
+		{"Warning with the default duration", TypeWarning, 1, "No show", 0, ptr.Time(now.Add(DefaultDuration)), nil},
+		{"Warning with a duration", TypeWarning, 2, "No show", time.Hour * 24, ptr.Time(now.Add(time.Hour * 24)), nil},
+		{"Temporary ban", TypeBan, 0, "Harassment", time.Hour * 24 * 7, ptr.Time(now.Add(time.Hour * 24 * 7)), nil},
+		{"Permanent ban", TypeBan, 0, "Harassment", 0, nil, nil},
+		{"Warning without points", TypeWarning, 0, "No show", 0, nil, ErrInvalidPoints},
+		{"Unknown type", "strike", 1, "No show", 0, nil, ErrUnknownType},
+		{"No reason", TypeWarning, 1, " ", 0, nil, ErrReasonRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			warning, err := New("user", tt.Type, tt.Points, tt.Reason, tt.Duration, "moderator", now)
+			assert.Equal(t, tt.Err, err)
+			if err != nil {
+				assert.Nil(t, warning)
+				return
+			}
+			assert.Equal(t, tt.Type, *warning.Type)
+			assert.Equal(t, tt.ResolvesAt, warning.ResolvesAt)
+			assert.False(t, *warning.Resolved)
+			assert.Equal(t, "moderator", *warning.IssuedBy)
+		})
+	}
+}
+
+func TestAppeal(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	active := func() *warningEntities.WarningEntity {
+		return &warningEntities.WarningEntity{Resolved: ptr.Bool(false), ResolvesAt: ptr.Time(now.Add(time.Hour))}
+	}
+
+	appealed := active()
+	appealed.Appeal = &warningEntities.WarningAppealEntity{Status: ptr.String(AppealUpheld)}
+
+	resolved := active()
+	resolved.Resolved = ptr.Bool(true)
+
+	tests := []struct {
+		Name    string
+		Warning *warningEntities.WarningEntity
+		Err     error
+	}{
+		{"Active warning", active(), nil},
+		{"Already appealed", appealed, ErrAlreadyAppealed},
+		{"Resolved", resolved, ErrNotActive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			err := Appeal(tt.Warning, "I was stuck in traffic", now)
+			assert.Equal(t, tt.Err, err)
+			if err == nil {
+				assert.Equal(t, AppealPending, *tt.Warning.Appeal.Status)
+				assert.Equal(t, "I was stuck in traffic", *tt.Warning.Appeal.Reason)
+			}
+		})
+	}
+}
+
+func TestDecide(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	pending := func() *warningEntities.WarningEntity {
+		return &warningEntities.WarningEntity{
+			Resolved: ptr.Bool(false),
+			Appeal:   &warningEntities.WarningAppealEntity{Status: ptr.String(AppealPending)},
+		}
+	}
+
+	tests := []struct {
+		Name     string
+		Warning  *warningEntities.WarningEntity
+		Action   string
+		Status   string
+		Resolved bool
+		Err      error
+	}{
+		{"Uphold", pending(), ActionUphold, AppealUpheld, false, nil},
+		{"Overturn", pending(), ActionOverturn, AppealOverturned, true, nil},
+		{"Unknown action", pending(), "ignore", AppealPending, false, ErrUnknownAction},
+		{"Not appealed", &warningEntities.WarningEntity{Resolved: ptr.Bool(false)}, ActionUphold, "", false, ErrNoPendingAppeal},
+		{"Already decided", &warningEntities.WarningEntity{
+			Resolved: ptr.Bool(false),
+			Appeal:   &warningEntities.WarningAppealEntity{Status: ptr.String(AppealUpheld)},
+		}, ActionOverturn, AppealUpheld, false, ErrNoPendingAppeal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			err := Decide(tt.Warning, tt.Action, "Checked the trip", "moderator", now)
+			assert.Equal(t, tt.Err, err)
+			assert.Equal(t, tt.Resolved, *tt.Warning.Resolved)
+			if tt.Warning.Appeal != nil {
+				assert.Equal(t, tt.Status, *tt.Warning.Appeal.Status)
+			}
+			if err == nil {
+				assert.Equal(t, "moderator", *tt.Warning.Appeal.DecidedBy)
+				assert.Equal(t, now, *tt.Warning.Appeal.DecidedAt)
+			}
+		})
+	}
+}
@@ -21,21 +21,31 @@ func HashPassword(password *string) (*string, *int64, error) {
 		return nil, nil, errors.New("password does not meet the required criteria")
 	}
 
-	// Hash the password using bcrypt
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(*password), bcrypt.DefaultCost)
+	return HashSecret(password)
+}
+
+// HashSecret hashes and peppers any secret the same way as a password, without the password rules.
+// It's checked with VerifyPassword.
+func HashSecret(secret *string) (*string, *int64, error) {
+	if secret == nil || *secret == "" {
+		return nil, nil, errors.New("secret cannot be empty")
+	}
+
+	// Hash the secret using bcrypt
+	hashedSecret, err := bcrypt.GenerateFromPassword([]byte(*secret), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	encryptionVersion := secrets.SymmetricKeyValueLatestVersion
 
-	// Pepper the hashed password (encrypt it)
-	pepperedPassword, err := EncryptWithPepper(hashedPassword, ptr.Int64(int64(encryptionVersion)))
+	// Pepper the hashed secret (encrypt it)
+	pepperedSecret, err := EncryptWithPepper(hashedSecret, ptr.Int64(int64(encryptionVersion)))
 	if err != nil {
 		return nil, nil, err
 	}
 
-	return &pepperedPassword, ptr.Int64(int64(encryptionVersion)), nil
+	return &pepperedSecret, ptr.Int64(int64(encryptionVersion)), nil
 }
 
 // VerifyPassword verifies a password against a peppered bcrypt hash.
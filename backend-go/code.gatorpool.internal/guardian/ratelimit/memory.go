@@ -0,0 +1,61 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// How many takes between sweeps of buckets that have filled back up
+const sweepEvery = 1000
+
+// MemoryStore keeps buckets in this server only, so with more than one server each of them allows
+// the full limit
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	takes   int
+}
+
+type memoryBucket struct {
+	Bucket
+
+	// Once the bucket is full again it's no different from one that was never used
+	FullAt time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*memoryBucket{}}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.takes++
+	if s.takes%sweepEvery == 0 {
+		s.sweep(now)
+	}
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{}
+	}
+
+	allowed, retryAfter := Take(&bucket.Bucket, limit, now)
+	if allowed {
+		bucket.FullAt = now.Add(time.Duration((float64(limit.Burst) - bucket.Tokens) * float64(limit.Every)))
+		s.buckets[key] = bucket
+	}
+
+	return allowed, retryAfter, nil
+}
+
+// sweep drops buckets that have filled back up
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, bucket := range s.buckets {
+		if !now.Before(bucket.FullAt) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// How many times Take tries again when another request changed the bucket first
+const maxAttempts = 3
+
+// MongoStore keeps buckets in Mongo, so every server shares the same limits
+type MongoStore struct{}
+
+// bucketDocument is a bucket as it's stored. Version changes on every write, so two servers can't
+// both spend the last token.
+type bucketDocument struct {
+	Key       string    `bson:"key"`
+	Tokens    float64   `bson:"tokens"`
+	UpdatedAt time.Time `bson:"updated_at"`
+	Version   int64     `bson:"version"`
+
+	// Buckets are deleted once they've filled back up
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+func NewMongoStore() *MongoStore {
+	return &MongoStore{}
+}
+
+func (s *MongoStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (bool, time.Duration, error) {
+	bucketsCollection := datastores.GetMongoDatabase(ctx).Collection(datastores.RateLimits)
+
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		var stored bucketDocument
+		err := bucketsCollection.FindOne(ctx, bson.M{"key": key}).Decode(&stored)
+		found := err == nil
+		if err != nil && err != mongo.ErrNoDocuments {
+			return false, 0, err
+		}
+
+		bucket := Bucket{Tokens: stored.Tokens, UpdatedAt: stored.UpdatedAt}
+		allowed, retryAfter := Take(&bucket, limit, now)
+		if !allowed {
+			return false, retryAfter, nil
+		}
+
+		expiresAt := now.Add(time.Duration((float64(limit.Burst) - bucket.Tokens) * float64(limit.Every)))
+
+		if !found {
+			_, err := bucketsCollection.InsertOne(ctx, bucketDocument{
+				Key:       key,
+				Tokens:    bucket.Tokens,
+				UpdatedAt: bucket.UpdatedAt,
+				Version:   1,
+				ExpiresAt: expiresAt,
+			})
+			if mongo.IsDuplicateKeyError(err) {
+				continue
+			}
+			return err == nil, 0, err
+		}
+
+		result, err := bucketsCollection.UpdateOne(ctx, bson.M{"key": key, "version": stored.Version}, bson.M{
+			"$set": bson.M{
+				"tokens":     bucket.Tokens,
+				"updated_at": bucket.UpdatedAt,
+				"expires_at": expiresAt,
+			},
+			"$inc": bson.M{"version": 1},
+		})
+		if err != nil {
+			return false, 0, err
+		}
+		if result.MatchedCount == 1 {
+			return true, 0, nil
+		}
+	}
+
+	// Lots of requests for the same key at once is what the limit is there to stop
+	return false, limit.Every, nil
+}
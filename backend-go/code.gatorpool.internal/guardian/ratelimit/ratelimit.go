@@ -0,0 +1,208 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.gatorpool.internal/util"
+)
+
+// Limit is a token bucket: up to Burst requests at once, with one more allowed every Every
+type Limit struct {
+	Burst int
+	Every time.Duration
+}
+
+// Key is what requests are counted by, e.g. the IP address they came from
+type Key struct {
+	Name string
+
+	// The value for the request, empty if the request doesn't have one
+	Of func(req *http.Request) string
+}
+
+// Rule limits requests with the same value for the key
+type Rule struct {
+	Key   Key
+	Limit Limit
+}
+
+// Store keeps the buckets. Take spends a token from the key's bucket if there is one, and otherwise
+// says how long until there will be
+type Store interface {
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (bool, time.Duration, error)
+}
+
+var (
+	ByIP       = Key{Name: "ip", Of: ClientIP}
+	ByUsername = Key{Name: "username", Of: header("X-GatorPool-Username")}
+	ByDevice   = Key{Name: "device", Of: header("X-GatorPool-Device-Id")}
+
+	// For routes that take the username in the JSON body, like the token endpoint. It shares buckets
+	// with ByUsername.
+	ByBodyUsername = Key{Name: "username", Of: bodyField("username")}
+)
+
+// Bodies bigger than this aren't read for a key
+const maxKeyBody = 1 << 16
+
+// PerIP limits requests from one IP address
+func PerIP(burst int, every time.Duration) Rule {
+	return Rule{Key: ByIP, Limit: Limit{Burst: burst, Every: every}}
+}
+
+// PerUsername limits requests for one account, wherever they come from
+func PerUsername(burst int, every time.Duration) Rule {
+	return Rule{Key: ByUsername, Limit: Limit{Burst: burst, Every: every}}
+}
+
+// PerBodyUsername limits requests for one account, by the username in the body
+func PerBodyUsername(burst int, every time.Duration) Rule {
+	return Rule{Key: ByBodyUsername, Limit: Limit{Burst: burst, Every: every}}
+}
+
+// PerDevice limits requests from one device
+func PerDevice(burst int, every time.Duration) Rule {
+	return Rule{Key: ByDevice, Limit: Limit{Burst: burst, Every: every}}
+}
+
+// Bucket is how many requests are left for a key, as of UpdatedAt
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+}
+
+// Take refills the bucket for the time since it was last used and spends a token. A bucket that has
+// never been used starts full. When it's empty the bucket is left alone and Take returns how long
+// until the next token.
+func Take(bucket *Bucket, limit Limit, now time.Time) (bool, time.Duration) {
+	tokens := float64(limit.Burst)
+	if !bucket.UpdatedAt.IsZero() {
+		elapsed := now.Sub(bucket.UpdatedAt)
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		tokens = math.Min(float64(limit.Burst), bucket.Tokens+float64(elapsed)/float64(limit.Every))
+	}
+
+	if tokens < 1 {
+		return false, time.Duration((1 - tokens) * float64(limit.Every))
+	}
+
+	bucket.Tokens = tokens - 1
+	bucket.UpdatedAt = now
+	return true, 0
+}
+
+// Middleware limits requests to the route. Each rule counts requests by its own key, and a request
+// has to get past all of them. Rules whose key the request doesn't have are skipped.
+func Middleware(store Store, route string, rules ...Rule) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			now := time.Now()
+
+			for _, rule := range rules {
+				value := rule.Key.Of(req)
+				if value == "" {
+					continue
+				}
+
+				allowed, retryAfter, err := store.Take(req.Context(), bucketKey(route, rule.Key.Name, value), rule.Limit, now)
+				if err != nil {
+					// Rather let a few extra requests through than lock everyone out
+					fmt.Println("Error checking rate limit: ", err)
+					continue
+				}
+
+				if !allowed {
+					seconds := int(math.Ceil(retryAfter.Seconds()))
+					if seconds < 1 {
+						seconds = 1
+					}
+
+					res.Header().Set("Retry-After", strconv.Itoa(seconds))
+					util.JSONResponse(res, http.StatusTooManyRequests, map[string]interface{}{
+						"error":       "too many requests, try again later",
+						"retry_after": seconds,
+					})
+					return
+				}
+			}
+
+			next.ServeHTTP(res, req)
+		})
+	}
+}
+
+// ClientIP is the address the request came from. App Engine puts the client's address in
+// X-Appengine-User-Ip and strips it from incoming requests, so it can't be spoofed.
+func ClientIP(req *http.Request) string {
+	if ip := req.Header.Get("X-Appengine-User-Ip"); ip != "" {
+		return ip
+	}
+
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return ip
+}
+
+// header reads a header, ignoring case and surrounding spaces so they can't be used to get a new bucket
+func header(name string) func(req *http.Request) string {
+	return func(req *http.Request) string {
+		return strings.ToLower(strings.TrimSpace(req.Header.Get(name)))
+	}
+}
+
+// bodyField reads a string field from a JSON body, the same way header does, and puts the body back
+// for the handler
+func bodyField(field string) func(req *http.Request) string {
+	return func(req *http.Request) string {
+		if req.Body == nil {
+			return ""
+		}
+
+		// Whatever was read goes back in front of the rest, so a big body still reaches the handler whole
+		raw, err := io.ReadAll(io.LimitReader(req.Body, maxKeyBody))
+		req.Body = readCloser{io.MultiReader(bytes.NewReader(raw), req.Body), req.Body}
+		if err != nil {
+			return ""
+		}
+
+		// Padding the body out can't be a way around the limit, so every oversized body shares a bucket
+		if len(raw) == maxKeyBody {
+			return "oversized body"
+		}
+
+		var body map[string]interface{}
+		if err := json.Unmarshal(raw, &body); err != nil {
+			return ""
+		}
+
+		value, _ := body[field].(string)
+		return strings.ToLower(strings.TrimSpace(value))
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// bucketKey names the bucket. The value is hashed so usernames aren't stored alongside the counts.
+func bucketKey(route string, key string, value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return route + ":" + key + ":" + hex.EncodeToString(sum[:])
+}
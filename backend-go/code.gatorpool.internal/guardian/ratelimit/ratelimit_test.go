@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTake(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	limit := Limit{Burst: 3, Every: time.Minute}
+
+	tests := []struct {
+		Name       string
+		Bucket     Bucket
+		Now        time.Time
+		Allowed    bool
+		Tokens     float64
+		RetryAfter time.Duration
+	}{
+		{"Never used", Bucket{}, now, true, 2, 0},
+		{"Last token", Bucket{Tokens: 1, UpdatedAt: now}, now, true, 0, 0},
+		{"Empty", Bucket{Tokens: 0, UpdatedAt: now}, now, false, 0, time.Minute},
+		{"Partly refilled", Bucket{Tokens: 0, UpdatedAt: now}, now.Add(time.Second * 15), false, 0, time.Second * 45},
+		{"Refilled", Bucket{Tokens: 0, UpdatedAt: now}, now.Add(time.Minute), true, 0, 0},
+		{"Refills up to the burst", Bucket{Tokens: 0, UpdatedAt: now}, now.Add(time.Hour), true, 2, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			bucket := tt.Bucket
+			allowed, retryAfter := Take(&bucket, limit, tt.Now)
+			assert.Equal(t, tt.Allowed, allowed)
+			assert.Equal(t, tt.RetryAfter, retryAfter)
+			assert.InDelta(t, tt.Tokens, bucket.Tokens, 0.0001)
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		Name       string
+		RemoteAddr string
+		Header     string
+		Expected   string
+	}{
+		{"Remote address", "203.0.113.7:52100", "", "203.0.113.7"},
+		{"App Engine header", "169.254.1.1:52100", "198.51.100.4", "198.51.100.4"},
+		{"No port", "203.0.113.7", "", "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/oauth2/token", nil)
+			req.RemoteAddr = tt.RemoteAddr
+			if tt.Header != "" {
+				req.Header.Set("X-Appengine-User-Ip", tt.Header)
+			}
+			assert.Equal(t, tt.Expected, ClientIP(req))
+		})
+	}
+}
+
+func TestByBodyUsername(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Body     string
+		Expected string
+	}{
+		{"Username", `{"username": "Albert@UFL.edu ", "password": "x"}`, "albert@ufl.edu"},
+		{"No username", `{"password": "x"}`, ""},
+		{"Not a string", `{"username": 5}`, ""},
+		{"Not JSON", `username=albert`, ""},
+		{"Too big to read", `{"username": "albert@ufl.edu", "padding": "` + strings.Repeat("x", maxKeyBody) + `"}`, "oversized body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/oauth2/token", strings.NewReader(tt.Body))
+			req.Header.Set("X-GatorPool-Username", "someone-else@ufl.edu")
+			assert.Equal(t, tt.Expected, ByBodyUsername.Of(req))
+
+			// The handler still gets the whole body
+			body, err := io.ReadAll(req.Body)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.Body, string(body))
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	handler := Middleware(NewMemoryStore(), "test",
+		PerIP(10, time.Minute),
+		PerUsername(2, time.Minute),
+	)(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		res.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(username string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/oauth2/token", nil)
+		req.RemoteAddr = "203.0.113.7:52100"
+		req.Header.Set("X-GatorPool-Username", username)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, request("gator@ufl.edu").Code)
+	assert.Equal(t, http.StatusOK, request("Gator@UFL.edu ").Code)
+
+	limited := request("gator@ufl.edu")
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code)
+	assert.Equal(t, "60", limited.Header().Get("Retry-After"))
+
+	// Someone else from the same address still has their own username bucket
+	assert.Equal(t, http.StatusOK, request("alligator@ufl.edu").Code)
+}
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	accountModel "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/account/roles"
 	riderEntities "code.gatorpool.internal/rider/entities"
 	datastores "code.gatorpool.internal/datastores/mongo"
 
@@ -28,6 +29,9 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// How long a refresh token lasts before the user has to sign in again
+const RefreshTokenLifetime = time.Hour * 24 * 28
+
 // Token, TokenID, RefreshToken, TokenVersion, RefreshTokenVersion, Error
 func GenerateOAuth2Token(req *http.Request, res http.ResponseWriter, ctx context.Context) (*string, *string, *string, *int32, *int32, error) {
 	logger := log.NewWithOptions(os.Stderr, log.Options{
@@ -318,6 +322,14 @@ func VerifyOAuthToken(next http.Handler) http.Handler {
             })
             return
         }
+        // Banned accounts can sign in, but can only use the routes that let them appeal
+        if status, err := checkBan(newCtx); err != nil {
+            util.JSONResponse(res, status, map[string]interface{}{
+                "error": err.Error(),
+            })
+            return
+        }
+
         // Update request with new context that contains the account object
         req = req.WithContext(newCtx)
 
@@ -525,6 +537,7 @@ func GenerateToken(deviceID string, account *accountModel.AccountEntity, private
 	claims["sub"] = username
 	claims["user_uuid"] = *account.UserUUID
 	claims["device_id"] = deviceID
+	claims["roles"] = roles.Of(account)
 
 	// Set expiration to 24 hours from now
 	claims["exp"] = time.Now().Add(24 * time.Hour).Unix() // Expiration time as a Unix timestamp
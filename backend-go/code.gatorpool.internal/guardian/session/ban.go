@@ -0,0 +1,50 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	accountModel "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/fulfillment/warnings"
+	"code.gatorpool.internal/util"
+)
+
+// MARK: VerifyOAuthTokenAllowBanned
+// VerifyOAuthTokenAllowBanned is VerifyOAuthToken for the few routes banned accounts can still use,
+// like seeing their warnings and appealing them
+func VerifyOAuthTokenAllowBanned(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		newCtx, err := VerifyOAuthTokenInternal(req, res, req.Context())
+		if err != nil {
+			util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		next.ServeHTTP(res, req.WithContext(newCtx))
+	})
+}
+
+// checkBan returns an error and the status to respond with if the account in context is banned, or
+// if it can't be told whether it is. Like checkStanding for trips, a failed lookup turns the request
+// away rather than letting a banned account through.
+func checkBan(ctx context.Context) (int, error) {
+	account, ok := ctx.Value("account").(accountModel.AccountEntity)
+	if !ok {
+		return 0, nil
+	}
+
+	ban, err := warnings.ActiveBan(ctx, *account.UserUUID, time.Now())
+	if err != nil {
+		fmt.Println("Error checking for bans: ", err)
+		return http.StatusInternalServerError, err
+	}
+
+	if ban != nil {
+		return http.StatusForbidden, warnings.BanError(ban)
+	}
+	return 0, nil
+}
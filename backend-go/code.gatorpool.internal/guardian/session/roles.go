@@ -0,0 +1,36 @@
+package session
+
+import (
+	"net/http"
+
+	accountModel "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/account/roles"
+	"code.gatorpool.internal/util"
+)
+
+// MARK: RequireRole
+// RequireRole only lets accounts with one of the roles through, admins always get through. It runs
+// after VerifyOAuthToken, which puts the account in context. Roles are checked against the account
+// rather than the token, so taking a role away applies straight away
+func RequireRole(allowed ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			account, ok := req.Context().Value("account").(accountModel.AccountEntity)
+			if !ok {
+				util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+					"error": "no account in context",
+				})
+				return
+			}
+
+			if !roles.Has(&account, allowed...) {
+				util.JSONResponse(res, http.StatusForbidden, map[string]interface{}{
+					"error": "you don't have access to this",
+				})
+				return
+			}
+
+			next.ServeHTTP(res, req)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package totp
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The SHA1 secret from RFC 6238 appendix B
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// RFC 6238 appendix B, keeping the last six digits of each eight digit code
+	tests := []struct {
+		Name     string
+		Unix     int64
+		Expected string
+	}{
+		{"59", 59, "287082"},
+		{"1111111109", 1111111109, "081804"},
+		{"1111111111", 1111111111, "050471"},
+		{"1234567890", 1234567890, "005924"},
+		{"2000000000", 2000000000, "279037"},
+		{"20000000000", 20000000000, "353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			code, err := Code(rfcSecret, Step(time.Unix(tt.Unix, 0)))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.Expected, code)
+		})
+	}
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+	_, err := Code("not base32!", 1)
+	assert.Equal(t, ErrInvalidSecret, err)
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	code := func(step int64) string {
+		code, _ := Code(rfcSecret, step)
+		return code
+	}
+
+	tests := []struct {
+		Name  string
+		Code  string
+		Step  int64
+		Valid bool
+	}{
+		{"Current code", code(current), current, true},
+		{"Previous code", code(current - 1), current - 1, true},
+		{"Next code", code(current + 1), current + 1, true},
+		{"Too old", code(current - 2), 0, false},
+		{"Spaces are ignored", code(current)[:3] + " " + code(current)[3:], current, true},
+		{"Wrong length", "12345", 0, false},
+		{"Wrong code", "000000", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			step, valid := Validate(rfcSecret, tt.Code, now)
+			assert.Equal(t, tt.Valid, valid)
+			assert.Equal(t, tt.Step, step)
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	_, err = Code(secret, 1)
+	assert.NoError(t, err)
+}
+
+func TestProvisioningURI(t *testing.T) {
+	uri := ProvisioningURI("GatorPool", "albert@ufl.edu", "JBSWY3DPEHPK3PXP")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/GatorPool:albert@ufl.edu?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=GatorPool")
+	assert.Contains(t, uri, "digits=6")
+	assert.Contains(t, uri, "period=30")
+}
@@ -0,0 +1,52 @@
+package entities
+
+import (
+	"time"
+)
+
+/*
+	A rider's share of a completed trip's fare, owed to the driver.
+
+	- owed: the trip is over and the rider hasn't paid yet
+	- paid: the rider says they've paid, e.g. "Venmo @jack"
+	- confirmed: the driver got the money
+	- disputed: the driver says they didn't, the rider can mark it paid again
+*/
+
+type DebtEntity struct {
+	// Unique identifier for the debt
+	DebtUUID			*string							`json:"debt_uuid,omitempty" bson:"debt_uuid,omitempty"`
+
+	TripUUID			*string							`json:"trip_uuid,omitempty" bson:"trip_uuid,omitempty"`
+
+	// The rider who owes, and the driver they owe
+	RiderUUID			*string							`json:"rider_uuid,omitempty" bson:"rider_uuid,omitempty"`
+	DriverUUID			*string							`json:"driver_uuid,omitempty" bson:"driver_uuid,omitempty"`
+
+	// The rider's share of each part of the fare, and all of it together
+	Gas					*float64						`json:"gas,omitempty" bson:"gas,omitempty"`
+	Food				*float64						`json:"food,omitempty" bson:"food,omitempty"`
+	Trip				*float64						`json:"trip,omitempty" bson:"trip,omitempty"`
+	Amount				*float64						`json:"amount,omitempty" bson:"amount,omitempty"`
+
+	// owed, paid, confirmed, disputed
+	Status				*string							`json:"status,omitempty" bson:"status,omitempty"`
+
+	// How the rider paid: venmo, zelle, cash, other, and anything they want the driver to know
+	Method				*string							`json:"method,omitempty" bson:"method,omitempty"`
+	Note				*string							`json:"note,omitempty" bson:"note,omitempty"`
+
+	// Why the driver disputed the payment
+	DisputeReason		*string							`json:"dispute_reason,omitempty" bson:"dispute_reason,omitempty"`
+
+	PaidAt				*time.Time						`json:"paid_at,omitempty" bson:"paid_at,omitempty"`
+	ConfirmedAt			*time.Time						`json:"confirmed_at,omitempty" bson:"confirmed_at,omitempty"`
+	DisputedAt			*time.Time						`json:"disputed_at,omitempty" bson:"disputed_at,omitempty"`
+
+	// When the debt becomes overdue, and when the rider was warned for it
+	DueAt				*time.Time						`json:"due_at,omitempty" bson:"due_at,omitempty"`
+	WarnedAt			*time.Time						`json:"warned_at,omitempty" bson:"warned_at,omitempty"`
+
+	CreatedAt			*time.Time						`json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt			*time.Time						`json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
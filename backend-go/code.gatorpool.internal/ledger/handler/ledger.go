@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/ledger"
+	ledgerEntities "code.gatorpool.internal/ledger/entities"
+	"code.gatorpool.internal/notify"
+	"code.gatorpool.internal/util"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/go-chi/chi"
+)
+
+type MarkDebtPaidBody struct {
+	// venmo, zelle, cash, other
+	Method string `json:"method"`
+
+	// e.g. the Venmo handle it was sent from
+	Note string `json:"note"`
+}
+
+type DisputeDebtBody struct {
+	Reason string `json:"reason"`
+}
+
+// GetLedger returns a page of the debts the user owes or is owed, and their balances
+func GetLedger(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	// Parse page number from query parameters
+	page := 1
+	if pageStr := req.URL.Query().Get("page"); pageStr != "" {
+		if parsedPage, err := strconv.Atoi(pageStr); err == nil && parsedPage > 0 {
+			page = parsedPage
+		}
+	}
+
+	debts, total, err := ledger.List(ctx, *account.UserUUID, page)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	balance, err := ledger.Balances(ctx, *account.UserUUID)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"debts":       debts,
+		"balance":     balance,
+		"currentPage": page,
+		"totalPages":  int(math.Ceil(float64(total) / float64(ledger.DebtsPerPage))),
+		"success":     true,
+	})
+}
+
+// MarkDebtPaid is the rider saying they've paid the driver
+func MarkDebtPaid(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	var body MarkDebtPaidBody
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid body",
+		})
+	}
+
+	if err := ledger.ValidMethod(body.Method); err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	note, err := ledger.Note(body.Note)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return updateDebt(req, res, ctx, ledger.ActionPay, func(debt *ledgerEntities.DebtEntity) {
+		debt.Method = nil
+		if body.Method != "" {
+			debt.Method = ptr.String(body.Method)
+		}
+		debt.Note = ptr.String(note)
+	})
+}
+
+// ConfirmDebt is the driver saying they got the rider's payment
+func ConfirmDebt(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+	return updateDebt(req, res, ctx, ledger.ActionConfirm, nil)
+}
+
+// DisputeDebt is the driver saying they didn't get the payment the rider marked
+func DisputeDebt(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	var body DisputeDebtBody
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid body",
+		})
+	}
+
+	reason, err := ledger.Note(body.Reason)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return updateDebt(req, res, ctx, ledger.ActionDispute, func(debt *ledgerEntities.DebtEntity) {
+		debt.DisputeReason = ptr.String(reason)
+	})
+}
+
+// debtEvents is who hears about each action, the other side of the debt
+var debtEvents = map[string]string{
+	ledger.ActionPay:     notify.EventPaymentMarked,
+	ledger.ActionConfirm: notify.EventPaymentConfirmed,
+	ledger.ActionDispute: notify.EventPaymentDisputed,
+}
+
+// updateDebt does the action to the debt in the URL for the account, then tells the other side
+func updateDebt(req *http.Request, res http.ResponseWriter, ctx context.Context, action string, edit func(debt *ledgerEntities.DebtEntity)) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	debt, err := ledger.Find(ctx, chi.URLParam(req, "debt_uuid"))
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if debt == nil {
+		return util.JSONResponse(res, http.StatusNotFound, map[string]interface{}{
+			"error": "debt not found",
+		})
+	}
+
+	from := *debt.Status
+	err = ledger.Apply(debt, action, *account.UserUUID, time.Now())
+	if err == ledger.ErrNotYourDebt {
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if err != nil {
+		return util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if edit != nil {
+		edit(debt)
+	}
+
+	saved, err := ledger.Save(ctx, debt, from)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if !saved {
+		return util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
+			"error": "the debt was changed by someone else, try again",
+		})
+	}
+
+	notifyUUID := *debt.DriverUUID
+	if action != ledger.ActionPay {
+		notifyUUID = *debt.RiderUUID
+	}
+
+	event := notify.Event{
+		Type:     debtEvents[action],
+		UserUUID: notifyUUID,
+		Data: map[string]string{
+			"NAME":      notify.FirstName(&account),
+			"AMOUNT":    fmt.Sprintf("%.2f", *debt.Amount),
+			"TRIP_UUID": *debt.TripUUID,
+			"DEBT_UUID": *debt.DebtUUID,
+		},
+	}
+	if err := notify.Enqueue(ctx, event); err != nil {
+		fmt.Println("Error queueing "+event.Type+" notification: ", err)
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"debt":    debt,
+		"success": true,
+	})
+}
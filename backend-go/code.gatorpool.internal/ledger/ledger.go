@@ -0,0 +1,307 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	ledgerEntities "code.gatorpool.internal/ledger/entities"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/fare"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/pborman/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	StatusOwed      = "owed"
+	StatusPaid      = "paid"
+	StatusConfirmed = "confirmed"
+	StatusDisputed  = "disputed"
+
+	MethodVenmo = "venmo"
+	MethodZelle = "zelle"
+	MethodCash  = "cash"
+	MethodOther = "other"
+
+	// What can be done to a debt, and by whom
+	ActionPay     = "pay"     // rider
+	ActionConfirm = "confirm" // driver
+	ActionDispute = "dispute" // driver
+
+	// How long a rider has to pay after the trip before the debt is overdue
+	DueAfter = time.Hour * 24 * 7
+
+	// Warning points for an overdue debt
+	OverduePoints = 1
+
+	MaxNoteLength = 200
+
+	DebtsPerPage = 25
+)
+
+var (
+	ErrNotYourDebt       = errors.New("this debt isn't yours")
+	ErrInvalidTransition = errors.New("the debt can't be changed that way")
+	ErrUnknownMethod     = errors.New("unknown payment method")
+	ErrNoteTooLong       = errors.New("note is too long")
+)
+
+// Balance is what the user owes and is owed across trips that haven't been settled
+type Balance struct {
+	// Owed by the user and not paid, or disputed by the driver
+	Owes float64 `json:"owes"`
+
+	// Paid by the user and waiting on the driver to confirm
+	Pending float64 `json:"pending"`
+
+	// Owed to the user as a driver and not confirmed yet
+	Owed float64 `json:"owed"`
+
+	// Debts the user hasn't paid on time
+	Overdue int `json:"overdue"`
+}
+
+// Debts is what each rider owes the driver once the trip is over, from the fare's shares
+func Debts(trip *tripEntities.TripEntity, now time.Time) []*ledgerEntities.DebtEntity {
+	debts := []*ledgerEntities.DebtEntity{}
+	if trip.TripUUID == nil || trip.AssignedDriver == nil || trip.AssignedDriver.UserUUID == nil || trip.Fare == nil {
+		return debts
+	}
+
+	// Trips saved before fares were split
+	if trip.Fare.Shares == nil {
+		fare.Split(trip)
+	}
+
+	for _, share := range trip.Fare.Shares {
+		if share == nil || share.UserUUID == nil || share.Total == nil || *share.Total <= 0 {
+			continue
+		}
+
+		debts = append(debts, &ledgerEntities.DebtEntity{
+			DebtUUID:   ptr.String(uuid.NewRandom().String()),
+			TripUUID:   ptr.String(*trip.TripUUID),
+			RiderUUID:  ptr.String(*share.UserUUID),
+			DriverUUID: ptr.String(*trip.AssignedDriver.UserUUID),
+			Gas:        share.Gas,
+			Food:       share.Food,
+			Trip:       share.Trip,
+			Amount:     share.Total,
+			Status:     ptr.String(StatusOwed),
+			DueAt:      ptr.Time(now.Add(DueAfter)),
+			CreatedAt:  ptr.Time(now),
+			UpdatedAt:  ptr.Time(now),
+		})
+	}
+
+	return debts
+}
+
+// Record adds the trip's debts to the ledger. Debts already recorded for the trip are left
+// alone, and the ones that are new are returned.
+func Record(ctx context.Context, trip *tripEntities.TripEntity) ([]*ledgerEntities.DebtEntity, error) {
+	ledgerCollection := datastores.GetMongoDatabase(ctx).Collection(datastores.Ledger)
+
+	recorded := []*ledgerEntities.DebtEntity{}
+	for _, debt := range Debts(trip, time.Now()) {
+		result, err := ledgerCollection.UpdateOne(ctx, bson.M{
+			"trip_uuid":  debt.TripUUID,
+			"rider_uuid": debt.RiderUUID,
+		}, bson.M{"$setOnInsert": debt}, options.Update().SetUpsert(true))
+		if err != nil {
+			return recorded, err
+		}
+		if result.UpsertedCount > 0 {
+			recorded = append(recorded, debt)
+		}
+	}
+
+	return recorded, nil
+}
+
+// ValidMethod reports whether the payment method is one we know, it's optional
+func ValidMethod(method string) error {
+	switch method {
+	case "", MethodVenmo, MethodZelle, MethodCash, MethodOther:
+		return nil
+	}
+	return ErrUnknownMethod
+}
+
+// Apply does the action to the debt for the user, checking it's theirs to do. Riders mark their
+// debts paid, and drivers confirm or dispute them. A driver can confirm a debt the rider never
+// marked, e.g. when they were paid in cash.
+func Apply(debt *ledgerEntities.DebtEntity, action string, userUUID string, now time.Time) error {
+	status := StatusOwed
+	if debt.Status != nil {
+		status = *debt.Status
+	}
+
+	isRider := debt.RiderUUID != nil && *debt.RiderUUID == userUUID
+	isDriver := debt.DriverUUID != nil && *debt.DriverUUID == userUUID
+
+	switch action {
+	case ActionPay:
+		if !isRider {
+			return ErrNotYourDebt
+		}
+		if status != StatusOwed && status != StatusDisputed {
+			return ErrInvalidTransition
+		}
+		debt.Status = ptr.String(StatusPaid)
+		debt.PaidAt = ptr.Time(now)
+	case ActionConfirm:
+		if !isDriver {
+			return ErrNotYourDebt
+		}
+		if status == StatusConfirmed {
+			return ErrInvalidTransition
+		}
+		debt.Status = ptr.String(StatusConfirmed)
+		debt.ConfirmedAt = ptr.Time(now)
+	case ActionDispute:
+		if !isDriver {
+			return ErrNotYourDebt
+		}
+		if status != StatusPaid {
+			return ErrInvalidTransition
+		}
+		debt.Status = ptr.String(StatusDisputed)
+		debt.DisputedAt = ptr.Time(now)
+	default:
+		return ErrInvalidTransition
+	}
+
+	debt.UpdatedAt = ptr.Time(now)
+	return nil
+}
+
+// Note trims a note left on a debt and checks it isn't too long
+func Note(note string) (string, error) {
+	note = strings.TrimSpace(note)
+	if len([]rune(note)) > MaxNoteLength {
+		return "", ErrNoteTooLong
+	}
+	return note, nil
+}
+
+// Overdue reports whether the debt is past due and still unpaid
+func Overdue(debt *ledgerEntities.DebtEntity, now time.Time) bool {
+	if debt.Status == nil || debt.DueAt == nil {
+		return false
+	}
+	return (*debt.Status == StatusOwed || *debt.Status == StatusDisputed) && debt.DueAt.Before(now)
+}
+
+// Summarize adds up the user's unsettled debts
+func Summarize(debts []*ledgerEntities.DebtEntity, userUUID string, now time.Time) Balance {
+	balance := Balance{}
+	for _, debt := range debts {
+		if debt == nil || debt.Status == nil || debt.Amount == nil {
+			continue
+		}
+
+		if debt.RiderUUID != nil && *debt.RiderUUID == userUUID {
+			switch *debt.Status {
+			case StatusOwed, StatusDisputed:
+				balance.Owes += *debt.Amount
+			case StatusPaid:
+				balance.Pending += *debt.Amount
+			}
+			if Overdue(debt, now) {
+				balance.Overdue++
+			}
+		}
+
+		if debt.DriverUUID != nil && *debt.DriverUUID == userUUID && *debt.Status != StatusConfirmed {
+			balance.Owed += *debt.Amount
+		}
+	}
+
+	balance.Owes = fare.Round(balance.Owes)
+	balance.Pending = fare.Round(balance.Pending)
+	balance.Owed = fare.Round(balance.Owed)
+	return balance
+}
+
+// Find returns the debt, or nil if there isn't one
+func Find(ctx context.Context, debtUUID string) (*ledgerEntities.DebtEntity, error) {
+	var debt *ledgerEntities.DebtEntity
+	err := datastores.GetMongoDatabase(ctx).Collection(datastores.Ledger).FindOne(ctx, bson.M{"debt_uuid": debtUUID}).Decode(&debt)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	return debt, err
+}
+
+// Save writes a debt changed by Apply, as long as it's still in the status it was read in. It
+// reports whether it was, so two changes at once can't both go through.
+func Save(ctx context.Context, debt *ledgerEntities.DebtEntity, from string) (bool, error) {
+	result, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Ledger).UpdateOne(ctx, bson.M{
+		"debt_uuid": debt.DebtUUID,
+		"status":    from,
+	}, bson.M{"$set": debt})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// List returns a page of the debts the user owes or is owed, newest first, and how many there
+// are in total
+func List(ctx context.Context, userUUID string, page int) ([]*ledgerEntities.DebtEntity, int64, error) {
+	ledgerCollection := datastores.GetMongoDatabase(ctx).Collection(datastores.Ledger)
+
+	query := bson.M{"$or": bson.A{
+		bson.M{"rider_uuid": userUUID},
+		bson.M{"driver_uuid": userUUID},
+	}}
+
+	total, err := ledgerCollection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * DebtsPerPage)).
+		SetLimit(DebtsPerPage)
+
+	cursor, err := ledgerCollection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	debts := []*ledgerEntities.DebtEntity{}
+	if err := cursor.All(ctx, &debts); err != nil {
+		return nil, 0, err
+	}
+
+	return debts, total, nil
+}
+
+// Balances is what the user owes and is owed across every unsettled debt
+func Balances(ctx context.Context, userUUID string) (Balance, error) {
+	cursor, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Ledger).Find(ctx, bson.M{
+		"$or": bson.A{
+			bson.M{"rider_uuid": userUUID},
+			bson.M{"driver_uuid": userUUID},
+		},
+		"status": bson.M{"$ne": StatusConfirmed},
+	})
+	if err != nil {
+		return Balance{}, err
+	}
+
+	debts := []*ledgerEntities.DebtEntity{}
+	if err := cursor.All(ctx, &debts); err != nil {
+		return Balance{}, err
+	}
+
+	return Summarize(debts, userUUID, time.Now()), nil
+}
@@ -0,0 +1,119 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+
+	ledgerEntities "code.gatorpool.internal/ledger/entities"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+)
+
+func debt(status string, amount float64) *ledgerEntities.DebtEntity {
+	return &ledgerEntities.DebtEntity{
+		DebtUUID:   ptr.String("debt"),
+		RiderUUID:  ptr.String("rider"),
+		DriverUUID: ptr.String("driver"),
+		Amount:     ptr.Float64(amount),
+		Status:     ptr.String(status),
+	}
+}
+
+func TestDebts(t *testing.T) {
+	now := time.Now()
+	trip := &tripEntities.TripEntity{
+		TripUUID:       ptr.String("trip"),
+		AssignedDriver: &tripEntities.TripAssignedDriverEntity{UserUUID: ptr.String("driver")},
+		Fare: &tripEntities.TripFareEntity{
+			Gas:  ptr.Float64(20),
+			Food: ptr.Float64(0),
+			Trip: ptr.Float64(10),
+		},
+		Riders: []*tripEntities.TripRiderEntity{
+			{
+				UserUUID: ptr.String("jack"),
+				Accepted: ptr.Bool(true),
+				Willing:  &tripEntities.TripRiderWillingEntity{PayGas: ptr.Bool(true)},
+			},
+			{
+				UserUUID: ptr.String("jill"),
+				Accepted: ptr.Bool(true),
+				Willing:  &tripEntities.TripRiderWillingEntity{PayGas: ptr.Bool(false)},
+			},
+		},
+	}
+
+	debts := Debts(trip, now)
+	assert.Len(t, debts, 2)
+	assert.Equal(t, "jack", *debts[0].RiderUUID)
+	assert.Equal(t, 25.0, *debts[0].Amount)
+	assert.Equal(t, 5.0, *debts[1].Amount)
+	assert.Equal(t, StatusOwed, *debts[1].Status)
+	assert.Equal(t, now.Add(DueAfter), *debts[0].DueAt)
+
+	// Riders who don't owe anything don't get a debt
+	trip.Fare.Trip = ptr.Float64(0)
+	trip.Fare.Shares = nil
+	assert.Len(t, Debts(trip, now), 1)
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Status   string
+		Action   string
+		User     string
+		Expected string
+		Err      error
+	}{
+		{"Rider pays", StatusOwed, ActionPay, "rider", StatusPaid, nil},
+		{"Rider pays again after a dispute", StatusDisputed, ActionPay, "rider", StatusPaid, nil},
+		{"Driver can't mark paid", StatusOwed, ActionPay, "driver", StatusOwed, ErrNotYourDebt},
+		{"Driver confirms", StatusPaid, ActionConfirm, "driver", StatusConfirmed, nil},
+		{"Driver confirms cash", StatusOwed, ActionConfirm, "driver", StatusConfirmed, nil},
+		{"Rider can't confirm", StatusPaid, ActionConfirm, "rider", StatusPaid, ErrNotYourDebt},
+		{"Already confirmed", StatusConfirmed, ActionConfirm, "driver", StatusConfirmed, ErrInvalidTransition},
+		{"Driver disputes", StatusPaid, ActionDispute, "driver", StatusDisputed, nil},
+		{"Nothing to dispute", StatusOwed, ActionDispute, "driver", StatusOwed, ErrInvalidTransition},
+		{"Someone else", StatusOwed, ActionPay, "stranger", StatusOwed, ErrNotYourDebt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			d := debt(tt.Status, 10)
+			err := Apply(d, tt.Action, tt.User, time.Now())
+			assert.Equal(t, tt.Err, err)
+			assert.Equal(t, tt.Expected, *d.Status)
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	now := time.Now()
+
+	overdue := debt(StatusOwed, 12.5)
+	overdue.DueAt = ptr.Time(now.Add(-time.Hour))
+
+	owedToRider := debt(StatusPaid, 8)
+	owedToRider.RiderUUID = ptr.String("someone")
+	owedToRider.DriverUUID = ptr.String("rider")
+
+	debts := []*ledgerEntities.DebtEntity{
+		overdue,
+		debt(StatusDisputed, 5),
+		debt(StatusPaid, 7.25),
+		debt(StatusConfirmed, 100),
+		owedToRider,
+	}
+
+	balance := Summarize(debts, "rider", now)
+	assert.Equal(t, 17.5, balance.Owes)
+	assert.Equal(t, 7.25, balance.Pending)
+	assert.Equal(t, 8.0, balance.Owed)
+	assert.Equal(t, 1, balance.Overdue)
+
+	assert.True(t, Overdue(overdue, now))
+	overdue.Status = ptr.String(StatusPaid)
+	assert.False(t, Overdue(overdue, now))
+}
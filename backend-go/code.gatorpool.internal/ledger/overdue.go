@@ -0,0 +1,77 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	dispatch "code.gatorpool.internal/fulfillment/dispatch"
+	warningEntities "code.gatorpool.internal/fulfillment/entities"
+	ledgerEntities "code.gatorpool.internal/ledger/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/pborman/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// WarnOverdue issues a warning to every rider with a debt past due, and returns how many it issued.
+// Each debt only ever earns one warning, however long it stays unpaid.
+func WarnOverdue(ctx context.Context, now time.Time) (int64, error) {
+	ledgerCollection := datastores.GetMongoDatabase(ctx).Collection(datastores.Ledger)
+
+	cursor, err := ledgerCollection.Find(ctx, bson.M{
+		"status":    bson.M{"$in": bson.A{StatusOwed, StatusDisputed}},
+		"due_at":    bson.M{"$lt": now},
+		"warned_at": nil,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	debts := []*ledgerEntities.DebtEntity{}
+	if err := cursor.All(ctx, &debts); err != nil {
+		return 0, err
+	}
+
+	var warned int64
+
+	for _, debt := range debts {
+		// Claim the debt first, so two servers don't both warn for it
+		result, err := ledgerCollection.UpdateOne(ctx, bson.M{
+			"debt_uuid": debt.DebtUUID,
+			"warned_at": nil,
+		}, bson.M{"$set": bson.M{"warned_at": now}})
+		if err != nil {
+			fmt.Println("Error claiming overdue debt: ", err)
+			continue
+		}
+		if result.ModifiedCount == 0 {
+			continue
+		}
+
+		if _, err := dispatch.DispatchWarningEvent(overdueWarning(debt, now), *debt.RiderUUID); err != nil {
+			fmt.Println("Error issuing overdue debt warning: ", err)
+			continue
+		}
+		warned++
+	}
+
+	return warned, nil
+}
+
+// overdueWarning is the warning a rider gets for not paying the debt on time
+func overdueWarning(debt *ledgerEntities.DebtEntity, now time.Time) *warningEntities.WarningEntity {
+	return &warningEntities.WarningEntity{
+		WarningUUID: ptr.String(uuid.NewRandom().String()),
+		UserUUID:    debt.RiderUUID,
+		Type:        ptr.String("warning"),
+		Points:      ptr.Int(OverduePoints),
+		IssuedAt:    ptr.Time(now),
+		Reason:      ptr.String("Trip fare unpaid"),
+		IssuedBy:    ptr.String("system"),
+		Resolved:    ptr.Bool(false),
+		ResolvesAt:  ptr.Time(now.Add(time.Hour * 24 * 30)),
+		CreatedAt:   ptr.Time(now),
+		UpdatedAt:   ptr.Time(now),
+	}
+}
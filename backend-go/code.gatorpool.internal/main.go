@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"code.gatorpool.internal/datastores/gcs"
 	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/guardian/ratelimit"
 	"code.gatorpool.internal/guardian/secrets"
 	"code.gatorpool.internal/guardian/session"
 	"code.gatorpool.internal/util"
@@ -17,11 +19,26 @@ import (
 	"github.com/joho/godotenv"
 
 	accountHandler "code.gatorpool.internal/account/handler"
+	accountHousekeeping "code.gatorpool.internal/account/housekeeping"
 	"code.gatorpool.internal/account/oauth"
+	"code.gatorpool.internal/account/roles"
 	configHandler "code.gatorpool.internal/config"
 	driverHandler "code.gatorpool.internal/driver/handler"
+	warningsHandler "code.gatorpool.internal/fulfillment/handler"
+	"code.gatorpool.internal/fulfillment/warnings"
+	"code.gatorpool.internal/ledger"
+	ledgerHandler "code.gatorpool.internal/ledger/handler"
+	"code.gatorpool.internal/notify"
+	notifyHandler "code.gatorpool.internal/notify/handler"
+	"code.gatorpool.internal/rider/alerts"
 	riderHandler "code.gatorpool.internal/rider/handler"
+	"code.gatorpool.internal/scheduler"
+	schedulerHandler "code.gatorpool.internal/scheduler/handler"
+	"code.gatorpool.internal/trip/geo"
 	tripHandler "code.gatorpool.internal/trip/handler"
+	tripHousekeeping "code.gatorpool.internal/trip/housekeeping"
+	"code.gatorpool.internal/trip/recurrence"
+	"code.gatorpool.internal/trip/stream"
 )
 
 func main() {
@@ -59,6 +76,65 @@ func main() {
 
 	datastores.ConnectDB(uri)
 	secrets.InitializeSecretCache()
+
+	if err := datastores.EnsureIndexes(context.Background()); err != nil {
+		logger.Error("Error creating indexes: " + err.Error())
+	}
+
+	if err := geo.Backfill(context.Background()); err != nil {
+		logger.Error("Error backfilling trip locations: " + err.Error())
+	}
+
+	// Send queued notifications, including any left over from before a restart. Sign-in codes can't
+	// wait on housekeeping, so the outbox has its own worker on every instance rather than a job.
+	notify.Register(notify.NewEmailChannel())
+	notify.Register(notify.NewInAppChannel())
+	go notify.Run(context.Background(), time.Minute)
+
+	// Background jobs, each on one instance at a time
+	for _, job := range []scheduler.Job{
+		// Keep recurring trips created a few weeks ahead, and let riders with a matching alert know
+		{Name: "generate_trip_series", Every: time.Hour, Run: func(ctx context.Context, now time.Time) (int64, error) {
+			trips, err := recurrence.GenerateAll(ctx, now)
+			alerts.Notify(ctx, trips...)
+			return int64(len(trips)), err
+		}},
+
+		// Warn riders who haven't paid for a trip in time
+		{Name: "warn_overdue_debts", Every: time.Hour, Run: ledger.WarnOverdue},
+
+		// Lift warnings and bans once their time is up
+		{Name: "resolve_expired_warnings", Every: time.Hour, Run: warnings.ResolveExpired},
+
+		{Name: "expire_stale_trips", Every: time.Hour, Run: tripHousekeeping.ExpireStale},
+		{Name: "complete_past_trips", Every: time.Hour, Run: tripHousekeeping.CompletePast},
+		{Name: "purge_expired_account_records", Every: time.Hour, Run: accountHousekeeping.PurgeExpired},
+		{Name: "purge_expired_sessions", Every: time.Hour * 24, Run: accountHousekeeping.PurgeSessions},
+	} {
+		if err := scheduler.Register(job); err != nil {
+			logger.Error("Error registering job " + job.Name + ": " + err.Error())
+		}
+	}
+
+	// Jobs don't run at all if the scheduler can't make sure they only run on one instance
+	go func() {
+		if err := scheduler.Run(context.Background(), time.Minute); err != nil {
+			logger.Error("Error starting scheduler, background jobs won't run: " + err.Error())
+		}
+	}()
+
+	// With more than one server, trip events are shared through a change stream on trips
+	if os.Getenv("TRIP_CHANGE_STREAM") == "true" {
+		go func() {
+			for {
+				if err := stream.Watch(context.Background(), stream.Default); err != nil {
+					logger.Error("Error watching trip changes: " + err.Error())
+				}
+				time.Sleep(time.Second * 5)
+			}
+		}()
+	}
+
 	gcs.InitMediaHandler()
 
 	r := chi.NewRouter()
@@ -78,7 +154,17 @@ func main() {
 		w.Write([]byte("Hello, world!"))
 	})
 
-	r.Post("/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+	// Rate limits are kept in this server unless they should be shared across servers
+	var limits ratelimit.Store = ratelimit.NewMemoryStore()
+	if os.Getenv("RATE_LIMIT_STORE") == "mongo" {
+		limits = ratelimit.NewMongoStore()
+	}
+
+	r.With(ratelimit.Middleware(limits, "oauth2_token",
+		ratelimit.PerIP(30, time.Second*10),
+		ratelimit.PerBodyUsername(10, time.Minute),
+		ratelimit.PerDevice(10, time.Minute),
+	)).Post("/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
 		oauth.OAuthToken(r, w, context.Background())
 	})
 
@@ -88,7 +174,10 @@ func main() {
 
 	// Account routes
 	r.Route("/v1/account", func(r chi.Router) {
-		r.Post("/auth/signup", func(w http.ResponseWriter, r *http.Request) {
+		r.With(ratelimit.Middleware(limits, "signup",
+			ratelimit.PerIP(5, time.Minute*10),
+			ratelimit.PerDevice(5, time.Minute*10),
+		)).Post("/auth/signup", func(w http.ResponseWriter, r *http.Request) {
 			accountHandler.SignUpV1(r, w, context.Background())
 		})
 		r.Put("/auth/verify", func(w http.ResponseWriter, r *http.Request) {
@@ -97,14 +186,31 @@ func main() {
 		r.Post("/auth/finish", func(w http.ResponseWriter, r *http.Request) {
 			accountHandler.FinishAccountV1(r, w, context.Background())
 		})
+		r.With(ratelimit.Middleware(limits, "unlock",
+			ratelimit.PerIP(10, time.Minute),
+		)).Put("/auth/unlock", func(w http.ResponseWriter, r *http.Request) {
+			accountHandler.UnlockAccount(r, w, context.Background())
+		})
 
-		r.Post("/auth/password/reset/request", func(w http.ResponseWriter, r *http.Request) {
+		r.With(ratelimit.Middleware(limits, "password_reset_request",
+			ratelimit.PerIP(10, time.Minute*5),
+			ratelimit.PerUsername(3, time.Minute*5),
+			ratelimit.PerDevice(5, time.Minute*5),
+		)).Post("/auth/password/reset/request", func(w http.ResponseWriter, r *http.Request) {
 			accountHandler.RequestPasswordReset(r, w, context.Background())
 		})
-		r.Post("/auth/password/reset", func(w http.ResponseWriter, r *http.Request) {
+		r.With(ratelimit.Middleware(limits, "password_reset",
+			ratelimit.PerIP(10, time.Minute),
+			ratelimit.PerUsername(5, time.Minute*5),
+			ratelimit.PerDevice(5, time.Minute*5),
+		)).Post("/auth/password/reset", func(w http.ResponseWriter, r *http.Request) {
 			accountHandler.ResetPassword(r, w, context.Background())
 		})
-		r.Post("/auth/password/reset/code", func(w http.ResponseWriter, r *http.Request) {
+		r.With(ratelimit.Middleware(limits, "password_reset_code",
+			ratelimit.PerIP(10, time.Minute),
+			ratelimit.PerUsername(5, time.Minute*5),
+			ratelimit.PerDevice(5, time.Minute*5),
+		)).Post("/auth/password/reset/code", func(w http.ResponseWriter, r *http.Request) {
 			accountHandler.CheckCode(r, w, context.Background())
 		})
 
@@ -116,9 +222,34 @@ func main() {
 			accountHandler.ToggleTwoFA(r, w, r.Context())
 		})
 
+		r.With(session.VerifyOAuthToken).Post("/auth/2fa/totp", func(w http.ResponseWriter, r *http.Request) {
+			accountHandler.EnrollTOTP(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Post("/auth/2fa/totp/confirm", func(w http.ResponseWriter, r *http.Request) {
+			accountHandler.ConfirmTOTP(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Delete("/auth/2fa/totp", func(w http.ResponseWriter, r *http.Request) {
+			accountHandler.DisableTOTP(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Post("/auth/2fa/recovery-codes", func(w http.ResponseWriter, r *http.Request) {
+			accountHandler.RegenerateRecoveryCodes(r, w, r.Context())
+		})
+
 		r.With(session.VerifyOAuthToken).Post("/idp/pfp", func(w http.ResponseWriter, r *http.Request) {
 			accountHandler.ChangeProfilePicture(r, w, r.Context())
 		})
+
+		// Banned users can still see their warnings and appeal them
+		r.With(session.VerifyOAuthTokenAllowBanned).Get("/warnings", func(w http.ResponseWriter, r *http.Request) {
+			warningsHandler.GetMyWarnings(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthTokenAllowBanned).Post("/warnings/{warning_uuid}/appeal", func(w http.ResponseWriter, r *http.Request) {
+			warningsHandler.AppealWarning(r, w, r.Context())
+		})
 	})
 
 	r.Route("/v1/rider", func(r chi.Router) {
@@ -134,6 +265,14 @@ func main() {
 			riderHandler.GetRiderFlowQueries(r, w, r.Context())
 		})
 
+		r.With(session.VerifyOAuthToken).Post("/queries/{query_uuid}/alert", func(w http.ResponseWriter, r *http.Request) {
+			riderHandler.SetQueryAlert(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Delete("/queries/{query_uuid}/alert", func(w http.ResponseWriter, r *http.Request) {
+			riderHandler.RemoveQueryAlert(r, w, r.Context())
+		})
+
 		r.With(session.VerifyOAuthToken).Get("/gender", func(w http.ResponseWriter, r *http.Request) {
 			riderHandler.GetRiderGender(r, w, r.Context())
 		})
@@ -178,10 +317,18 @@ func main() {
 			tripHandler.CreateTrip(r, w, r.Context())
 		})
 
+		r.With(session.VerifyOAuthToken).Get("/stream", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.StreamTrips(r, w, r.Context())
+		})
+
 		r.With(session.VerifyOAuthToken).Post("/rider/query", func(w http.ResponseWriter, r *http.Request) {
 			riderHandler.QueryTrips(r, w, r.Context())
 		})
 
+		r.With(session.VerifyOAuthToken).Post("/fare/suggest", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.SuggestFare(r, w, r.Context())
+		})
+
 		// r.With(session.VerifyOAuthToken).Get("/{trip_uuid}/rflow/driver", func(w http.ResponseWriter, r *http.Request) {
 		// 	tripHandler.RiderFlowGetDriverDetails(r, w, r.Context())
 		// })
@@ -198,6 +345,74 @@ func main() {
 			tripHandler.CancelTripDriverFlow(r, w, r.Context())
 		})
 
+		r.With(session.VerifyOAuthToken).Post("/{trip_uuid}/start", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.StartTrip(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Post("/{trip_uuid}/complete", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.CompleteTrip(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Post("/{trip_uuid}/location", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.ShareLocation(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Get("/{trip_uuid}/location", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.GetDriverLocation(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Get("/{trip_uuid}/messages", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.GetTripMessages(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Post("/{trip_uuid}/messages", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.SendTripMessage(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Post("/{trip_uuid}/messages/read", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.ReadTripMessages(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Get("/{trip_uuid}/timeline", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.GetTripTimeline(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Post("/{trip_uuid}/conflicts/{conflict_uuid}/accept", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.AcceptTripConflict(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Post("/{trip_uuid}/conflicts/{conflict_uuid}/leave", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.LeaveTripConflict(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Post("/{trip_uuid}/rate/driver", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.RiderRateDriver(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Post("/{trip_uuid}/rate/rider/{rider_uuid}", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.DriverRateRider(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Get("/series/{series_uuid}", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.GetTripSeries(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Put("/series/{series_uuid}", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.SaveTripSeries(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Delete("/series/{series_uuid}", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.CancelTripSeries(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Post("/series/{series_uuid}/request", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.RequestTripSeries(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Delete("/series/{series_uuid}/request", func(w http.ResponseWriter, r *http.Request) {
+			tripHandler.RemoveTripSeriesRequest(r, w, r.Context())
+		})
+
 		r.With(session.VerifyOAuthToken).Post("/rider/request", func(w http.ResponseWriter, r *http.Request) {
 			tripHandler.RiderRequestTrip(r, w, r.Context())
 		})
@@ -255,6 +470,100 @@ func main() {
 		})
 	})
 
+	r.Route("/v1/notifications", func(r chi.Router) {
+		r.With(session.VerifyOAuthToken).Get("/", func(w http.ResponseWriter, r *http.Request) {
+			notifyHandler.GetNotifications(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Post("/read", func(w http.ResponseWriter, r *http.Request) {
+			notifyHandler.ReadAllNotifications(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Post("/{notification_uuid}/read", func(w http.ResponseWriter, r *http.Request) {
+			notifyHandler.ReadNotification(r, w, r.Context())
+		})
+	})
+
+	r.Route("/v1/ledger", func(r chi.Router) {
+		r.With(session.VerifyOAuthToken).Get("/", func(w http.ResponseWriter, r *http.Request) {
+			ledgerHandler.GetLedger(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Post("/{debt_uuid}/paid", func(w http.ResponseWriter, r *http.Request) {
+			ledgerHandler.MarkDebtPaid(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Post("/{debt_uuid}/confirm", func(w http.ResponseWriter, r *http.Request) {
+			ledgerHandler.ConfirmDebt(r, w, r.Context())
+		})
+
+		r.With(session.VerifyOAuthToken).Post("/{debt_uuid}/dispute", func(w http.ResponseWriter, r *http.Request) {
+			ledgerHandler.DisputeDebt(r, w, r.Context())
+		})
+	})
+
+	r.Route("/v1/admin", func(r chi.Router) {
+		r.Use(session.VerifyOAuthToken)
+
+		// Moderators and admins review driver applications and handle warnings
+		r.Group(func(r chi.Router) {
+			r.Use(session.RequireRole(roles.Moderator))
+
+			r.Get("/driver-applications", func(w http.ResponseWriter, r *http.Request) {
+				driverHandler.ListDriverApplications(r, w, r.Context())
+			})
+
+			r.Post("/driver-applications/{application_uuid}/approve", func(w http.ResponseWriter, r *http.Request) {
+				driverHandler.ApproveDriverApplication(r, w, r.Context())
+			})
+
+			r.Post("/driver-applications/{application_uuid}/reject", func(w http.ResponseWriter, r *http.Request) {
+				driverHandler.RejectDriverApplication(r, w, r.Context())
+			})
+
+			r.Post("/driver-applications/{application_uuid}/request-changes", func(w http.ResponseWriter, r *http.Request) {
+				driverHandler.RequestDriverApplicationChanges(r, w, r.Context())
+			})
+
+			r.Get("/appeals", func(w http.ResponseWriter, r *http.Request) {
+				warningsHandler.ListAppeals(r, w, r.Context())
+			})
+
+			r.Post("/warnings/{warning_uuid}/appeal/uphold", func(w http.ResponseWriter, r *http.Request) {
+				warningsHandler.UpholdAppeal(r, w, r.Context())
+			})
+
+			r.Post("/warnings/{warning_uuid}/appeal/overturn", func(w http.ResponseWriter, r *http.Request) {
+				warningsHandler.OverturnAppeal(r, w, r.Context())
+			})
+
+			r.Post("/accounts/{user_uuid}/warnings", func(w http.ResponseWriter, r *http.Request) {
+				warningsHandler.IssueWarning(r, w, r.Context())
+			})
+		})
+
+		// Only admins hand out staff roles, clear lockouts and look after the scheduled jobs
+		r.Group(func(r chi.Router) {
+			r.Use(session.RequireRole(roles.Admin))
+
+			r.Post("/accounts/{user_uuid}/roles/{role}", func(w http.ResponseWriter, r *http.Request) {
+				accountHandler.GrantAccountRole(r, w, r.Context())
+			})
+
+			r.Delete("/accounts/{user_uuid}/roles/{role}", func(w http.ResponseWriter, r *http.Request) {
+				accountHandler.RevokeAccountRole(r, w, r.Context())
+			})
+
+			r.Delete("/accounts/{user_uuid}/lockout", func(w http.ResponseWriter, r *http.Request) {
+				accountHandler.ClearAccountLockout(r, w, r.Context())
+			})
+
+			r.Get("/jobs/runs", func(w http.ResponseWriter, r *http.Request) {
+				schedulerHandler.ListJobRuns(r, w, r.Context())
+			})
+		})
+	})
+
 	r.Route("/v1/config", func(r chi.Router) {
 		r.With(session.VerifyOAuthToken).Get("/banner", func(w http.ResponseWriter, r *http.Request) {
 			configHandler.GetBannerAnnouncement(r, w, r.Context())
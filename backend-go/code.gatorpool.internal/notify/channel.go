@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	datastores "code.gatorpool.internal/datastores/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+	ChannelPush  = "push"
+	ChannelInApp = "in_app"
+)
+
+var (
+	ErrUnknownChannel = errors.New("channel is not registered")
+	ErrNoAddress      = errors.New("recipient has no address for this channel")
+)
+
+// Channel delivers a message one way, e.g. by email. Send can be called again for the same
+// message if the server stops partway through, Message.ID is the same each time.
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
+
+// Message is one notification rendered for one channel
+type Message struct {
+	// The outbox entry the message came from
+	ID       string
+	Event    string
+	Channel  string
+	UserUUID string
+
+	// Sends to this address instead of the account's email
+	Email string
+
+	Subject string
+	Body    string
+
+	// HTML email template and the values filled into it and Subject and Body
+	Template string
+	Data     map[string]string
+}
+
+var (
+	mu       sync.RWMutex
+	channels = map[string]Channel{}
+)
+
+// Register makes the channel available, replacing any registered under the same name
+func Register(channel Channel) {
+	mu.Lock()
+	defer mu.Unlock()
+	channels[channel.Name()] = channel
+}
+
+// Unregister removes the channel, events stop being queued for it
+func Unregister(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(channels, name)
+}
+
+// Lookup returns the channel registered under name, or nil
+func Lookup(name string) Channel {
+	mu.RLock()
+	defer mu.RUnlock()
+	return channels[name]
+}
+
+// recipient finds the account a message is for
+func recipient(ctx context.Context, userUUID string) (*accountEntities.AccountEntity, error) {
+	var account accountEntities.AccountEntity
+	err := datastores.GetMongoDatabase(ctx).Collection(datastores.Accounts).FindOne(ctx, bson.M{"user_uuid": userUUID}).Decode(&account)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNoAddress
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
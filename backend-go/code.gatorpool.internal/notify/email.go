@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"context"
+	"net/smtp"
+	"os"
+
+	"code.gatorpool.internal/guardian/secrets"
+)
+
+// EmailChannel sends email through GatorPool's mailbox
+type EmailChannel struct {
+	From string
+	Host string
+	Addr string
+}
+
+func NewEmailChannel() *EmailChannel {
+	return &EmailChannel{
+		From: "noreply@gatorpool.app",
+		Host: "smtp.gmail.com",
+		Addr: "smtp.gmail.com:587",
+	}
+}
+
+func (c *EmailChannel) Name() string {
+	return ChannelEmail
+}
+
+func (c *EmailChannel) Send(ctx context.Context, msg Message) error {
+
+	data := map[string]string{}
+	for key, value := range msg.Data {
+		data[key] = value
+	}
+
+	// Look the account up for its address, and to greet them by name
+	to := msg.Email
+	if msg.UserUUID != "" {
+		account, err := recipient(ctx, msg.UserUUID)
+		if err != nil && to == "" {
+			return err
+		}
+		if account != nil {
+			if to == "" && account.Email != nil {
+				to = *account.Email
+			}
+			if _, ok := data["FIRST_NAME"]; !ok && account.FirstName != nil {
+				data["FIRST_NAME"] = *account.FirstName
+			}
+		}
+	}
+
+	if to == "" {
+		return ErrNoAddress
+	}
+
+	contentType := "text/plain"
+	body := msg.Body
+	if msg.Template != "" {
+		emailTemplate, err := os.ReadFile("templates/" + msg.Template + ".html")
+		if err != nil {
+			return err
+		}
+		contentType = "text/html"
+		body = Fill(string(emailTemplate), data)
+	}
+
+	// Construct email headers
+	headers := "From: " + c.From + "\n" +
+		"To: " + to + "\n" +
+		"Subject: " + msg.Subject + "\n" +
+		"MIME-version: 1.0\nContent-Type: " + contentType + "; charset=\"UTF-8\"\n\n"
+
+	auth := smtp.PlainAuth(
+		"",
+		c.From,
+		secrets.EmailSecretValue,
+		c.Host,
+	)
+
+	return smtp.SendMail(
+		c.Addr,
+		auth,
+		c.From,
+		[]string{to},
+		[]byte(headers+body),
+	)
+}
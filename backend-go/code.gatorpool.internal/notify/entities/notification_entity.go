@@ -0,0 +1,28 @@
+package entities
+
+import (
+	"time"
+)
+
+// A notification shown in the app, written by the in-app channel
+type NotificationEntity struct {
+	// Unique identifier, the same as the outbox entry it came from
+	NotificationUUID	*string							`json:"notification_uuid,omitempty" bson:"notification_uuid,omitempty"`
+
+	// Who the notification is for
+	UserUUID			*string							`json:"user_uuid,omitempty" bson:"user_uuid,omitempty"`
+
+	// What happened, e.g. trip.request_accepted
+	Event				*string							`json:"event,omitempty" bson:"event,omitempty"`
+
+	Title				*string							`json:"title,omitempty" bson:"title,omitempty"`
+	Body				*string							`json:"body,omitempty" bson:"body,omitempty"`
+
+	// Values the app can link from, e.g. TRIP_UUID
+	Data				map[string]string				`json:"data,omitempty" bson:"data,omitempty"`
+
+	Read				*bool							`json:"read" bson:"read"`
+	ReadAt				*time.Time						`json:"read_at,omitempty" bson:"read_at,omitempty"`
+
+	CreatedAt			*time.Time						`json:"created_at,omitempty" bson:"created_at,omitempty"`
+}
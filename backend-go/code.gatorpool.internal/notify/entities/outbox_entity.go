@@ -0,0 +1,56 @@
+package entities
+
+import (
+	"time"
+)
+
+/*
+
+	Every notification is written to the outbox before it is sent, one entry per channel,
+	so nothing is lost if the server restarts before it goes out.
+
+	- Status: pending until it is picked up, sending while a worker has it, then sent or failed
+	- Attempts: how many times it has been tried, failed once it runs out
+	- LockedUntil: a worker that dies mid-send gives the entry back once this passes
+
+*/
+
+type OutboxEntity struct {
+	// Unique identifier for the entry
+	OutboxUUID			*string							`json:"outbox_uuid,omitempty" bson:"outbox_uuid,omitempty"`
+
+	// What happened, e.g. trip.request_accepted
+	Event				*string							`json:"event,omitempty" bson:"event,omitempty"`
+
+	// Can be email, sms, push, in_app
+	Channel				*string							`json:"channel,omitempty" bson:"channel,omitempty"`
+
+	// Who the notification is for
+	UserUUID			*string							`json:"user_uuid,omitempty" bson:"user_uuid,omitempty"`
+
+	// Email address to use instead of the account's, for people who don't have one yet
+	Email				*string							`json:"email,omitempty" bson:"email,omitempty"`
+
+	// The rendered notification
+	Subject				*string							`json:"subject,omitempty" bson:"subject,omitempty"`
+	Body				*string							`json:"body,omitempty" bson:"body,omitempty"`
+
+	// HTML email template and the values filled into it
+	Template			*string							`json:"template,omitempty" bson:"template,omitempty"`
+	Data				map[string]string				`json:"data,omitempty" bson:"data,omitempty"`
+
+	// Can be pending, sending, sent, failed
+	Status				*string							`json:"status,omitempty" bson:"status,omitempty"`
+
+	Attempts			*int							`json:"attempts,omitempty" bson:"attempts,omitempty"`
+	LastError			*string							`json:"last_error,omitempty" bson:"last_error,omitempty"`
+
+	// When the entry can next be tried
+	NextAttemptAt		*time.Time						`json:"next_attempt_at,omitempty" bson:"next_attempt_at,omitempty"`
+	LockedUntil			*time.Time						`json:"locked_until,omitempty" bson:"locked_until,omitempty"`
+
+	// Timestamps
+	SentAt				*time.Time						`json:"sent_at,omitempty" bson:"sent_at,omitempty"`
+	CreatedAt			*time.Time						`json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt			*time.Time						`json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
@@ -0,0 +1,336 @@
+package notify
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/geo"
+	"code.gatorpool.internal/trip/recurrence"
+)
+
+const (
+	// Account
+	EventVerifyAccount    = "account.verify"
+	EventMFACode          = "account.mfa_code"
+	EventPasswordReset    = "account.password_reset"
+	EventAccountLocked    = "account.locked"
+	EventRecoveryCodeUsed = "account.recovery_code_used"
+
+	// A rider asked to join a driver's trip, and what the driver did about it
+	EventTripRequested   = "trip.requested"
+	EventSeriesRequested = "trip.series_requested"
+	EventRequestAccepted = "trip.request_accepted"
+	EventRequestRejected = "trip.request_rejected"
+	EventRiderRemoved    = "trip.rider_removed"
+	EventRiderLeft       = "trip.rider_left"
+
+	// A driver offered to drive a rider's trip, and what the rider did about it
+	EventDriverOffered = "trip.driver_offered"
+	EventOfferAccepted = "trip.offer_accepted"
+	EventOfferRejected = "trip.offer_rejected"
+
+	EventTripEdited      = "trip.edited"
+	EventSeriesEdited    = "trip.series_edited"
+	EventTripCancelled   = "trip.cancelled"
+	EventSeriesCancelled = "trip.series_cancelled"
+	EventTripAlert       = "trip.alert"
+	EventTripMessage     = "trip.message"
+
+	// Settling up after a trip
+	EventPaymentDue       = "payment.due"
+	EventPaymentMarked    = "payment.marked"
+	EventPaymentConfirmed = "payment.confirmed"
+	EventPaymentDisputed  = "payment.disputed"
+
+	// Driver applications
+	EventApplicationApproved         = "driver.application_approved"
+	EventApplicationRejected         = "driver.application_rejected"
+	EventApplicationChangesRequested = "driver.application_changes_requested"
+
+	// Warnings and appeals
+	EventWarningIssued    = "warning.issued"
+	EventAppealUpheld     = "warning.appeal_upheld"
+	EventAppealOverturned = "warning.appeal_overturned"
+)
+
+var ErrUnknownEvent = errors.New("unknown notification event")
+
+// Event is something that happened that someone should hear about
+type Event struct {
+	Type string
+
+	// Who the event is for
+	UserUUID string
+
+	// Sends email here instead of the account's email, for people who don't have one yet
+	Email string
+
+	// Values filled into the {{KEY}} placeholders
+	Data map[string]string
+}
+
+// kind is how an event is worded and which channels it goes out on
+type kind struct {
+	Channels []string
+	Subject  string
+	Body     string
+
+	// HTML email template under templates/, plain text Body is sent if empty
+	Template string
+
+	// Contains a code, so the rendered message is removed from the outbox once it's done
+	Sensitive bool
+}
+
+// Trip events go everywhere but SMS, which is kept for cancellations
+var tripChannels = []string{ChannelEmail, ChannelPush, ChannelInApp}
+
+var kinds = map[string]kind{
+	EventVerifyAccount: {
+		Channels: []string{ChannelEmail},
+		Subject:  "GatorPool - Verify your email",
+		Body:     "Finish signing up by verifying your email: {{URL}}",
+		Template: "verify-create-account",
+	},
+	EventMFACode: {
+		Channels:  []string{ChannelEmail},
+		Subject:   "GatorPool MFA Code",
+		Body:      "You have requested to sign in. Your code is {{CODE}}. This code will expire in 5 minutes.",
+		Sensitive: true,
+	},
+	EventPasswordReset: {
+		Channels:  []string{ChannelEmail},
+		Subject:   "Password Reset",
+		Body:      "You have requested to reset your password. Your code is {{CODE}}. This code will expire in 15 minutes.",
+		Sensitive: true,
+	},
+	EventAccountLocked: {
+		Channels:  []string{ChannelEmail},
+		Subject:   "GatorPool - Your account has been locked",
+		Body:      "Someone failed to sign in to your account too many times, so we've locked it for {{MINUTES}} minutes. If it was you, you can unlock it now: {{URL}} If it wasn't, we recommend resetting your password.",
+		Sensitive: true,
+	},
+	EventRecoveryCodeUsed: {
+		Channels:  []string{ChannelEmail},
+		Subject:   "GatorPool - A recovery code was used to sign in",
+		Body:      "Someone just signed in to your account with one of your recovery codes. You have {{REMAINING}} left. If it wasn't you, reset your password and generate new recovery codes.",
+		Sensitive: true,
+	},
+	EventTripRequested: {
+		Channels: tripChannels,
+		Subject:  "GatorPool - New ride request",
+		Body:     "{{NAME}} asked to join your trip from {{FROM}} to {{TO}} on {{DATE}}.",
+	},
+	EventSeriesRequested: {
+		Channels: tripChannels,
+		Subject:  "GatorPool - New ride request",
+		Body:     "{{NAME}} asked to join every trip in your weekly trip from {{FROM}} to {{TO}}.",
+	},
+	EventRequestAccepted: {
+		Channels: tripChannels,
+		Subject:  "GatorPool - Your ride request was accepted",
+		Body:     "{{NAME}} accepted your request to join the trip from {{FROM}} to {{TO}} on {{DATE}}.",
+	},
+	EventRequestRejected: {
+		Channels: tripChannels,
+		Subject:  "GatorPool - Your ride request was declined",
+		Body:     "Your request to join the trip from {{FROM}} to {{TO}} on {{DATE}} was declined.",
+	},
+	EventRiderRemoved: {
+		Channels: tripChannels,
+		Subject:  "GatorPool - You were removed from a trip",
+		Body:     "The driver removed you from the trip from {{FROM}} to {{TO}} on {{DATE}}.",
+	},
+	EventRiderLeft: {
+		Channels: tripChannels,
+		Subject:  "GatorPool - A rider left your trip",
+		Body:     "{{NAME}} left your trip from {{FROM}} to {{TO}} on {{DATE}}.",
+	},
+	EventDriverOffered: {
+		Channels: tripChannels,
+		Subject:  "GatorPool - A driver offered to drive your trip",
+		Body:     "{{NAME}} offered to drive your trip from {{FROM}} to {{TO}} on {{DATE}}.",
+	},
+	EventOfferAccepted: {
+		Channels: tripChannels,
+		Subject:  "GatorPool - Your offer to drive was accepted",
+		Body:     "{{NAME}} accepted your offer to drive the trip from {{FROM}} to {{TO}} on {{DATE}}.",
+	},
+	EventOfferRejected: {
+		Channels: tripChannels,
+		Subject:  "GatorPool - Your offer to drive was declined",
+		Body:     "Your offer to drive the trip from {{FROM}} to {{TO}} on {{DATE}} was declined.",
+	},
+	EventTripEdited: {
+		Channels: tripChannels,
+		Subject:  "GatorPool - Your trip was changed",
+		Body:     "The driver changed the fare or details of the trip from {{FROM}} to {{TO}} on {{DATE}}. Accept the changes or leave the trip before it starts.",
+	},
+	EventSeriesEdited: {
+		Channels: tripChannels,
+		Subject:  "GatorPool - A weekly trip was changed",
+		Body:     "The driver changed the fare or details of the weekly trip from {{FROM}} to {{TO}}. Accept the changes or leave each trip before it starts.",
+	},
+	EventTripCancelled: {
+		Channels: []string{ChannelEmail, ChannelSMS, ChannelPush, ChannelInApp},
+		Subject:  "GatorPool - Your trip was cancelled",
+		Body:     "The driver cancelled the trip from {{FROM}} to {{TO}} on {{DATE}}.",
+	},
+	EventSeriesCancelled: {
+		Channels: tripChannels,
+		Subject:  "GatorPool - A weekly trip was cancelled",
+		Body:     "The driver cancelled the weekly trip from {{FROM}} to {{TO}}. Upcoming trips in the series won't run.",
+	},
+	EventTripAlert: {
+		Channels: tripChannels,
+		Subject:  "GatorPool - A trip matching your search was posted",
+		Body:     "{{FROM}} to {{TO}}, leaving {{DATE}}.",
+		Template: "trip-alert",
+	},
+	EventTripMessage: {
+		Channels: []string{ChannelPush, ChannelInApp},
+		Subject:  "{{NAME}}",
+		Body:     "{{MESSAGE}}",
+	},
+	EventPaymentDue: {
+		Channels: tripChannels,
+		Subject:  "GatorPool - Your share of the trip",
+		Body:     "Your share of the trip from {{FROM}} to {{TO}} is ${{AMOUNT}}. Pay your driver and mark it paid in the app.",
+	},
+	EventPaymentMarked: {
+		Channels: []string{ChannelPush, ChannelInApp},
+		Subject:  "GatorPool - A rider paid",
+		Body:     "{{NAME}} says they paid ${{AMOUNT}}. Confirm it once you've got it.",
+	},
+	EventPaymentConfirmed: {
+		Channels: []string{ChannelPush, ChannelInApp},
+		Subject:  "GatorPool - Payment confirmed",
+		Body:     "{{NAME}} confirmed your payment of ${{AMOUNT}}.",
+	},
+	EventPaymentDisputed: {
+		Channels: tripChannels,
+		Subject:  "GatorPool - Your payment was disputed",
+		Body:     "{{NAME}} says they didn't get your payment of ${{AMOUNT}}. Sort it out with them and mark it paid again.",
+	},
+	EventApplicationApproved: {
+		Channels: []string{ChannelEmail, ChannelPush, ChannelInApp},
+		Subject:  "GatorPool - You're approved to drive",
+		Body:     "Your driver application was approved. You can start posting trips.",
+	},
+	EventApplicationRejected: {
+		Channels: []string{ChannelEmail, ChannelPush, ChannelInApp},
+		Subject:  "GatorPool - Your driver application",
+		Body:     "Your driver application wasn't approved. {{MESSAGE}}",
+	},
+	EventApplicationChangesRequested: {
+		Channels: []string{ChannelEmail, ChannelPush, ChannelInApp},
+		Subject:  "GatorPool - Your driver application needs changes",
+		Body:     "Your driver application needs a few changes before it can be approved. {{MESSAGE}} Update it and apply again in the app.",
+	},
+	EventWarningIssued: {
+		Channels: []string{ChannelEmail, ChannelInApp},
+		Subject:  "GatorPool - You've received a {{TYPE}}",
+		Body:     "You've received a {{TYPE}} on your account: {{REASON}} If you think this is a mistake, you can appeal it in the app.",
+	},
+	EventAppealUpheld: {
+		Channels: []string{ChannelEmail, ChannelInApp},
+		Subject:  "GatorPool - Your appeal",
+		Body:     "We looked at your appeal and the {{TYPE}} on your account stands. {{NOTE}}",
+	},
+	EventAppealOverturned: {
+		Channels: []string{ChannelEmail, ChannelInApp},
+		Subject:  "GatorPool - Your appeal was accepted",
+		Body:     "We looked at your appeal and removed the {{TYPE}} from your account. {{NOTE}}",
+	},
+}
+
+// Render words the event for each of its channels
+func Render(event Event) ([]Message, error) {
+	k, ok := kinds[event.Type]
+	if !ok {
+		return nil, ErrUnknownEvent
+	}
+
+	messages := []Message{}
+	for _, channel := range k.Channels {
+		messages = append(messages, Message{
+			Event:    event.Type,
+			Channel:  channel,
+			UserUUID: event.UserUUID,
+			Email:    event.Email,
+			Subject:  Fill(k.Subject, event.Data),
+			Body:     Fill(k.Body, event.Data),
+			Template: k.Template,
+			Data:     event.Data,
+		})
+	}
+
+	return messages, nil
+}
+
+// Fill replaces the {{KEY}} placeholders in text with data
+func Fill(text string, data map[string]string) string {
+	for key, value := range data {
+		text = strings.ReplaceAll(text, "{{"+key+"}}", value)
+	}
+	return text
+}
+
+// TripEvent is an event about the trip, with where and when it's going filled in
+func TripEvent(eventType string, userUUID string, trip *tripEntities.TripEntity, data map[string]string) Event {
+	filled := map[string]string{
+		"FROM": PlaceName(geo.FindWaypoint(trip, "pickup", "driver")),
+		"TO":   PlaceName(geo.FindWaypoint(trip, "destination", "driver")),
+	}
+
+	if trip.TripUUID != nil {
+		filled["TRIP_UUID"] = *trip.TripUUID
+	}
+	if trip.SeriesUUID != nil {
+		filled["SERIES_UUID"] = *trip.SeriesUUID
+	}
+	if trip.Datetime != nil {
+		filled["DATE"] = FormatDate(*trip.Datetime)
+	}
+
+	for key, value := range data {
+		filled[key] = value
+	}
+
+	return Event{Type: eventType, UserUUID: userUUID, Data: filled}
+}
+
+// FormatDate is how dates are written in notifications, in Gainesville time
+func FormatDate(at time.Time) string {
+	loc, err := time.LoadLocation(recurrence.DefaultTimezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return at.In(loc).Format("Monday, January 2 at 3:04 PM")
+}
+
+// PlaceName is the shortest useful name for a waypoint
+func PlaceName(waypoint *tripEntities.WaypointEntity) string {
+	switch {
+	case waypoint == nil:
+		return ""
+	case waypoint.GeoText != nil && *waypoint.GeoText != "":
+		return *waypoint.GeoText
+	case waypoint.Name != nil:
+		return *waypoint.Name
+	case waypoint.City != nil:
+		return *waypoint.City
+	}
+	return ""
+}
+
+// FirstName is how the account is named to other people in notifications
+func FirstName(account *accountEntities.AccountEntity) string {
+	if account == nil || account.FirstName == nil || *account.FirstName == "" {
+		return "Someone"
+	}
+	return *account.FirstName
+}
@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeChannel keeps messages in memory instead of sending them, for tests
+type FakeChannel struct {
+	// Returned from Send instead of keeping the message when set
+	Err error
+
+	name     string
+	mu       sync.Mutex
+	messages []Message
+}
+
+func NewFakeChannel(name string) *FakeChannel {
+	return &FakeChannel{name: name}
+}
+
+func (c *FakeChannel) Name() string {
+	return c.name
+}
+
+func (c *FakeChannel) Send(ctx context.Context, msg Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Err != nil {
+		return c.Err
+	}
+
+	c.messages = append(c.messages, msg)
+	return nil
+}
+
+// Messages returns what has been sent so far
+func (c *FakeChannel) Messages() []Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Message{}, c.messages...)
+}
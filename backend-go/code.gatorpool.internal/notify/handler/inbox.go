@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/notify"
+	"code.gatorpool.internal/util"
+	"github.com/go-chi/chi"
+)
+
+// GetNotifications returns a page of the user's notifications, newest first, with how many
+// are unread
+func GetNotifications(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	// Parse page number from query parameters
+	page := 1
+	if pageStr := req.URL.Query().Get("page"); pageStr != "" {
+		if parsedPage, err := strconv.Atoi(pageStr); err == nil && parsedPage > 0 {
+			page = parsedPage
+		}
+	}
+
+	notifications, total, err := notify.Inbox(ctx, *account.UserUUID, page)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	unread, err := notify.UnreadCount(ctx, *account.UserUUID)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"notifications": notifications,
+		"unread":        unread,
+		"currentPage":   page,
+		"totalPages":    int(math.Ceil(float64(total) / float64(notify.NotificationsPerPage))),
+		"success":       true,
+	})
+}
+
+// ReadNotification marks one notification read
+func ReadNotification(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	found, err := notify.MarkRead(ctx, *account.UserUUID, chi.URLParam(req, "notification_uuid"))
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if !found {
+		return util.JSONResponse(res, http.StatusNotFound, map[string]interface{}{
+			"error": "notification not found",
+		})
+	}
+
+	return unreadResponse(ctx, res, *account.UserUUID, map[string]interface{}{})
+}
+
+// ReadAllNotifications marks every notification read
+func ReadAllNotifications(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	read, err := notify.MarkAllRead(ctx, *account.UserUUID)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return unreadResponse(ctx, res, *account.UserUUID, map[string]interface{}{"read": read})
+}
+
+// unreadResponse returns success with the new unread count, so the badge can be updated
+func unreadResponse(ctx context.Context, res http.ResponseWriter, userUUID string, body map[string]interface{}) *http.Response {
+	unread, err := notify.UnreadCount(ctx, userUUID)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	body["unread"] = unread
+	body["success"] = true
+	return util.JSONResponse(res, http.StatusOK, body)
+}
@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"context"
+	"time"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	notifyEntities "code.gatorpool.internal/notify/entities"
+	"code.gatorpool.internal/util/ptr"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InAppChannel saves the notification for the app to show
+type InAppChannel struct{}
+
+func NewInAppChannel() *InAppChannel {
+	return &InAppChannel{}
+}
+
+func (c *InAppChannel) Name() string {
+	return ChannelInApp
+}
+
+func (c *InAppChannel) Send(ctx context.Context, msg Message) error {
+	if msg.UserUUID == "" {
+		return ErrNoAddress
+	}
+
+	notification := &notifyEntities.NotificationEntity{
+		NotificationUUID: ptr.String(msg.ID),
+		UserUUID:         ptr.String(msg.UserUUID),
+		Event:            ptr.String(msg.Event),
+		Title:            ptr.String(msg.Subject),
+		Body:             ptr.String(msg.Body),
+		Data:             msg.Data,
+		Read:             ptr.Bool(false),
+		CreatedAt:        ptr.Time(time.Now()),
+	}
+
+	// Keyed on the outbox entry, so sending it again doesn't show it twice
+	_, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Notifications).UpdateOne(ctx,
+		bson.M{"notification_uuid": notification.NotificationUUID},
+		bson.M{"$setOnInsert": notification},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
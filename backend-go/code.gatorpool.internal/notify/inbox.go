@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"time"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	notifyEntities "code.gatorpool.internal/notify/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const NotificationsPerPage = 25
+
+// Inbox returns a page of the user's in-app notifications, newest first, and how many they have
+// in total
+func Inbox(ctx context.Context, userUUID string, page int) ([]*notifyEntities.NotificationEntity, int64, error) {
+	notificationsCollection := datastores.GetMongoDatabase(ctx).Collection(datastores.Notifications)
+
+	query := bson.M{"user_uuid": userUUID}
+
+	total, err := notificationsCollection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSkip(int64((page - 1) * NotificationsPerPage)).
+		SetLimit(NotificationsPerPage)
+
+	cursor, err := notificationsCollection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	notifications := []*notifyEntities.NotificationEntity{}
+	if err := cursor.All(ctx, &notifications); err != nil {
+		return nil, 0, err
+	}
+
+	return notifications, total, nil
+}
+
+// UnreadCount is how many of the user's notifications they haven't read
+func UnreadCount(ctx context.Context, userUUID string) (int64, error) {
+	return datastores.GetMongoDatabase(ctx).Collection(datastores.Notifications).CountDocuments(ctx, bson.M{
+		"user_uuid": userUUID,
+		"read":      false,
+	})
+}
+
+// MarkRead marks one of the user's notifications read, and reports whether it was found
+func MarkRead(ctx context.Context, userUUID string, notificationUUID string) (bool, error) {
+	result, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Notifications).UpdateOne(ctx, bson.M{
+		"notification_uuid": notificationUUID,
+		"user_uuid":         userUUID,
+	}, bson.M{"$set": bson.M{"read": true}, "$min": bson.M{"read_at": time.Now()}})
+	if err != nil {
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+// MarkAllRead marks every unread notification the user has read, and returns how many there were
+func MarkAllRead(ctx context.Context, userUUID string) (int64, error) {
+	result, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Notifications).UpdateMany(ctx, bson.M{
+		"user_uuid": userUUID,
+		"read":      false,
+	}, bson.M{"$set": bson.M{
+		"read":    true,
+		"read_at": time.Now(),
+	}})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
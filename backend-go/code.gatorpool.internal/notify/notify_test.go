@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	notifyEntities "code.gatorpool.internal/notify/entities"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRender(t *testing.T) {
+	trip := &tripEntities.TripEntity{
+		TripUUID: ptr.String("trip"),
+		Datetime: ptr.Time(time.Date(2025, 1, 3, 22, 0, 0, 0, time.UTC)),
+		Waypoints: []*tripEntities.WaypointEntity{
+			{Type: ptr.String("pickup"), For: ptr.String("driver"), City: ptr.String("Gainesville")},
+			{Type: ptr.String("destination"), For: ptr.String("driver"), GeoText: ptr.String("Miami, FL")},
+		},
+	}
+
+	messages, err := Render(TripEvent(EventRequestAccepted, "rider", trip, map[string]string{"NAME": "Alex"}))
+	assert.NoError(t, err)
+	assert.Len(t, messages, 3)
+
+	for _, msg := range messages {
+		assert.Equal(t, "rider", msg.UserUUID)
+		assert.Equal(t, "trip", msg.Data["TRIP_UUID"])
+		assert.Equal(t, "Alex accepted your request to join the trip from Gainesville to Miami, FL on Friday, January 3 at 5:00 PM.", msg.Body)
+	}
+	assert.Equal(t, ChannelEmail, messages[0].Channel)
+	assert.Equal(t, ChannelInApp, messages[2].Channel)
+
+	_, err = Render(Event{Type: "trip.unknown"})
+	assert.Equal(t, ErrUnknownEvent, err)
+}
+
+func TestDeliver(t *testing.T) {
+	fake := NewFakeChannel(ChannelPush)
+	Register(fake)
+	defer Unregister(ChannelPush)
+
+	entry := &notifyEntities.OutboxEntity{
+		OutboxUUID: ptr.String("outbox"),
+		Event:      ptr.String(EventTripCancelled),
+		Channel:    ptr.String(ChannelPush),
+		UserUUID:   ptr.String("rider"),
+		Subject:    ptr.String("GatorPool - Your trip was cancelled"),
+	}
+
+	assert.NoError(t, Deliver(context.Background(), entry))
+	assert.Equal(t, []Message{{
+		ID:       "outbox",
+		Event:    EventTripCancelled,
+		Channel:  ChannelPush,
+		UserUUID: "rider",
+		Subject:  "GatorPool - Your trip was cancelled",
+	}}, fake.Messages())
+
+	fake.Err = errors.New("provider is down")
+	assert.Equal(t, fake.Err, Deliver(context.Background(), entry))
+	assert.Len(t, fake.Messages(), 1)
+
+	entry.Channel = ptr.String(ChannelSMS)
+	assert.Equal(t, ErrUnknownChannel, Deliver(context.Background(), entry))
+}
+
+func TestOutcome(t *testing.T) {
+	now := time.Now()
+
+	newEntry := func(event string, attempts int) *notifyEntities.OutboxEntity {
+		return &notifyEntities.OutboxEntity{Event: ptr.String(event), Attempts: ptr.Int(attempts)}
+	}
+
+	tests := []struct {
+		Name     string
+		Entry    *notifyEntities.OutboxEntity
+		Err      error
+		Status   string
+		Retry    time.Duration
+		Redacted bool
+	}{
+		{"Sent", newEntry(EventTripCancelled, 1), nil, StatusSent, 0, false},
+		{"Failed once", newEntry(EventTripCancelled, 1), errors.New("timeout"), StatusPending, time.Minute, false},
+		{"Failed again", newEntry(EventTripCancelled, 3), errors.New("timeout"), StatusPending, time.Minute * 9, false},
+		{"Out of attempts", newEntry(EventTripCancelled, MaxAttempts), errors.New("timeout"), StatusFailed, 0, false},
+		{"Nowhere to send it", newEntry(EventTripCancelled, 1), ErrNoAddress, StatusFailed, 0, false},
+		{"Codes are removed once sent", newEntry(EventMFACode, 1), nil, StatusSent, 0, true},
+		{"Codes are kept to try again", newEntry(EventMFACode, 1), errors.New("timeout"), StatusPending, time.Minute, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			update := outcome(tt.Entry, tt.Err, now)
+			set := update["$set"].(bson.M)
+			unset := update["$unset"].(bson.M)
+
+			assert.Equal(t, tt.Status, set["status"])
+			if tt.Retry > 0 {
+				assert.Equal(t, now.Add(tt.Retry), set["next_attempt_at"])
+			} else {
+				assert.Nil(t, set["next_attempt_at"])
+			}
+
+			_, redacted := unset["body"]
+			assert.Equal(t, tt.Redacted, redacted)
+		})
+	}
+}
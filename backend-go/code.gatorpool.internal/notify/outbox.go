@@ -0,0 +1,263 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	notifyEntities "code.gatorpool.internal/notify/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/pborman/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	StatusPending = "pending"
+	StatusSending = "sending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+
+	// How many times an entry is tried before it's given up on
+	MaxAttempts = 5
+
+	// How long a worker has to send an entry before another one can take it
+	LockFor = time.Minute * 2
+
+	// Most entries sent in one pass, the rest wait for the next
+	BatchSize = 100
+)
+
+var kick = make(chan struct{}, 1)
+
+// Enqueue writes the events to the outbox, one entry for each registered channel, and wakes
+// the worker. Channels that aren't registered are skipped.
+func Enqueue(ctx context.Context, events ...Event) error {
+
+	now := time.Now()
+
+	documents := []interface{}{}
+	for _, event := range events {
+		messages, err := Render(event)
+		if err != nil {
+			return err
+		}
+
+		for _, msg := range messages {
+			if Lookup(msg.Channel) == nil {
+				continue
+			}
+			documents = append(documents, outboxEntry(msg, now))
+		}
+	}
+
+	if len(documents) == 0 {
+		return nil
+	}
+
+	_, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Outbox).InsertMany(ctx, documents)
+	if err != nil {
+		return err
+	}
+
+	Kick()
+	return nil
+}
+
+// Kick wakes the worker so new entries go out now instead of on the next tick
+func Kick() {
+	select {
+	case kick <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends due outbox entries on an interval, or as soon as something is enqueued, until the
+// context is done
+func Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := Process(ctx); err != nil {
+			fmt.Println("Error processing outbox: ", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-kick:
+		}
+	}
+}
+
+// Process sends the outbox entries that are due
+func Process(ctx context.Context) error {
+	outboxCollection := datastores.GetMongoDatabase(ctx).Collection(datastores.Outbox)
+
+	for i := 0; i < BatchSize; i++ {
+		now := time.Now()
+
+		entry, err := claim(ctx, outboxCollection, now)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return nil
+		}
+
+		sendErr := Deliver(ctx, entry)
+		if sendErr != nil {
+			fmt.Println("Error sending "+*entry.Channel+" notification "+*entry.OutboxUUID+": ", sendErr)
+		}
+
+		_, err = outboxCollection.UpdateOne(ctx, bson.M{"outbox_uuid": entry.OutboxUUID}, outcome(entry, sendErr, time.Now()))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Deliver sends the entry on its channel
+func Deliver(ctx context.Context, entry *notifyEntities.OutboxEntity) error {
+	if entry.Channel == nil {
+		return ErrUnknownChannel
+	}
+
+	channel := Lookup(*entry.Channel)
+	if channel == nil {
+		return ErrUnknownChannel
+	}
+
+	return channel.Send(ctx, message(entry))
+}
+
+// claim takes the next due entry, or one whose worker stopped partway through, so no other
+// worker sends it at the same time
+func claim(ctx context.Context, outboxCollection *mongo.Collection, now time.Time) (*notifyEntities.OutboxEntity, error) {
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.M{"next_attempt_at": 1}).
+		SetReturnDocument(options.After)
+
+	var entry notifyEntities.OutboxEntity
+	err := outboxCollection.FindOneAndUpdate(ctx, bson.M{
+		"$or": []bson.M{
+			{"status": StatusPending, "next_attempt_at": bson.M{"$lte": now}},
+			{"status": StatusSending, "locked_until": bson.M{"$lte": now}},
+		},
+	}, bson.M{
+		"$set": bson.M{
+			"status":       StatusSending,
+			"locked_until": now.Add(LockFor),
+			"updated_at":   now,
+		},
+		"$inc": bson.M{"attempts": 1},
+	}, opts).Decode(&entry)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// outcome is the update recording how sending the entry went. Failed sends are tried again
+// later, waiting longer each time, until MaxAttempts. Sends that can never work fail right away.
+func outcome(entry *notifyEntities.OutboxEntity, sendErr error, now time.Time) bson.M {
+	update := bson.M{}
+	set := bson.M{"updated_at": now}
+	unset := bson.M{"locked_until": ""}
+
+	attempts := 0
+	if entry.Attempts != nil {
+		attempts = *entry.Attempts
+	}
+
+	done := true
+	switch {
+	case sendErr == nil:
+		set["status"] = StatusSent
+		set["sent_at"] = now
+	case errors.Is(sendErr, ErrUnknownChannel) || errors.Is(sendErr, ErrNoAddress) || attempts >= MaxAttempts:
+		// Trying again won't help
+		set["status"] = StatusFailed
+		set["last_error"] = sendErr.Error()
+	default:
+		done = false
+		set["status"] = StatusPending
+		set["last_error"] = sendErr.Error()
+		set["next_attempt_at"] = now.Add(Backoff(attempts))
+	}
+
+	// Codes don't stay in the outbox after they've been sent
+	if done && entry.Event != nil && kinds[*entry.Event].Sensitive {
+		unset["body"] = ""
+		unset["data"] = ""
+	}
+
+	update["$set"] = set
+	update["$unset"] = unset
+	return update
+}
+
+// Backoff is how long to wait before trying again after the given number of attempts
+func Backoff(attempts int) time.Duration {
+	return time.Minute * time.Duration(attempts*attempts)
+}
+
+func outboxEntry(msg Message, now time.Time) *notifyEntities.OutboxEntity {
+	entry := &notifyEntities.OutboxEntity{
+		OutboxUUID:    ptr.String(uuid.NewRandom().String()),
+		Event:         ptr.String(msg.Event),
+		Channel:       ptr.String(msg.Channel),
+		Subject:       ptr.String(msg.Subject),
+		Body:          ptr.String(msg.Body),
+		Data:          msg.Data,
+		Status:        ptr.String(StatusPending),
+		Attempts:      ptr.Int(0),
+		NextAttemptAt: ptr.Time(now),
+		CreatedAt:     ptr.Time(now),
+		UpdatedAt:     ptr.Time(now),
+	}
+
+	if msg.UserUUID != "" {
+		entry.UserUUID = ptr.String(msg.UserUUID)
+	}
+	if msg.Email != "" {
+		entry.Email = ptr.String(msg.Email)
+	}
+	if msg.Template != "" {
+		entry.Template = ptr.String(msg.Template)
+	}
+
+	return entry
+}
+
+func message(entry *notifyEntities.OutboxEntity) Message {
+	value := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+
+	return Message{
+		ID:       value(entry.OutboxUUID),
+		Event:    value(entry.Event),
+		Channel:  value(entry.Channel),
+		UserUUID: value(entry.UserUUID),
+		Email:    value(entry.Email),
+		Subject:  value(entry.Subject),
+		Body:     value(entry.Body),
+		Template: value(entry.Template),
+		Data:     entry.Data,
+	}
+}
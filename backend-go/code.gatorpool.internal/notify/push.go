@@ -0,0 +1,32 @@
+package notify
+
+import (
+	"context"
+)
+
+// PushProvider sends a push notification to every device the user is signed in on
+type PushProvider interface {
+	SendPush(ctx context.Context, userUUID string, title string, body string, data map[string]string) error
+}
+
+// PushChannel sends push notifications. It's only registered when there is a provider to
+// send through.
+type PushChannel struct {
+	Provider PushProvider
+}
+
+func NewPushChannel(provider PushProvider) *PushChannel {
+	return &PushChannel{Provider: provider}
+}
+
+func (c *PushChannel) Name() string {
+	return ChannelPush
+}
+
+func (c *PushChannel) Send(ctx context.Context, msg Message) error {
+	if msg.UserUUID == "" {
+		return ErrNoAddress
+	}
+
+	return c.Provider.SendPush(ctx, msg.UserUUID, msg.Subject, msg.Body, msg.Data)
+}
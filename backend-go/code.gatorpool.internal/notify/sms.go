@@ -0,0 +1,41 @@
+package notify
+
+import (
+	"context"
+)
+
+// SMSProvider sends a text message to a phone number
+type SMSProvider interface {
+	SendSMS(ctx context.Context, to string, body string) error
+}
+
+// SMSChannel texts the phone number on the account. It's only registered when there is a
+// provider to send through.
+type SMSChannel struct {
+	Provider SMSProvider
+}
+
+func NewSMSChannel(provider SMSProvider) *SMSChannel {
+	return &SMSChannel{Provider: provider}
+}
+
+func (c *SMSChannel) Name() string {
+	return ChannelSMS
+}
+
+func (c *SMSChannel) Send(ctx context.Context, msg Message) error {
+	if msg.UserUUID == "" {
+		return ErrNoAddress
+	}
+
+	account, err := recipient(ctx, msg.UserUUID)
+	if err != nil {
+		return err
+	}
+
+	if account.Phone == nil || *account.Phone == "" {
+		return ErrNoAddress
+	}
+
+	return c.Provider.SendSMS(ctx, *account.Phone, msg.Body)
+}
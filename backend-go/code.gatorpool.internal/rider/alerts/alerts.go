@@ -0,0 +1,155 @@
+package alerts
+
+import (
+	"math"
+	"time"
+
+	riderEntities "code.gatorpool.internal/rider/entities"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/geo"
+	"code.gatorpool.internal/trip/lifecycle"
+	"code.gatorpool.internal/trip/recurrence"
+	"code.gatorpool.internal/trip/seats"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/pborman/uuid"
+)
+
+const (
+	// How many searches are kept for each rider, not counting ones with an alert on
+	MaxQueries = 20
+
+	// How far either side of the searched date trips are shown
+	DateWindow         = time.Hour * 24 * 7
+	FlexibleDateWindow = time.Hour * 24 * 28
+)
+
+// Window returns how far either side of the searched date a search matches trips
+func Window(flexibleDates *bool) time.Duration {
+	if flexibleDates != nil && *flexibleDates {
+		return FlexibleDateWindow
+	}
+	return DateWindow
+}
+
+// ExpiresAt is when an alert on a search for date stops, the end of the day searched for in
+// Gainesville time
+func ExpiresAt(date time.Time) time.Time {
+	loc, err := time.LoadLocation(recurrence.DefaultTimezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := date.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc)
+}
+
+// Active reports whether the query has an alert on that hasn't expired
+func Active(query *riderEntities.RiderQueryEntity, now time.Time) bool {
+	return query.Alert != nil && *query.Alert && query.AlertExpiresAt != nil && query.AlertExpiresAt.After(now)
+}
+
+// Record adds the search to the rider's queries, newest first, or bumps it if they've searched
+// the same thing before. Only the most recent searches are kept, but ones with an alert on are
+// never dropped.
+func Record(queries []*riderEntities.RiderQueryEntity, query *riderEntities.RiderQueryEntity) ([]*riderEntities.RiderQueryEntity, *riderEntities.RiderQueryEntity) {
+
+	recorded := query
+	rest := []*riderEntities.RiderQueryEntity{}
+	for _, existing := range queries {
+		if existing != nil && recorded == query && sameSearch(existing, query) {
+			existing.LastQueried = query.LastQueried
+			existing.Radius = query.Radius
+			existing.FemalesOnly = query.FemalesOnly
+			existing.FlexibleDates = query.FlexibleDates
+			recorded = existing
+			continue
+		}
+		rest = append(rest, existing)
+	}
+
+	if recorded.QueryUUID == nil {
+		recorded.QueryUUID = ptr.String(uuid.NewRandom().String())
+	}
+
+	kept := []*riderEntities.RiderQueryEntity{recorded}
+	count := 1
+	for _, existing := range rest {
+		if existing == nil {
+			continue
+		}
+
+		alert := existing.Alert != nil && *existing.Alert
+		if !alert && count >= MaxQueries {
+			continue
+		}
+		if !alert {
+			count++
+		}
+		kept = append(kept, existing)
+	}
+
+	return kept, recorded
+}
+
+// Matches reports whether the trip is one the search would find. The trip has to be open, have
+// seats left, fall in the date window, and go where the rider wants to go.
+func Matches(query *riderEntities.RiderQueryEntity, trip *tripEntities.TripEntity, now time.Time) bool {
+
+	if query.Date == nil || trip.Datetime == nil || !trip.Datetime.After(now) {
+		return false
+	}
+
+	if trip.Status == nil || *trip.Status != lifecycle.StatusPending || seats.Remaining(trip) <= 0 {
+		return false
+	}
+
+	if math.Abs(float64(trip.Datetime.Sub(*query.Date))) > float64(Window(query.FlexibleDates)) {
+		return false
+	}
+
+	if query.FemalesOnly != nil && *query.FemalesOnly {
+		if trip.AssignedDriver == nil || trip.AssignedDriver.Gender == nil || *trip.AssignedDriver.Gender != "female" {
+			return false
+		}
+	}
+
+	from, ok := latLng(query.From)
+	if !ok {
+		return false
+	}
+
+	to, ok := latLng(query.To)
+	if !ok {
+		return false
+	}
+
+	_, ok = geo.MatchRoute(trip, from, to, geo.Radius(query.Radius), geo.DefaultDetourMiles)
+	return ok
+}
+
+func latLng(waypoint *tripEntities.WaypointEntity) (geo.LatLng, bool) {
+	if waypoint == nil || waypoint.Latitude == nil || waypoint.Longitude == nil {
+		return geo.LatLng{}, false
+	}
+	return geo.LatLng{Lat: *waypoint.Latitude, Lng: *waypoint.Longitude}, true
+}
+
+// sameSearch reports whether two queries are between the same places on the same day
+func sameSearch(a *riderEntities.RiderQueryEntity, b *riderEntities.RiderQueryEntity) bool {
+	aFrom, ok1 := latLng(a.From)
+	bFrom, ok2 := latLng(b.From)
+	aTo, ok3 := latLng(a.To)
+	bTo, ok4 := latLng(b.To)
+	if !ok1 || !ok2 || !ok3 || !ok4 || a.Date == nil || b.Date == nil {
+		return false
+	}
+
+	// Anything within a few hundred feet is the same place
+	const closeEnough = 0.1
+	if geo.DistanceMiles(aFrom, bFrom) > closeEnough || geo.DistanceMiles(aTo, bTo) > closeEnough {
+		return false
+	}
+
+	ay, am, ad := a.Date.UTC().Date()
+	by, bm, bd := b.Date.UTC().Date()
+	return ay == by && am == bm && ad == bd
+}
@@ -0,0 +1,132 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	riderEntities "code.gatorpool.internal/rider/entities"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+)
+
+func point(lat float64, lng float64) *tripEntities.WaypointEntity {
+	return &tripEntities.WaypointEntity{Latitude: ptr.Float64(lat), Longitude: ptr.Float64(lng)}
+}
+
+func search(date time.Time) *riderEntities.RiderQueryEntity {
+	return &riderEntities.RiderQueryEntity{
+		From:        point(29.6516, -82.3248),
+		To:          point(25.7617, -80.1918),
+		Date:        ptr.Time(date),
+		LastQueried: ptr.Time(time.Now()),
+	}
+}
+
+func TestRecord(t *testing.T) {
+	date := time.Now().Add(time.Hour * 24 * 3)
+
+	queries, first := Record(nil, search(date))
+	assert.Len(t, queries, 1)
+	assert.NotNil(t, first.QueryUUID)
+
+	// Searching the same thing again bumps it instead of adding another
+	first.Alert = ptr.Bool(true)
+	queries, again := Record(queries, search(date))
+	assert.Len(t, queries, 1)
+	assert.Equal(t, *first.QueryUUID, *again.QueryUUID)
+	assert.True(t, *again.Alert)
+
+	// Old searches fall off, but the alert is kept
+	for i := 0; i < MaxQueries+5; i++ {
+		queries, _ = Record(queries, search(date.Add(time.Hour*24*time.Duration(i+1))))
+	}
+	assert.Len(t, queries, MaxQueries+1)
+	assert.Equal(t, *first.QueryUUID, *queries[len(queries)-1].QueryUUID)
+}
+
+func TestActive(t *testing.T) {
+	now := time.Now()
+	query := search(now)
+
+	assert.False(t, Active(query, now))
+
+	query.Alert = ptr.Bool(true)
+	query.AlertExpiresAt = ptr.Time(ExpiresAt(now))
+	assert.True(t, Active(query, now))
+	assert.False(t, Active(query, now.Add(time.Hour*25)))
+}
+
+func TestExpiresAt(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	assert.Nil(t, err)
+
+	tests := []struct {
+		Name     string
+		Date     time.Time
+		Expected time.Time
+	}{
+		{"Midday", time.Date(2025, 3, 19, 12, 0, 0, 0, loc), time.Date(2025, 3, 20, 0, 0, 0, 0, loc)},
+		{"Start of the day", time.Date(2025, 3, 19, 0, 0, 0, 0, loc), time.Date(2025, 3, 20, 0, 0, 0, 0, loc)},
+		{"Late evening", time.Date(2025, 3, 19, 23, 59, 0, 0, loc), time.Date(2025, 3, 20, 0, 0, 0, 0, loc)},
+		{"Already the next day in UTC", time.Date(2025, 3, 20, 2, 0, 0, 0, time.UTC), time.Date(2025, 3, 20, 0, 0, 0, 0, loc)},
+		{"Clocks go forward", time.Date(2025, 3, 9, 12, 0, 0, 0, loc), time.Date(2025, 3, 10, 0, 0, 0, 0, loc)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.True(t, tt.Expected.Equal(ExpiresAt(tt.Date)))
+		})
+	}
+}
+
+func TestMatches(t *testing.T) {
+	now := time.Now()
+	date := now.Add(time.Hour * 24 * 10)
+
+	newTrip := func(datetime time.Time, gender string) *tripEntities.TripEntity {
+		return &tripEntities.TripEntity{
+			Status:         ptr.String("pending"),
+			Datetime:       ptr.Time(datetime),
+			SeatsRemaining: ptr.Int(2),
+			Seats:          ptr.Int(2),
+			AssignedDriver: &tripEntities.TripAssignedDriverEntity{Gender: ptr.String(gender)},
+			Waypoints: []*tripEntities.WaypointEntity{
+				{Type: ptr.String("pickup"), For: ptr.String("driver"), Latitude: ptr.Float64(29.65), Longitude: ptr.Float64(-82.32)},
+				{Type: ptr.String("destination"), For: ptr.String("driver"), Latitude: ptr.Float64(25.76), Longitude: ptr.Float64(-80.19)},
+			},
+		}
+	}
+
+	tests := []struct {
+		Name        string
+		Trip        *tripEntities.TripEntity
+		FemalesOnly bool
+		Flexible    bool
+		Expected    bool
+	}{
+		{"Same route and day", newTrip(date, "male"), false, false, true},
+		{"Outside the date window", newTrip(date.Add(time.Hour*24*10), "male"), false, false, false},
+		{"Inside a flexible date window", newTrip(date.Add(time.Hour*24*10), "male"), false, true, true},
+		{"Females only with a male driver", newTrip(date, "male"), true, false, false},
+		{"Females only with a female driver", newTrip(date, "female"), true, false, true},
+		{"Trip already left", newTrip(now.Add(-time.Hour), "male"), false, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			query := search(date)
+			query.FemalesOnly = ptr.Bool(tt.FemalesOnly)
+			query.FlexibleDates = ptr.Bool(tt.Flexible)
+			assert.Equal(t, tt.Expected, Matches(query, tt.Trip, now))
+		})
+	}
+
+	// Full trips don't match
+	full := newTrip(date, "male")
+	full.Riders = []*tripEntities.TripRiderEntity{
+		{UserUUID: ptr.String("a"), Accepted: ptr.Bool(true)},
+		{UserUUID: ptr.String("b"), Accepted: ptr.Bool(true)},
+	}
+	assert.False(t, Matches(search(date), full, now))
+}
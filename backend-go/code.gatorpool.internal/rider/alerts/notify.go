@@ -0,0 +1,129 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/notify"
+	riderEntities "code.gatorpool.internal/rider/entities"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Notify tells every rider with an alert that matches one of the new trips about it. Riders
+// hear about one trip however many of them match.
+func Notify(ctx context.Context, trips ...*tripEntities.TripEntity) {
+
+	if len(trips) == 0 {
+		return
+	}
+
+	if err := Expire(ctx); err != nil {
+		fmt.Println("Error expiring alerts: ", err)
+	}
+
+	now := time.Now()
+
+	// Only look at riders with an alert somewhere near the new trips' dates
+	earliest, latest := *trips[0].Datetime, *trips[0].Datetime
+	for _, trip := range trips {
+		if trip.Datetime.Before(earliest) {
+			earliest = *trip.Datetime
+		}
+		if trip.Datetime.After(latest) {
+			latest = *trip.Datetime
+		}
+	}
+
+	cursor, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Riders).Find(ctx, bson.M{
+		"rider_uuid": bson.M{"$ne": trips[0].PostedBy},
+		"queries": bson.M{"$elemMatch": bson.M{
+			"alert":            true,
+			"alert_expires_at": bson.M{"$gt": now},
+			"date": bson.M{
+				"$gte": earliest.Add(-FlexibleDateWindow),
+				"$lte": latest.Add(FlexibleDateWindow),
+			},
+		}},
+	})
+	if err != nil {
+		fmt.Println("Error finding rider alerts: ", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var rider riderEntities.RiderEntity
+		if err := cursor.Decode(&rider); err != nil {
+			fmt.Println("Error decoding rider: ", err)
+			continue
+		}
+
+		trip := firstMatch(&rider, trips, now)
+		if trip == nil {
+			continue
+		}
+
+		if err := sendAlert(ctx, *rider.RiderUUID, trip); err != nil {
+			fmt.Println("Error sending trip alert: ", err)
+		}
+	}
+}
+
+// Expire turns off alerts whose date has passed
+func Expire(ctx context.Context) error {
+	now := time.Now()
+
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{
+			"expired.alert":            true,
+			"expired.alert_expires_at": bson.M{"$lte": now},
+		}},
+	})
+
+	_, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Riders).UpdateMany(ctx, bson.M{
+		"queries": bson.M{"$elemMatch": bson.M{
+			"alert":            true,
+			"alert_expires_at": bson.M{"$lte": now},
+		}},
+	}, bson.M{"$set": bson.M{
+		"queries.$[expired].alert": false,
+	}}, opts)
+
+	return err
+}
+
+// firstMatch returns the earliest trip that matches one of the rider's alerts
+func firstMatch(rider *riderEntities.RiderEntity, trips []*tripEntities.TripEntity, now time.Time) *tripEntities.TripEntity {
+	var match *tripEntities.TripEntity
+	for _, query := range rider.Queries {
+		if query == nil || !Active(query, now) {
+			continue
+		}
+		for _, trip := range trips {
+			if match != nil && !trip.Datetime.Before(*match.Datetime) {
+				continue
+			}
+			if Matches(query, trip, now) {
+				match = trip
+			}
+		}
+	}
+	return match
+}
+
+func sendAlert(ctx context.Context, riderUUID string, trip *tripEntities.TripEntity) error {
+
+	link := "https://gatorpool.app/find-ride"
+	if os.Getenv("ENV") == "development" {
+		link = "http://localhost:3000/find-ride"
+	}
+
+	return notify.Enqueue(ctx, notify.TripEvent(notify.EventTripAlert, riderUUID, trip, map[string]string{
+		"URL": link,
+	}))
+}
@@ -14,6 +14,9 @@ type RiderEntity struct {
 	// Average rating of the rider given by drivers
 	Rating				*float64				`json:"rating" bson:"rating"`
 
+	// The number of ratings the average is made of
+	RatingCount			*int64					`json:"rating_count" bson:"rating_count"`
+
 	Options 			*RiderOptionsEntity		`json:"options" bson:"options"`
 
 	// The amount of rides the rider has taken/cancelled
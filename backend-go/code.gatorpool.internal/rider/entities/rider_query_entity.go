@@ -12,4 +12,15 @@ type RiderQueryEntity struct {
 	To					*tripEntities.WaypointEntity	`json:"to,omitempty" bson:"to,omitempty"`
 	Date				*time.Time						`json:"date,omitempty" bson:"date,omitempty"`
 	LastQueried			*time.Time						`json:"last_queried,omitempty" bson:"last_queried,omitempty"`
+
+	// Search options, so an alert matches the same trips the search did
+	Radius				*float64						`json:"radius,omitempty" bson:"radius,omitempty"`
+	FemalesOnly			*bool							`json:"females_only,omitempty" bson:"females_only,omitempty"`
+	FlexibleDates		*bool							`json:"flexible_dates,omitempty" bson:"flexible_dates,omitempty"`
+
+	// Whether the rider is notified when a trip matching this query is posted
+	Alert				*bool							`json:"alert,omitempty" bson:"alert,omitempty"`
+
+	// Alerts stop once the date searched for has passed
+	AlertExpiresAt		*time.Time						`json:"alert_expires_at,omitempty" bson:"alert_expires_at,omitempty"`
 }
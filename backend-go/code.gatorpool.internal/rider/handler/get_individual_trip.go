@@ -7,7 +7,9 @@ import (
 
 	accountEntities "code.gatorpool.internal/account/entities"
 	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/trip/conflict"
 	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/fare"
 	"code.gatorpool.internal/util"
 	"github.com/go-chi/chi"
 	"go.mongodb.org/mongo-driver/bson"
@@ -41,8 +43,15 @@ func GetIndividualTrip(req *http.Request, res http.ResponseWriter, ctx context.C
 		})
 	}
 
+	// Trips saved before fares were split get their shares worked out here
+	if trip.Fare != nil && trip.Fare.Shares == nil {
+		fare.Split(trip)
+	}
+
 	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
 		"trip": trip,
+		"share": fare.FindShare(trip, *account.UserUUID),
+		"conflicts": conflict.Pending(trip, *account.UserUUID),
 		"success": true,
 		"userUUID": *account.UserUUID,
 	})
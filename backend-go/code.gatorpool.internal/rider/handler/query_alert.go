@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/rider/alerts"
+	riderEntities "code.gatorpool.internal/rider/entities"
+	"code.gatorpool.internal/util"
+	"github.com/go-chi/chi"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// SetQueryAlert turns one of the rider's saved searches into an alert, so they're told when a
+// matching trip is posted
+func SetQueryAlert(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	rider, query, errResponse := findRiderQuery(req, res)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	if query.Date == nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "this search has no date",
+		})
+	}
+
+	expiresAt := alerts.ExpiresAt(*query.Date)
+	if !expiresAt.After(time.Now()) {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "the date of this search has already passed",
+		})
+	}
+
+	return updateQueryAlert(ctx, res, rider, query, true, &expiresAt)
+}
+
+// RemoveQueryAlert turns the alert on a saved search off
+func RemoveQueryAlert(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	rider, query, errResponse := findRiderQuery(req, res)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	return updateQueryAlert(ctx, res, rider, query, false, nil)
+}
+
+func findRiderQuery(req *http.Request, res http.ResponseWriter) (*riderEntities.RiderEntity, *riderEntities.RiderQueryEntity, *http.Response) {
+
+	rider, ok := req.Context().Value("rider").(*riderEntities.RiderEntity)
+	if !ok || rider == nil {
+		fmt.Println("Rider object is missing in context")
+		return nil, nil, util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no rider in context",
+		})
+	}
+
+	queryUUID := chi.URLParam(req, "query_uuid")
+	for _, query := range rider.Queries {
+		if query != nil && query.QueryUUID != nil && *query.QueryUUID == queryUUID {
+			return rider, query, nil
+		}
+	}
+
+	return nil, nil, util.JSONResponse(res, http.StatusNotFound, map[string]interface{}{
+		"error": "query not found",
+	})
+}
+
+func updateQueryAlert(ctx context.Context, res http.ResponseWriter, rider *riderEntities.RiderEntity, query *riderEntities.RiderQueryEntity, alert bool, expiresAt *time.Time) *http.Response {
+
+	query.Alert = &alert
+	query.AlertExpiresAt = expiresAt
+
+	riderCollection := datastores.GetMongoDatabase(ctx).Collection(datastores.Riders)
+
+	_, err := riderCollection.UpdateOne(ctx, bson.M{
+		"rider_uuid":         rider.RiderUUID,
+		"queries.query_uuid": query.QueryUUID,
+	}, bson.M{"$set": bson.M{
+		"queries.$.alert":            query.Alert,
+		"queries.$.alert_expires_at": query.AlertExpiresAt,
+	}})
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"query":   query,
+		"success": true,
+	})
+}
@@ -8,6 +8,7 @@ import (
 	datastores "code.gatorpool.internal/datastores/mongo"
 	riderEntities "code.gatorpool.internal/rider/entities"
 	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/geo"
 	"code.gatorpool.internal/util"
 	"code.gatorpool.internal/util/ptr"
 	"code.gatorpool.internal/util/requesthydrator"
@@ -65,6 +66,7 @@ func SaveAddress(req *http.Request, res http.ResponseWriter, ctx context.Context
 		Latitude: &latitude,
 		Longitude: &longitude,
 	}
+	geo.Locate(rider.Address)
 
 	db := datastores.GetMongoDatabase(ctx)
 	riderCollection := db.Collection(datastores.Riders)
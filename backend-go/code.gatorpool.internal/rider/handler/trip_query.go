@@ -4,15 +4,19 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"math"
 	"net/http"
 	"time"
 
 	accountEntities "code.gatorpool.internal/account/entities"
 	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/rider/alerts"
+	riderEntities "code.gatorpool.internal/rider/entities"
 	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/geo"
 	tripHandler "code.gatorpool.internal/trip/handler"
+	"code.gatorpool.internal/trip/seats"
 	"code.gatorpool.internal/util"
+	"code.gatorpool.internal/util/ptr"
 
 	"go.mongodb.org/mongo-driver/bson"
 )
@@ -30,9 +34,11 @@ type QueryTripsBodyRequest struct {
 		Lat float64 `json:"lat"`
 		Lng float64 `json:"lng"`
 	} `json:"to"`
-	Datetime      string `json:"datetime"`
-	FemalesOnly   *bool  `json:"females_only"`
-	FlexibleDates *bool  `json:"flexible_dates"`
+	Datetime      string   `json:"datetime"`
+	FemalesOnly   *bool    `json:"females_only"`
+	FlexibleDates *bool    `json:"flexible_dates"`
+	Radius        *float64 `json:"radius"`     // Miles, defaults to geo.DefaultRadiusMiles
+	MaxDetour     *float64 `json:"max_detour"` // Miles, defaults to geo.DefaultDetourMiles
 }
 
 func QueryTrips(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
@@ -70,6 +76,8 @@ func QueryTrips(req *http.Request, res http.ResponseWriter, ctx context.Context)
 
 	query := bson.M{
 		"status": "pending",
+		// Trips created before seats were tracked don't have seats_remaining yet
+		"seats_remaining": bson.M{"$ne": 0},
 	}
 
 	if body.FemalesOnly != nil && *body.FemalesOnly {
@@ -82,76 +90,56 @@ func QueryTrips(req *http.Request, res http.ResponseWriter, ctx context.Context)
 		}
 	}
 
-	// Find trips where the driver's destination waypoint is within 50 miles
-	round := func(val float64) float64 {
-		return math.Round(val*1e6) / 1e6
+	from := geo.LatLng{Lat: body.From.Lat, Lng: body.From.Lng}
+	to := geo.LatLng{Lat: body.To.Lat, Lng: body.To.Lng}
+	if !geo.Valid(from.Lat, from.Lng) || !geo.Valid(to.Lat, to.Lng) {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid coordinates",
+		})
 	}
 
-	// lat := round(body.To.Lat)
-	// lng := round(body.To.Lng)
+	// Find trips where the driver's pickup and destination are within the rider's radius,
+	// or the driver's route passes close enough to both for a detour. The trip's max drop off
+	// radius and the actual detour are checked after the query
+	radius := geo.Radius(body.Radius)
+	detour := geo.Detour(body.MaxDetour)
 
-	// query["waypoints"] = bson.M{
-	// 	"$elemMatch": bson.M{
-	// 		"type": "destination",
-	// 		"for":  "driver",
-	// 		"latitude": bson.M{
-	// 			"$gte": lat - 0.725,
-	// 			"$lte": lat + 0.725,
-	// 		},
-	// 		"longitude": bson.M{
-	// 			"$gte": lng - 0.725,
-	// 			"$lte": lng + 0.725,
-	// 		},
-	// 	},
-	// }
-
-	query["$and"] = []bson.M{
+	endpoints := []bson.M{
 		{
 			"waypoints": bson.M{
 				"$elemMatch": bson.M{
-					"type": "pickup",
-					"for":  "driver",
-					"latitude": bson.M{
-						"$gte": round(body.From.Lat - 0.725),
-						"$lte": round(body.From.Lat + 0.725),
-					},
-					"longitude": bson.M{
-						"$gte": round(body.From.Lng - 0.725),
-						"$lte": round(body.From.Lng + 0.725),
-					},
+					"type":     "pickup",
+					"for":      "driver",
+					"location": geo.WithinMiles(from, radius),
 				},
 			},
 		},
 		{
 			"waypoints": bson.M{
 				"$elemMatch": bson.M{
-					"type": "destination",
-					"for":  "driver",
-					"latitude": bson.M{
-						"$gte": round(body.To.Lat - 0.725),
-						"$lte": round(body.To.Lat + 0.725),
-					},
-					"longitude": bson.M{
-						"$gte": round(body.To.Lng - 0.725),
-						"$lte": round(body.To.Lng + 0.725),
-					},
+					"type":     "destination",
+					"for":      "driver",
+					"location": geo.WithinMiles(to, radius),
 				},
 			},
 		},
 	}
 
-	if body.FlexibleDates != nil && *body.FlexibleDates {
-		// Filter the trips that are within 48 hours of the datetime
-		query["datetime"] = bson.M{
-			"$gte": datetime.Add(-24 * 28 * time.Hour),
-			"$lte": datetime.Add(24 * 28 * time.Hour),
-		}
-	} else {
-		// Filter the trips that are within 8 hours of the datetime
-		query["datetime"] = bson.M{
-			"$gte": datetime.Add(-24 * 7 * time.Hour),
-			"$lte": datetime.Add(24 * 7 * time.Hour),
-		}
+	corridor := []bson.M{
+		geo.NearRoute(from, detour),
+		geo.NearRoute(to, detour),
+	}
+
+	query["$or"] = []bson.M{
+		{"$and": endpoints},
+		{"$and": corridor},
+	}
+
+	// Filter the trips that are within a week of the datetime, or four weeks with flexible dates
+	window := alerts.Window(body.FlexibleDates)
+	query["datetime"] = bson.M{
+		"$gte": datetime.Add(-window),
+		"$lte": datetime.Add(window),
 	}
 
 	cursor, err := tripsCollection.Find(ctx, query)
@@ -176,37 +164,43 @@ func QueryTrips(req *http.Request, res http.ResponseWriter, ctx context.Context)
 	}
 
 	var newTrips []tripEntities.TripEntity
+	distances := map[string]geo.Match{}
 	// check if any trip is in the past
 	now := time.Now().UTC()
 	for _, trip := range trips {
-		if trip.Datetime.After(now) {
-
-			if *trip.Carpool {
-				newTrips = append(newTrips, trip)
-			} else {
-				acceptedRiders := 0
-
-				for _, rider := range trip.Riders {
-					if *rider.Accepted {
-						acceptedRiders++
-					}
-				}
-
-				if acceptedRiders == 0 {
-					newTrips = append(newTrips, trip)
-				}
-			}
+		// Hide trips that are in the past or have no seats left
+		if !trip.Datetime.After(now) || seats.Remaining(&trip) <= 0 {
+			continue
+		}
+
+		match, ok := geo.MatchRoute(&trip, from, to, radius, detour)
+		if !ok {
+			continue
 		}
-	}	
+
+		distances[*trip.TripUUID] = match
+		newTrips = append(newTrips, trip)
+	}
+
+	// Trips the driver barely has to go out of their way for come first
+	geo.SortByDetour(newTrips, distances)
 
 	if newTrips == nil {
 		newTrips = []tripEntities.TripEntity{}
 	}
 
+	// Save the search so the rider can find it again or turn it into an alert
+	var queryUUID *string
+	if rider, ok := req.Context().Value("rider").(*riderEntities.RiderEntity); ok && rider != nil {
+		queryUUID = recordQuery(ctx, rider, &body, datetime)
+	}
+
 	if len(newTrips) == 0 {
 		return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
 			"trips":   newTrips,
 			"driverProfiles": []string{},
+			"distances": distances,
+			"query_uuid": queryUUID,
 			"success": true,
 		})
 	}
@@ -227,6 +221,41 @@ func QueryTrips(req *http.Request, res http.ResponseWriter, ctx context.Context)
 	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
 		"trips":   newTrips,
 		"driverProfiles": driverProfiles,
+		"distances": distances,
+		"query_uuid": queryUUID,
 		"success": true,
 	})
 }
+
+// recordQuery adds the search to the rider's saved queries and returns its uuid
+func recordQuery(ctx context.Context, rider *riderEntities.RiderEntity, body *QueryTripsBodyRequest, datetime time.Time) *string {
+
+	from := &tripEntities.WaypointEntity{
+		Latitude:  ptr.Float64(body.From.Lat),
+		Longitude: ptr.Float64(body.From.Lng),
+	}
+	to := &tripEntities.WaypointEntity{
+		Latitude:  ptr.Float64(body.To.Lat),
+		Longitude: ptr.Float64(body.To.Lng),
+	}
+	geo.Locate(from, to)
+
+	queries, recorded := alerts.Record(rider.Queries, &riderEntities.RiderQueryEntity{
+		From:          from,
+		To:            to,
+		Date:          ptr.Time(datetime),
+		LastQueried:   ptr.Time(time.Now()),
+		Radius:        body.Radius,
+		FemalesOnly:   body.FemalesOnly,
+		FlexibleDates: body.FlexibleDates,
+	})
+
+	riderCollection := datastores.GetMongoDatabase(ctx).Collection(datastores.Riders)
+	_, err := riderCollection.UpdateOne(ctx, bson.M{"rider_uuid": rider.RiderUUID}, bson.M{"$set": bson.M{"queries": queries}})
+	if err != nil {
+		fmt.Println("Error saving rider query: ", err)
+		return nil
+	}
+
+	return recorded.QueryUUID
+}
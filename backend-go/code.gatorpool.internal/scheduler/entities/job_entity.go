@@ -0,0 +1,48 @@
+package entities
+
+import (
+	"time"
+)
+
+/*
+
+	Every instance runs the scheduler, but each job only runs on one of them at a time. An
+	instance takes a job by holding the lease on it, and writes down how each run went.
+
+	- LockedUntil: an instance that dies mid-run gives the job back once this passes
+	- NextRunAt: the job isn't due again until then, whichever instance ran it last
+
+*/
+
+type JobLeaseEntity struct {
+	// Name of the job, one lease per job
+	Job					*string			`json:"job,omitempty" bson:"job,omitempty"`
+
+	// The instance holding the lease
+	Owner				*string			`json:"owner,omitempty" bson:"owner,omitempty"`
+
+	LockedUntil			*time.Time		`json:"locked_until,omitempty" bson:"locked_until,omitempty"`
+	NextRunAt			*time.Time		`json:"next_run_at,omitempty" bson:"next_run_at,omitempty"`
+	LastRunAt			*time.Time		`json:"last_run_at,omitempty" bson:"last_run_at,omitempty"`
+
+	UpdatedAt			*time.Time		`json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+type JobRunEntity struct {
+	RunUUID				*string			`json:"run_uuid,omitempty" bson:"run_uuid,omitempty"`
+	Job					*string			`json:"job,omitempty" bson:"job,omitempty"`
+
+	// The instance that ran the job
+	Owner				*string			`json:"owner,omitempty" bson:"owner,omitempty"`
+
+	// succeeded, failed
+	Status				*string			`json:"status,omitempty" bson:"status,omitempty"`
+
+	// How many records the job changed or removed
+	Affected			*int64			`json:"affected,omitempty" bson:"affected,omitempty"`
+	Error				*string			`json:"error,omitempty" bson:"error,omitempty"`
+
+	StartedAt			*time.Time		`json:"started_at,omitempty" bson:"started_at,omitempty"`
+	FinishedAt			*time.Time		`json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+	DurationMS			*int64			`json:"duration_ms,omitempty" bson:"duration_ms,omitempty"`
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+
+	"code.gatorpool.internal/scheduler"
+	"code.gatorpool.internal/util"
+)
+
+// ListJobRuns returns a page of the scheduled job run history, newest first, optionally for one job
+func ListJobRuns(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	query := req.URL.Query()
+
+	// Parse page number from query parameters
+	page := 1
+	if pageStr := query.Get("page"); pageStr != "" {
+		if parsedPage, err := strconv.Atoi(pageStr); err == nil && parsedPage > 0 {
+			page = parsedPage
+		}
+	}
+
+	runs, total, err := scheduler.Runs(ctx, query.Get("job"), page)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	jobs := []string{}
+	for _, job := range scheduler.Jobs() {
+		jobs = append(jobs, job.Name)
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"jobs":        jobs,
+		"runs":        runs,
+		"currentPage": page,
+		"totalPages":  int(math.Ceil(float64(total) / float64(scheduler.RunsPerPage))),
+		"success":     true,
+	})
+}
@@ -0,0 +1,39 @@
+package scheduler
+
+import (
+	"context"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ensureIndexes creates the indexes the scheduler relies on. Leases are only exclusive with the unique
+// index on job, so the scheduler doesn't run without it.
+func ensureIndexes(ctx context.Context) error {
+	db := datastores.GetMongoDatabase(ctx)
+
+	// One lease per scheduled job, so only one instance can take it
+	_, err := db.Collection(datastores.JobLeases).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "job", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Collection(datastores.JobRuns).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "job", Value: 1}, {Key: "started_at", Value: -1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Run history is kept for a month
+	_, err = db.Collection(datastores.JobRuns).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "started_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(60 * 60 * 24 * 30),
+	})
+	return err
+}
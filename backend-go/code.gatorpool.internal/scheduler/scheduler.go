@@ -0,0 +1,217 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	schedulerEntities "code.gatorpool.internal/scheduler/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/pborman/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	RunSucceeded = "succeeded"
+	RunFailed    = "failed"
+
+	// How long a run can hold a job before another instance may take it over
+	LockFor = time.Minute * 15
+
+	RunsPerPage = 25
+)
+
+var (
+	ErrJobNameRequired = errors.New("job needs a name")
+	ErrJobInterval     = errors.New("job needs an interval")
+	ErrJobFunc         = errors.New("job needs something to run")
+	ErrDuplicateJob    = errors.New("job is already registered")
+)
+
+// Job is housekeeping that runs every Every on one instance at a time. Run returns how many
+// records it changed or removed, which goes in the run history.
+type Job struct {
+	Name  string
+	Every time.Duration
+	Run   func(ctx context.Context, now time.Time) (int64, error)
+}
+
+var (
+	mu   sync.Mutex
+	jobs = []Job{}
+
+	// Tells this instance's leases apart from everyone else's
+	owner = instanceName()
+)
+
+// Register adds a job to the scheduler
+func Register(job Job) error {
+	if job.Name == "" {
+		return ErrJobNameRequired
+	}
+	if job.Every <= 0 {
+		return ErrJobInterval
+	}
+	if job.Run == nil {
+		return ErrJobFunc
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, registered := range jobs {
+		if registered.Name == job.Name {
+			return ErrDuplicateJob
+		}
+	}
+
+	jobs = append(jobs, job)
+	return nil
+}
+
+// Jobs returns the registered jobs
+func Jobs() []Job {
+	mu.Lock()
+	defer mu.Unlock()
+	return append([]Job{}, jobs...)
+}
+
+// Run checks every tick for jobs that are due and runs the ones this instance gets the lease on,
+// until ctx is cancelled. It returns straight away if the lease index can't be created, since jobs
+// could then run on every instance at once.
+func Run(ctx context.Context, tick time.Duration) error {
+	if err := ensureIndexes(ctx); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		for _, job := range Jobs() {
+			if err := runIfDue(ctx, job, time.Now()); err != nil {
+				fmt.Println("Error running job "+job.Name+": ", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// runIfDue runs the job if it's due and nobody else has it
+func runIfDue(ctx context.Context, job Job, now time.Time) error {
+	acquired, err := acquire(ctx, job.Name, now)
+	if err != nil || !acquired {
+		return err
+	}
+
+	affected, runErr := job.Run(ctx, now)
+	finishedAt := time.Now()
+
+	run := &schedulerEntities.JobRunEntity{
+		RunUUID:    ptr.String(uuid.NewRandom().String()),
+		Job:        ptr.String(job.Name),
+		Owner:      ptr.String(owner),
+		Status:     ptr.String(RunSucceeded),
+		Affected:   ptr.Int64(affected),
+		StartedAt:  ptr.Time(now),
+		FinishedAt: ptr.Time(finishedAt),
+		DurationMS: ptr.Int64(finishedAt.Sub(now).Milliseconds()),
+	}
+	if runErr != nil {
+		run.Status = ptr.String(RunFailed)
+		run.Error = ptr.String(runErr.Error())
+	}
+
+	db := datastores.GetMongoDatabase(ctx)
+
+	if _, err := db.Collection(datastores.JobRuns).InsertOne(ctx, run); err != nil {
+		fmt.Println("Error recording run of job "+job.Name+": ", err)
+	}
+
+	// A failed run is due again on the next interval like any other, rather than retried straight away
+	_, err = db.Collection(datastores.JobLeases).UpdateOne(ctx, bson.M{"job": job.Name, "owner": owner}, bson.M{
+		"$set": bson.M{
+			"next_run_at": now.Add(job.Every),
+			"last_run_at": now,
+			"updated_at":  finishedAt,
+		},
+		"$unset": bson.M{"locked_until": ""},
+	})
+	return err
+}
+
+// acquire takes the lease on the job if it's due and no other instance holds it. The first
+// instance to see a job creates its lease.
+func acquire(ctx context.Context, name string, now time.Time) (bool, error) {
+	_, err := datastores.GetMongoDatabase(ctx).Collection(datastores.JobLeases).UpdateOne(ctx, bson.M{
+		"job": name,
+		"$and": []bson.M{
+			{"$or": []bson.M{{"next_run_at": nil}, {"next_run_at": bson.M{"$lte": now}}}},
+			{"$or": []bson.M{{"locked_until": nil}, {"locked_until": bson.M{"$lte": now}}}},
+		},
+	}, bson.M{"$set": bson.M{
+		"owner":        owner,
+		"locked_until": now.Add(LockFor),
+		"updated_at":   now,
+	}}, options.Update().SetUpsert(true))
+
+	// The lease exists but isn't free, so the upsert ran into the unique index on job
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Runs returns a page of the run history, newest first, and how many runs there are in total.
+// An empty job returns runs of every job.
+func Runs(ctx context.Context, job string, page int) ([]*schedulerEntities.JobRunEntity, int64, error) {
+	runsCollection := datastores.GetMongoDatabase(ctx).Collection(datastores.JobRuns)
+
+	query := bson.M{}
+	if job != "" {
+		query["job"] = job
+	}
+
+	total, err := runsCollection.CountDocuments(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "started_at", Value: -1}}).
+		SetSkip(int64((page - 1) * RunsPerPage)).
+		SetLimit(RunsPerPage)
+
+	cursor, err := runsCollection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	runs := []*schedulerEntities.JobRunEntity{}
+	if err := cursor.All(ctx, &runs); err != nil {
+		return nil, 0, err
+	}
+
+	return runs, total, nil
+}
+
+// instanceName is the host this is running on, with a random suffix so two processes on one host
+// don't share leases
+func instanceName() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "instance"
+	}
+	return hostname + "-" + uuid.NewRandom().String()[:8]
+}
@@ -0,0 +1,38 @@
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegister(t *testing.T) {
+	jobs = []Job{}
+	t.Cleanup(func() { jobs = []Job{} })
+
+	run := func(ctx context.Context, now time.Time) (int64, error) { return 0, nil }
+
+	tests := []struct {
+		Name string
+		Job  Job
+		Err  error
+	}{
+		{"Valid job", Job{Name: "purge", Every: time.Hour, Run: run}, nil},
+		{"Same name again", Job{Name: "purge", Every: time.Minute, Run: run}, ErrDuplicateJob},
+		{"No name", Job{Every: time.Hour, Run: run}, ErrJobNameRequired},
+		{"No interval", Job{Name: "expire", Run: run}, ErrJobInterval},
+		{"Nothing to run", Job{Name: "expire", Every: time.Hour}, ErrJobFunc},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Err, Register(tt.Job))
+		})
+	}
+
+	registered := Jobs()
+	assert.Len(t, registered, 1)
+	assert.Equal(t, time.Hour, registered[0].Every)
+}
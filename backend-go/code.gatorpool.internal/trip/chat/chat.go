@@ -0,0 +1,168 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/lifecycle"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/pborman/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// Longest message, in characters
+	MaxLength = 1000
+
+	// How long messages are kept after the trip ends
+	Retention = time.Hour * 24 * 30
+
+	// Messages returned per page of history
+	DefaultPageSize = 50
+	MaxPageSize     = 100
+)
+
+var (
+	ErrEmptyMessage   = errors.New("message is empty")
+	ErrMessageTooLong = errors.New("message is too long")
+)
+
+// Members is everyone who can use the trip's chat, the assigned driver and accepted riders
+func Members(trip *tripEntities.TripEntity) []string {
+	members := []string{}
+	if trip.AssignedDriver != nil && trip.AssignedDriver.UserUUID != nil {
+		members = append(members, *trip.AssignedDriver.UserUUID)
+	}
+	for _, rider := range trip.Riders {
+		if rider != nil && rider.UserUUID != nil && rider.Accepted != nil && *rider.Accepted {
+			members = append(members, *rider.UserUUID)
+		}
+	}
+	return members
+}
+
+// IsMember reports whether the user can read the trip's chat
+func IsMember(trip *tripEntities.TripEntity, userUUID string) bool {
+	for _, member := range Members(trip) {
+		if member == userUUID {
+			return true
+		}
+	}
+	return false
+}
+
+// Open reports whether messages can still be sent, the chat is read-only once the trip is over
+func Open(trip *tripEntities.TripEntity) bool {
+	return trip.Status == nil || !lifecycle.IsFinal(*trip.Status)
+}
+
+// Validate trims the message and checks it isn't empty or too long
+func Validate(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", ErrEmptyMessage
+	}
+	if utf8.RuneCountInString(body) > MaxLength {
+		return "", ErrMessageTooLong
+	}
+	return body, nil
+}
+
+// PageSize is the number of messages to return for a requested limit
+func PageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return limit
+}
+
+// Send saves a message from the sender on the trip
+func Send(ctx context.Context, tripUUID string, senderUUID string, body string) (*tripEntities.TripMessageEntity, error) {
+	message := &tripEntities.TripMessageEntity{
+		MessageUUID: ptr.String(uuid.NewRandom().String()),
+		TripUUID:    &tripUUID,
+		SenderUUID:  &senderUUID,
+		Body:        &body,
+		ReadBy:      []*tripEntities.TripMessageReadEntity{},
+		CreatedAt:   ptr.Time(time.Now()),
+	}
+
+	_, err := datastores.GetMongoDatabase(ctx).Collection(datastores.TripMessages).InsertOne(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// History returns a page of the trip's messages sent before the given time, newest first
+func History(ctx context.Context, tripUUID string, before *time.Time, limit int) ([]*tripEntities.TripMessageEntity, error) {
+	query := bson.M{"trip_uuid": tripUUID}
+	if before != nil {
+		query["created_at"] = bson.M{"$lt": *before}
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetLimit(int64(PageSize(limit)))
+
+	cursor, err := datastores.GetMongoDatabase(ctx).Collection(datastores.TripMessages).Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := []*tripEntities.TripMessageEntity{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// MarkRead adds a read receipt from the user to every message on the trip sent by someone else
+// up to through, and returns how many were newly read
+func MarkRead(ctx context.Context, tripUUID string, userUUID string, through time.Time) (int64, error) {
+	result, err := datastores.GetMongoDatabase(ctx).Collection(datastores.TripMessages).UpdateMany(ctx, bson.M{
+		"trip_uuid":         tripUUID,
+		"sender_uuid":       bson.M{"$ne": userUUID},
+		"read_by.user_uuid": bson.M{"$ne": userUUID},
+		"created_at":        bson.M{"$lte": through},
+	}, bson.M{"$push": bson.M{
+		"read_by": &tripEntities.TripMessageReadEntity{
+			UserUUID: &userUUID,
+			ReadAt:   ptr.Time(time.Now()),
+		},
+	}})
+	if err != nil {
+		return 0, err
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// Unread is how many messages on the trip the user hasn't read
+func Unread(ctx context.Context, tripUUID string, userUUID string) (int64, error) {
+	return datastores.GetMongoDatabase(ctx).Collection(datastores.TripMessages).CountDocuments(ctx, bson.M{
+		"trip_uuid":         tripUUID,
+		"sender_uuid":       bson.M{"$ne": userUUID},
+		"read_by.user_uuid": bson.M{"$ne": userUUID},
+	})
+}
+
+// Expire starts the retention window on the trip's messages, called when the trip ends
+func Expire(ctx context.Context, tripUUID string, endedAt time.Time) error {
+	_, err := datastores.GetMongoDatabase(ctx).Collection(datastores.TripMessages).UpdateMany(ctx, bson.M{
+		"trip_uuid":  tripUUID,
+		"expires_at": nil,
+	}, bson.M{"$set": bson.M{"expires_at": endedAt.Add(Retention)}})
+	return err
+}
@@ -0,0 +1,59 @@
+package chat
+
+import (
+	"strings"
+	"testing"
+
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMembers(t *testing.T) {
+	trip := &tripEntities.TripEntity{
+		Status:         ptr.String("active"),
+		AssignedDriver: &tripEntities.TripAssignedDriverEntity{UserUUID: ptr.String("driver")},
+		Riders: []*tripEntities.TripRiderEntity{
+			{UserUUID: ptr.String("accepted"), Accepted: ptr.Bool(true)},
+			{UserUUID: ptr.String("requested"), Accepted: ptr.Bool(false)},
+		},
+	}
+
+	assert.Equal(t, []string{"driver", "accepted"}, Members(trip))
+	assert.True(t, IsMember(trip, "accepted"))
+	assert.False(t, IsMember(trip, "requested"))
+	assert.False(t, IsMember(trip, "stranger"))
+
+	assert.True(t, Open(trip))
+	trip.Status = ptr.String("completed")
+	assert.False(t, Open(trip))
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Body     string
+		Expected string
+		Err      error
+	}{
+		{"Plain message", "I'm by the bus stop", "I'm by the bus stop", nil},
+		{"Whitespace is trimmed", "  running late \n", "running late", nil},
+		{"Empty", "   ", "", ErrEmptyMessage},
+		{"Longest allowed", strings.Repeat("é", MaxLength), strings.Repeat("é", MaxLength), nil},
+		{"Too long", strings.Repeat("a", MaxLength+1), "", ErrMessageTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			body, err := Validate(tt.Body)
+			assert.Equal(t, tt.Err, err)
+			assert.Equal(t, tt.Expected, body)
+		})
+	}
+}
+
+func TestPageSize(t *testing.T) {
+	assert.Equal(t, DefaultPageSize, PageSize(0))
+	assert.Equal(t, 10, PageSize(10))
+	assert.Equal(t, MaxPageSize, PageSize(MaxPageSize+1))
+}
@@ -0,0 +1,286 @@
+package conflict
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"time"
+
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/fare"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/pborman/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	TypeFareChanged    = "fare_changed"
+	TypeDetailsChanged = "details_changed"
+
+	// How each rider has responded to a change
+	StatusPending  = "pending"
+	StatusAccepted = "accepted"
+	StatusLeft     = "left"
+
+	// A rider's share going up by at least this much, and by at least this fraction of what it
+	// was, is a material increase, and leaving over it is noted on their left event
+	MaterialAmount  = 1.00
+	MaterialPercent = 0.10
+)
+
+var (
+	ErrConflictNotFound = errors.New("conflict not found")
+	ErrNotAffected      = errors.New("this change doesn't affect you")
+	ErrAlreadyResponded = errors.New("you already responded to this change")
+)
+
+// New records what the edit changed about the trip, for the riders who were accepted before it.
+// It returns nil if nothing riders agreed to was changed.
+func New(before *tripEntities.TripEntity, after *tripEntities.TripEntity, changedBy string, now time.Time) *tripEntities.TripConflictEntity {
+	oldValues, changedValues := Diff(before, after)
+	if len(changedValues) == 0 {
+		return nil
+	}
+
+	conflictType := TypeDetailsChanged
+	for key := range changedValues {
+		if strings.HasPrefix(key, "fare.") {
+			conflictType = TypeFareChanged
+			break
+		}
+	}
+
+	oldShares := fare.Shares(before)
+	newShares := fare.Shares(after)
+
+	riders := []*tripEntities.TripConflictRiderEntity{}
+	for _, rider := range before.Riders {
+		if rider == nil || rider.UserUUID == nil || rider.Accepted == nil || !*rider.Accepted {
+			continue
+		}
+
+		oldShare := shareTotal(oldShares, *rider.UserUUID)
+		newShare := shareTotal(newShares, *rider.UserUUID)
+		riders = append(riders, &tripEntities.TripConflictRiderEntity{
+			UserUUID:         ptr.String(*rider.UserUUID),
+			Status:           ptr.String(StatusPending),
+			OldShare:         ptr.Float64(oldShare),
+			NewShare:         ptr.Float64(newShare),
+			MaterialIncrease: ptr.Bool(MaterialIncrease(oldShare, newShare)),
+		})
+	}
+
+	return &tripEntities.TripConflictEntity{
+		ConflictUUID:  ptr.String(uuid.NewRandom().String()),
+		Type:          ptr.String(conflictType),
+		ConflictAt:    ptr.Time(now),
+		OldValues:     oldValues,
+		ChangedValues: changedValues,
+		ChangedBy:     ptr.String(changedBy),
+		Riders:        riders,
+	}
+}
+
+// Diff returns the old and new values of the fare, carpool and miscellaneous settings that were
+// changed, keyed by their dotted path, e.g. fare.gas. Shares follow from the rest so they're left out.
+func Diff(before *tripEntities.TripEntity, after *tripEntities.TripEntity) (map[string]interface{}, map[string]interface{}) {
+	oldFlat := flatten(before)
+	newFlat := flatten(after)
+
+	oldValues := map[string]interface{}{}
+	changedValues := map[string]interface{}{}
+	for key, value := range newFlat {
+		if old, ok := oldFlat[key]; !ok || !reflect.DeepEqual(old, value) {
+			oldValues[key] = oldFlat[key]
+			changedValues[key] = value
+		}
+	}
+	for key, old := range oldFlat {
+		if _, ok := newFlat[key]; !ok {
+			oldValues[key] = old
+			changedValues[key] = nil
+		}
+	}
+
+	return oldValues, changedValues
+}
+
+// MaterialIncrease reports whether a share going from old to new is a big enough increase
+func MaterialIncrease(old float64, new float64) bool {
+	increase := fare.Round(new - old)
+	return increase >= math.Max(MaterialAmount, fare.Round(old*MaterialPercent))
+}
+
+// Pending is the changes the rider still has to accept or leave over
+func Pending(trip *tripEntities.TripEntity, userUUID string) []*tripEntities.TripConflictEntity {
+	pending := []*tripEntities.TripConflictEntity{}
+	for _, conflict := range trip.Conflicts {
+		if response := findRider(conflict, userUUID); response != nil && isPending(response) {
+			pending = append(pending, conflict)
+		}
+	}
+	return pending
+}
+
+// Unresolved is the changes any rider still on the trip hasn't responded to
+func Unresolved(trip *tripEntities.TripEntity) []*tripEntities.TripConflictEntity {
+	unresolved := []*tripEntities.TripConflictEntity{}
+	for _, conflict := range trip.Conflicts {
+		for _, userUUID := range riderUUIDs(trip) {
+			if response := findRider(conflict, userUUID); response != nil && isPending(response) {
+				unresolved = append(unresolved, conflict)
+				break
+			}
+		}
+	}
+	return unresolved
+}
+
+// Blocking is the riders still on the trip who haven't responded to a change. The trip can't
+// start until they accept or leave.
+func Blocking(trip *tripEntities.TripEntity) []string {
+	blocking := []string{}
+	for _, userUUID := range riderUUIDs(trip) {
+		if len(Pending(trip, userUUID)) > 0 {
+			blocking = append(blocking, userUUID)
+		}
+	}
+	return blocking
+}
+
+// Excused reports whether a change the rider hasn't accepted put their share up materially, so
+// their left event can say that's why they left
+func Excused(trip *tripEntities.TripEntity, userUUID string) bool {
+	for _, conflict := range Pending(trip, userUUID) {
+		response := findRider(conflict, userUUID)
+		if response.MaterialIncrease != nil && *response.MaterialIncrease {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns the rider's response to the change if they still have to make it
+func Check(trip *tripEntities.TripEntity, conflictUUID string, userUUID string) (*tripEntities.TripConflictRiderEntity, error) {
+	for _, conflict := range trip.Conflicts {
+		if conflict == nil || conflict.ConflictUUID == nil || *conflict.ConflictUUID != conflictUUID {
+			continue
+		}
+
+		response := findRider(conflict, userUUID)
+		if response == nil {
+			return nil, ErrNotAffected
+		}
+		if !isPending(response) {
+			return nil, ErrAlreadyResponded
+		}
+		return response, nil
+	}
+
+	return nil, ErrConflictNotFound
+}
+
+// Respond records the rider accepting the change or leaving over it
+func Respond(trip *tripEntities.TripEntity, conflictUUID string, userUUID string, status string, now time.Time) (*tripEntities.TripConflictRiderEntity, error) {
+	response, err := Check(trip, conflictUUID, userUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	response.Status = ptr.String(status)
+	response.RespondedAt = ptr.Time(now)
+	return response, nil
+}
+
+// Resolve marks every change the rider hasn't responded to with the status, e.g. when they
+// leave the trip
+func Resolve(trip *tripEntities.TripEntity, userUUID string, status string, now time.Time) {
+	for _, conflict := range Pending(trip, userUUID) {
+		response := findRider(conflict, userUUID)
+		response.Status = ptr.String(status)
+		response.RespondedAt = ptr.Time(now)
+	}
+}
+
+// flatten is the settings riders agree to when they join, keyed by dotted path
+func flatten(trip *tripEntities.TripEntity) map[string]interface{} {
+	flat := map[string]interface{}{}
+
+	if trip.Fare != nil {
+		terms := *trip.Fare
+		terms.Shares = nil
+		flattenInto(flat, "fare", terms)
+	}
+	if trip.Carpool != nil {
+		flat["carpool"] = *trip.Carpool
+	}
+	if trip.Miscellaneous != nil {
+		flattenInto(flat, "miscellaneous", trip.Miscellaneous)
+	}
+
+	return flat
+}
+
+func flattenInto(flat map[string]interface{}, prefix string, value interface{}) {
+	raw, err := bson.Marshal(value)
+	if err != nil {
+		return
+	}
+
+	var doc bson.M
+	if err := bson.Unmarshal(raw, &doc); err != nil {
+		return
+	}
+
+	flattenMap(flat, prefix, doc)
+}
+
+func flattenMap(flat map[string]interface{}, prefix string, doc bson.M) {
+	for key, value := range doc {
+		path := prefix + "." + key
+		switch nested := value.(type) {
+		case bson.M:
+			flattenMap(flat, path, nested)
+		case nil:
+		default:
+			flat[path] = value
+		}
+	}
+}
+
+func findRider(conflict *tripEntities.TripConflictEntity, userUUID string) *tripEntities.TripConflictRiderEntity {
+	if conflict == nil {
+		return nil
+	}
+	for _, response := range conflict.Riders {
+		if response != nil && response.UserUUID != nil && *response.UserUUID == userUUID {
+			return response
+		}
+	}
+	return nil
+}
+
+func isPending(response *tripEntities.TripConflictRiderEntity) bool {
+	return response.Status == nil || *response.Status == StatusPending
+}
+
+// riderUUIDs is the riders accepted on the trip now
+func riderUUIDs(trip *tripEntities.TripEntity) []string {
+	uuids := []string{}
+	for _, rider := range trip.Riders {
+		if rider != nil && rider.UserUUID != nil && rider.Accepted != nil && *rider.Accepted {
+			uuids = append(uuids, *rider.UserUUID)
+		}
+	}
+	return uuids
+}
+
+func shareTotal(shares []*tripEntities.TripFareShareEntity, userUUID string) float64 {
+	for _, share := range shares {
+		if share != nil && share.UserUUID != nil && *share.UserUUID == userUUID && share.Total != nil {
+			return *share.Total
+		}
+	}
+	return 0
+}
@@ -0,0 +1,119 @@
+package conflict
+
+import (
+	"testing"
+	"time"
+
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/fare"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTrip(tripFare float64) *tripEntities.TripEntity {
+	trip := &tripEntities.TripEntity{
+		Carpool: ptr.Bool(true),
+		Fare: &tripEntities.TripFareEntity{
+			Gas:  ptr.Float64(0),
+			Food: ptr.Float64(0),
+			Trip: ptr.Float64(tripFare),
+		},
+		Miscellaneous: &tripEntities.TripMiscellaneousEntity{
+			Music: &tripEntities.TripMiscellaneousMusicOptionsEntity{CanBeControlled: ptr.Bool(true)},
+		},
+		Riders: []*tripEntities.TripRiderEntity{
+			{UserUUID: ptr.String("jack"), Accepted: ptr.Bool(true)},
+			{UserUUID: ptr.String("jill"), Accepted: ptr.Bool(true)},
+			{UserUUID: ptr.String("requested"), Accepted: ptr.Bool(false)},
+		},
+	}
+	fare.Split(trip)
+	return trip
+}
+
+func edited(trip *tripEntities.TripEntity, tripFare float64) *tripEntities.TripEntity {
+	after := *trip
+	after.Fare = &tripEntities.TripFareEntity{
+		Gas:  trip.Fare.Gas,
+		Food: trip.Fare.Food,
+		Trip: ptr.Float64(tripFare),
+	}
+	fare.Split(&after)
+	return &after
+}
+
+func TestDiff(t *testing.T) {
+	before := newTrip(20)
+	after := edited(before, 30)
+	after.Miscellaneous = &tripEntities.TripMiscellaneousEntity{
+		Music: &tripEntities.TripMiscellaneousMusicOptionsEntity{CanBeControlled: ptr.Bool(false)},
+	}
+
+	oldValues, changedValues := Diff(before, after)
+	assert.Equal(t, map[string]interface{}{
+		"fare.trip":                             30.0,
+		"miscellaneous.music.can_be_controlled": false,
+	}, changedValues)
+	assert.Equal(t, 20.0, oldValues["fare.trip"])
+
+	_, changedValues = Diff(before, edited(before, 20))
+	assert.Empty(t, changedValues)
+}
+
+func TestNew(t *testing.T) {
+	now := time.Now()
+	before := newTrip(20)
+
+	assert.Nil(t, New(before, edited(before, 20), "driver", now))
+
+	conflict := New(before, edited(before, 30), "driver", now)
+	assert.Equal(t, TypeFareChanged, *conflict.Type)
+	assert.Len(t, conflict.Riders, 2)
+	assert.Equal(t, 10.0, *conflict.Riders[0].OldShare)
+	assert.Equal(t, 15.0, *conflict.Riders[0].NewShare)
+	assert.True(t, *conflict.Riders[0].MaterialIncrease)
+
+	before.Conflicts = []*tripEntities.TripConflictEntity{conflict}
+	assert.Equal(t, []string{"jack", "jill"}, Blocking(before))
+	assert.True(t, Excused(before, "jack"))
+
+	_, err := Respond(before, *conflict.ConflictUUID, "jack", StatusAccepted, now)
+	assert.Nil(t, err)
+	_, err = Respond(before, *conflict.ConflictUUID, "jack", StatusAccepted, now)
+	assert.Equal(t, ErrAlreadyResponded, err)
+	_, err = Respond(before, *conflict.ConflictUUID, "requested", StatusAccepted, now)
+	assert.Equal(t, ErrNotAffected, err)
+	_, err = Respond(before, "missing", "jill", StatusAccepted, now)
+	assert.Equal(t, ErrConflictNotFound, err)
+
+	assert.False(t, Excused(before, "jack"))
+	assert.Empty(t, Pending(before, "jack"))
+	assert.Equal(t, []string{"jill"}, Blocking(before))
+
+	Resolve(before, "jill", StatusLeft, now)
+	assert.Empty(t, Blocking(before))
+	assert.Empty(t, Unresolved(before))
+}
+
+func TestMaterialIncrease(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Old      float64
+		New      float64
+		Expected bool
+	}{
+		{"Cheaper", 20, 15, false},
+		{"Same", 20, 20, false},
+		{"Small increase", 20, 21, false},
+		{"Ten percent", 20, 22, true},
+		{"Under a dollar on a small share", 5, 5.75, false},
+		{"A dollar on a small share", 5, 6, true},
+		{"From free", 0, 5, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, MaterialIncrease(tt.Old, tt.New))
+		})
+	}
+}
@@ -5,6 +5,9 @@ import (
 )
 
 type TripConflictEntity struct {
+	// Unique identifier for the conflict
+	ConflictUUID		*string							`json:"conflict_uuid,omitempty" bson:"conflict_uuid,omitempty"`
+
 	// Type of conflict: fare_changed, driver_cancelled, driver_changed, date_change, etc.
 	Type				*string							`json:"type,omitempty" bson:"type"`
 
@@ -16,4 +19,26 @@ type TripConflictEntity struct {
 
 	// New values of the trip
 	ChangedValues		map[string]interface{}			`json:"changed_values,omitempty" bson:"changed_values"`
+
+	// The UserUUID of whoever made the change
+	ChangedBy			*string							`json:"changed_by,omitempty" bson:"changed_by,omitempty"`
+
+	// The accepted riders at the time of the change, who have to accept it or leave
+	Riders				[]*TripConflictRiderEntity		`json:"riders,omitempty" bson:"riders,omitempty"`
+}
+
+type TripConflictRiderEntity struct {
+	UserUUID			*string							`json:"user_uuid,omitempty" bson:"user_uuid"`
+
+	// pending, accepted, left
+	Status				*string							`json:"status,omitempty" bson:"status"`
+
+	// The rider's share of the fare before and after the change
+	OldShare			*float64						`json:"old_share,omitempty" bson:"old_share"`
+	NewShare			*float64						`json:"new_share,omitempty" bson:"new_share"`
+
+	// Whether their share went up enough that they can leave without a warning
+	MaterialIncrease	*bool							`json:"material_increase,omitempty" bson:"material_increase"`
+
+	RespondedAt			*time.Time						`json:"responded_at,omitempty" bson:"responded_at,omitempty"`
 }
\ No newline at end of file
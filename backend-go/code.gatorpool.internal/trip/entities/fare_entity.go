@@ -16,4 +16,31 @@ type TripFareEntity struct {
 	Gas					*float64						`json:"gas,omitempty" bson:"gas"`
 	Trip 				*float64						`json:"trip,omitempty" bson:"trip"`
 	Food				*float64						`json:"food,omitempty" bson:"food"`
+
+	// How the fare is split between riders: equal, distance
+	Split				*string							`json:"split,omitempty" bson:"split,omitempty"`
+
+	// What each accepted rider owes, worked out again whenever riders join or leave
+	Shares				[]*TripFareShareEntity			`json:"shares,omitempty" bson:"shares,omitempty"`
+}
+
+/*
+	Each component is only split between the riders willing to pay for it, so with
+	the example above and two riders where only Jack pays for food:
+
+	- Jack: $10 gas, $10 food, $15 trip -> $35 total
+	- Jill: $10 gas, $0 food, $15 trip -> $25 total
+*/
+
+type TripFareShareEntity struct {
+	// The UserUUID of the rider
+	UserUUID			*string							`json:"user_uuid,omitempty" bson:"user_uuid"`
+
+	Gas					*float64						`json:"gas,omitempty" bson:"gas"`
+	Food				*float64						`json:"food,omitempty" bson:"food"`
+	Trip				*float64						`json:"trip,omitempty" bson:"trip"`
+	Total				*float64						`json:"total,omitempty" bson:"total"`
+
+	// The miles the rider is in the car for, used to weigh distance splits
+	Miles				*float64						`json:"miles,omitempty" bson:"miles,omitempty"`
 }
\ No newline at end of file
@@ -2,15 +2,23 @@ package entities
 
 import (
 	"time"
+
+	driverEntities "code.gatorpool.internal/driver/entities"
 )
 
 type TripEntity struct {
 	// Unique identifier for the trip
 	TripUUID			*string							`json:"trip_uuid,omitempty" bson:"trip_uuid,omitempty"`
+
+	// The recurring series this trip is an occurrence of, if any
+	SeriesUUID			*string							`json:"series_uuid,omitempty" bson:"series_uuid,omitempty"`
 	
 	// Waypoints for the trip (driver destination, rider pickup/dropoff)
 	Waypoints			[]*WaypointEntity				`json:"waypoints,omitempty" bson:"waypoints,omitempty"`
 
+	// GeoJSON line through the driver's waypoints, used for matching riders along the way
+	Route				*GeoLineEntity					`json:"route,omitempty" bson:"route,omitempty"`
+
 	// The assigned driver for a trip, since riders can post ride requests
 	AssignedDriver		*TripAssignedDriverEntity		`json:"assigned_driver,omitempty" bson:"assigned_driver,omitempty"`
 
@@ -37,9 +45,17 @@ type TripEntity struct {
 	// The expected datetime of the trip
 	Datetime			*time.Time						`json:"datetime,omitempty" bson:"datetime,omitempty"`
 
-	// Current location of the driver, updated everytime driver loads page/does anything on the page
+	// The pickup until the trip completes, then the destination. The driver's live position while
+	// the trip is active is kept encrypted in trip_locations.
 	CurrentLocation		*WaypointEntity					`json:"current_location,omitempty" bson:"current_location,omitempty"`
 
+	// Snapshot of the vehicle used for the trip, taken when the driver is assigned
+	Vehicle				*driverEntities.VehicleEntity	`json:"vehicle,omitempty" bson:"vehicle,omitempty"`
+
+	// The number of riders the trip can take, and how many more can still be accepted
+	Seats				*int							`json:"seats,omitempty" bson:"seats,omitempty"`
+	SeatsRemaining		*int							`json:"seats_remaining,omitempty" bson:"seats_remaining,omitempty"`
+
 	// Riders for the trip
 	Riders				[]*TripRiderEntity				`json:"riders,omitempty" bson:"riders,omitempty"`
 
@@ -60,6 +76,10 @@ type TripEntity struct {
 
 	MaxRadiusDropOff	*float64						`json:"max_radius_dropoff,omitempty" bson:"max_radius_dropoff,omitempty"`
 
+	// Timestamps of when the driver started and completed the trip
+	StartedAt			*time.Time						`json:"started_at,omitempty" bson:"started_at,omitempty"`
+	CompletedAt			*time.Time						`json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+
 	// Fields for auditing
 	CreatedAt			*time.Time						`json:"created_at,omitempty" bson:"created_at,omitempty"`
 	UpdatedAt			*time.Time						`json:"updated_at,omitempty" bson:"updated_at,omitempty"`
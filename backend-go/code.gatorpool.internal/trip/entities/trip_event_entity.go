@@ -0,0 +1,38 @@
+package entities
+
+import (
+	"time"
+)
+
+/*
+
+	An entry in a trip's history. Every handler that changes a trip appends one, and they are
+	never updated or deleted, so support can see who did what to the trip and when.
+
+*/
+
+type TripEventEntity struct {
+	// Unique identifier for the event
+	EventUUID			*string							`json:"event_uuid,omitempty" bson:"event_uuid,omitempty"`
+
+	// The trip the event happened on
+	TripUUID			*string							`json:"trip_uuid,omitempty" bson:"trip_uuid,omitempty"`
+
+	// created, requested, accepted, rejected, removed, left, edited, changes_accepted, started, cancelled, completed
+	Type				*string							`json:"type,omitempty" bson:"type,omitempty"`
+
+	// Who did it
+	ActorUUID			*string							`json:"actor_uuid,omitempty" bson:"actor_uuid,omitempty"`
+
+	// Who it was done to, a rider or driver. Empty for events about the whole trip
+	SubjectUUID			*string							`json:"subject_uuid,omitempty" bson:"subject_uuid,omitempty"`
+
+	// Why it happened, if we know
+	Reason				*string							`json:"reason,omitempty" bson:"reason,omitempty"`
+
+	// What an edit changed, in the same shape as a conflict
+	OldValues			map[string]interface{}			`json:"old_values,omitempty" bson:"old_values,omitempty"`
+	ChangedValues		map[string]interface{}			`json:"changed_values,omitempty" bson:"changed_values,omitempty"`
+
+	CreatedAt			*time.Time						`json:"created_at,omitempty" bson:"created_at,omitempty"`
+}
@@ -0,0 +1,36 @@
+package entities
+
+import (
+	"time"
+)
+
+/*
+
+	A location ping the driver sends while the trip is active. The coordinates are encrypted
+	with guardian/encryption.LocationEncryption, and pings are deleted once the trip ends.
+
+*/
+
+type TripLocationEntity struct {
+	// The trip the driver is on
+	TripUUID			*string							`json:"trip_uuid,omitempty" bson:"trip_uuid,omitempty"`
+
+	// The driver who sent the ping
+	DriverUUID			*string							`json:"driver_uuid,omitempty" bson:"driver_uuid,omitempty"`
+
+	// Encrypted coordinates
+	Latitude			*string							`json:"-" bson:"latitude,omitempty"`
+	Longitude			*string							`json:"-" bson:"longitude,omitempty"`
+
+	// Symmetric key version the coordinates were encrypted with
+	EncryptedVersion	*int32							`json:"-" bson:"encrypted_version,omitempty"`
+
+	// Degrees from north, meters per second, and meters, as the device reported them
+	Heading				*float64						`json:"heading,omitempty" bson:"heading,omitempty"`
+	Speed				*float64						`json:"speed,omitempty" bson:"speed,omitempty"`
+	Accuracy			*float64						`json:"accuracy,omitempty" bson:"accuracy,omitempty"`
+
+	// When the device took the reading, and when the server got it
+	RecordedAt			*time.Time						`json:"recorded_at,omitempty" bson:"recorded_at,omitempty"`
+	ReceivedAt			*time.Time						`json:"received_at,omitempty" bson:"received_at,omitempty"`
+}
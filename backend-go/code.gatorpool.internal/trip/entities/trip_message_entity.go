@@ -0,0 +1,38 @@
+package entities
+
+import (
+	"time"
+)
+
+/*
+
+	A chat message on a trip, between the assigned driver and accepted riders.
+	Messages are kept for a while after the trip ends, then ExpiresAt deletes them.
+
+*/
+
+type TripMessageEntity struct {
+	// Unique identifier for the message
+	MessageUUID			*string							`json:"message_uuid,omitempty" bson:"message_uuid,omitempty"`
+
+	// The trip the message was sent on
+	TripUUID			*string							`json:"trip_uuid,omitempty" bson:"trip_uuid,omitempty"`
+
+	// Who sent it
+	SenderUUID			*string							`json:"sender_uuid,omitempty" bson:"sender_uuid,omitempty"`
+
+	Body				*string							`json:"body,omitempty" bson:"body,omitempty"`
+
+	// Everyone who has read the message, not including the sender
+	ReadBy				[]*TripMessageReadEntity		`json:"read_by" bson:"read_by"`
+
+	CreatedAt			*time.Time						`json:"created_at,omitempty" bson:"created_at,omitempty"`
+
+	// Set once the trip ends, the message is deleted after this
+	ExpiresAt			*time.Time						`json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+}
+
+type TripMessageReadEntity struct {
+	UserUUID			*string							`json:"user_uuid,omitempty" bson:"user_uuid,omitempty"`
+	ReadAt				*time.Time						`json:"read_at,omitempty" bson:"read_at,omitempty"`
+}
@@ -23,6 +23,18 @@ type TripRiderEntity struct {
 	// The review given to the driver by the rider
 	Review				*string							`json:"review,omitempty" bson:"review"`
 
+	// Timestamp of when the rider rated the driver
+	RatedAt				*time.Time						`json:"rated_at,omitempty" bson:"rated_at"`
+
+	// The rating given to the rider by the driver
+	DriverRating		*float64						`json:"driver_rating,omitempty" bson:"driver_rating"`
+
+	// The review given to the rider by the driver
+	DriverReview		*string							`json:"driver_review,omitempty" bson:"driver_review"`
+
+	// Timestamp of when the driver rated the rider
+	DriverRatedAt		*time.Time						`json:"driver_rated_at,omitempty" bson:"driver_rated_at"`
+
 	// What the rider is willing to pay for
 	Willing 			*TripRiderWillingEntity			`json:"willing,omitempty" bson:"willing"`
 
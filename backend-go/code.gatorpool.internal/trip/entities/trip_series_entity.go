@@ -0,0 +1,54 @@
+package entities
+
+import (
+	"time"
+)
+
+/*
+
+	A driver going home every Friday creates one series instead of a trip each week.
+
+	- Recurrence: weekly on Friday until the end of the semester
+	- Template: the trip as the driver created it, copied for every occurrence
+	- Riders: riders who requested every occurrence, added as requests to each new one
+	- GeneratedUntil: occurrences up to here have been created as trips
+
+*/
+
+type TripSeriesEntity struct {
+	// Unique identifier for the series
+	SeriesUUID			*string							`json:"series_uuid,omitempty" bson:"series_uuid,omitempty"`
+
+	// The driver who created the series
+	DriverUUID			*string							`json:"driver_uuid,omitempty" bson:"driver_uuid,omitempty"`
+
+	// When the series repeats
+	Recurrence			*TripRecurrenceEntity			`json:"recurrence,omitempty" bson:"recurrence,omitempty"`
+
+	// The first occurrence, every generated trip is a copy of it
+	Template			*TripEntity						`json:"template,omitempty" bson:"template,omitempty"`
+
+	// Rider requests that are added to every occurrence
+	Riders				[]*TripRiderEntity				`json:"riders" bson:"riders"`
+
+	// Can be active, cancelled
+	Status				*string							`json:"status,omitempty" bson:"status,omitempty"`
+
+	// Occurrences up to this time have been created as trips
+	GeneratedUntil		*time.Time						`json:"generated_until,omitempty" bson:"generated_until"`
+
+	// Timestamps
+	CreatedAt			*time.Time						`json:"created_at,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt			*time.Time						`json:"updated_at,omitempty" bson:"updated_at,omitempty"`
+}
+
+type TripRecurrenceEntity struct {
+	// Days of the week the trip repeats on, 0 is Sunday
+	Weekdays			[]int							`json:"weekdays" bson:"weekdays"`
+
+	// Last day an occurrence can fall on
+	Until				*time.Time						`json:"until,omitempty" bson:"until,omitempty"`
+
+	// IANA timezone the weekdays and time of day are in
+	Timezone			*string							`json:"timezone,omitempty" bson:"timezone,omitempty"`
+}
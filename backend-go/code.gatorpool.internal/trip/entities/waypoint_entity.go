@@ -61,6 +61,9 @@ type WaypointEntity struct {
 	// Longitude of the waypoint
 	Longitude		*float64				`json:"longitude,omitempty" bson:"longitude,omitempty"`
 
+	// GeoJSON point of the waypoint, used by the 2dsphere index on trips
+	Location		*GeoPointEntity			`json:"location,omitempty" bson:"location,omitempty"`
+
 	// Geographical location of the waypoint
 	Name			*string					`json:"name,omitempty" bson:"name,omitempty"`
 	Address			*string					`json:"address,omitempty" bson:"address,omitempty"`
@@ -75,4 +78,16 @@ type WaypointEntity struct {
 
 	// Actual time of arrival
 	Actual			*time.Time				`json:"actual,omitempty" bson:"actual,omitempty"`
+}
+
+// GeoJSON point, coordinates are [longitude, latitude]
+type GeoPointEntity struct {
+	Type			string					`json:"type" bson:"type"`
+	Coordinates		[]float64				`json:"coordinates" bson:"coordinates"`
+}
+
+// GeoJSON line string, coordinates are [longitude, latitude] pairs
+type GeoLineEntity struct {
+	Type			string					`json:"type" bson:"type"`
+	Coordinates		[][]float64				`json:"coordinates" bson:"coordinates"`
 }
\ No newline at end of file
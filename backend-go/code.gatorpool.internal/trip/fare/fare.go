@@ -0,0 +1,231 @@
+package fare
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/geo"
+	"code.gatorpool.internal/util/ptr"
+)
+
+const (
+	// Every rider pays the same for each component they're willing to pay for
+	SplitEqual = "equal"
+
+	// Riders pay for each component in proportion to how far they ride
+	SplitDistance = "distance"
+)
+
+var ErrUnknownSplit = errors.New("unknown fare split")
+
+// ValidSplit reports whether the split is one the engine knows, empty means equal
+func ValidSplit(split string) error {
+	switch split {
+	case "", SplitEqual, SplitDistance:
+		return nil
+	}
+	return ErrUnknownSplit
+}
+
+// Split works out what each accepted rider owes and stores it on the trip's fare. It has to be
+// called whenever riders join or leave, or the fare changes.
+func Split(trip *tripEntities.TripEntity) {
+	if trip.Fare == nil {
+		return
+	}
+	trip.Fare.Shares = Shares(trip)
+}
+
+// Shares is what each accepted rider owes for the trip. Gas and food are only split between the
+// riders willing to pay for them, the trip component between every accepted rider.
+func Shares(trip *tripEntities.TripEntity) []*tripEntities.TripFareShareEntity {
+	shares := []*tripEntities.TripFareShareEntity{}
+	if trip.Fare == nil {
+		return shares
+	}
+
+	riders := []*tripEntities.TripRiderEntity{}
+	for _, rider := range trip.Riders {
+		if rider != nil && rider.UserUUID != nil && rider.Accepted != nil && *rider.Accepted {
+			riders = append(riders, rider)
+		}
+	}
+	if len(riders) == 0 {
+		return shares
+	}
+
+	distance := trip.Fare.Split != nil && *trip.Fare.Split == SplitDistance
+	miles := make([]float64, len(riders))
+	for i, rider := range riders {
+		miles[i] = RiderMiles(trip, rider)
+	}
+
+	// Riders with no known distance are split equally rather than charged nothing
+	weights := func(pays func(rider *tripEntities.TripRiderEntity) bool) []float64 {
+		w := make([]float64, len(riders))
+		known := true
+		for i, rider := range riders {
+			if pays(rider) && miles[i] <= 0 {
+				known = false
+			}
+		}
+		for i, rider := range riders {
+			if !pays(rider) {
+				continue
+			}
+			w[i] = 1
+			if distance && known {
+				w[i] = miles[i]
+			}
+		}
+		return w
+	}
+
+	gas := Divide(amount(trip.Fare.Gas), weights(func(rider *tripEntities.TripRiderEntity) bool {
+		return rider.Willing != nil && rider.Willing.PayGas != nil && *rider.Willing.PayGas
+	}))
+	food := Divide(amount(trip.Fare.Food), weights(func(rider *tripEntities.TripRiderEntity) bool {
+		return rider.Willing != nil && rider.Willing.PayFood != nil && *rider.Willing.PayFood
+	}))
+	tripFare := Divide(amount(trip.Fare.Trip), weights(func(rider *tripEntities.TripRiderEntity) bool {
+		return true
+	}))
+
+	for i, rider := range riders {
+		share := &tripEntities.TripFareShareEntity{
+			UserUUID: ptr.String(*rider.UserUUID),
+			Gas:      ptr.Float64(gas[i]),
+			Food:     ptr.Float64(food[i]),
+			Trip:     ptr.Float64(tripFare[i]),
+			Total:    ptr.Float64(Round(gas[i] + food[i] + tripFare[i])),
+		}
+		if distance {
+			share.Miles = ptr.Float64(math.Round(miles[i]*10) / 10)
+		}
+		shares = append(shares, share)
+	}
+
+	return shares
+}
+
+// FindShare returns the rider's share of the trip's fare, or nil if they don't owe anything
+func FindShare(trip *tripEntities.TripEntity, userUUID string) *tripEntities.TripFareShareEntity {
+	if trip.Fare == nil {
+		return nil
+	}
+	for _, share := range trip.Fare.Shares {
+		if share != nil && share.UserUUID != nil && *share.UserUUID == userUUID {
+			return share
+		}
+	}
+	return nil
+}
+
+// Divide splits total in proportion to the weights, rounded to the cent. Cents lost to rounding
+// go to the largest remainders, so the parts always add back up to the total. Riders with a zero
+// weight get nothing, and if every weight is zero nobody does.
+func Divide(total float64, weights []float64) []float64 {
+	parts := make([]float64, len(weights))
+
+	sum := 0.0
+	for _, weight := range weights {
+		if weight > 0 {
+			sum += weight
+		}
+	}
+	if sum == 0 || total <= 0 {
+		return parts
+	}
+
+	cents := int64(math.Round(total * 100))
+	remainders := make([]float64, len(weights))
+	assigned := int64(0)
+	for i, weight := range weights {
+		if weight <= 0 {
+			continue
+		}
+		exact := float64(cents) * weight / sum
+		whole := int64(math.Floor(exact))
+		parts[i] = float64(whole)
+		remainders[i] = exact - float64(whole)
+		assigned += whole
+	}
+
+	order := []int{}
+	for i, weight := range weights {
+		if weight > 0 {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for i := int64(0); i < cents-assigned; i++ {
+		parts[order[int(i)%len(order)]]++
+	}
+
+	for i := range parts {
+		parts[i] = parts[i] / 100
+	}
+	return parts
+}
+
+// RiderMiles is how far the rider rides, from their pickup to their drop off. Whatever isn't
+// known is taken from the driver's route, so a rider without waypoints rides the whole trip.
+func RiderMiles(trip *tripEntities.TripEntity, rider *tripEntities.TripRiderEntity) float64 {
+	pickup := geo.FindWaypoint(trip, "pickup", "driver")
+	dropoff := geo.FindWaypoint(trip, "destination", "driver")
+
+	if located(rider.Address) {
+		pickup = rider.Address
+	}
+	for _, waypoint := range trip.Waypoints {
+		if waypoint == nil || waypoint.Type == nil || waypoint.For == nil || !located(waypoint) {
+			continue
+		}
+		if *waypoint.Type == "dropoff" && *waypoint.For == "rider" && riderUUID(waypoint) == *rider.UserUUID {
+			dropoff = waypoint
+			break
+		}
+	}
+
+	if !located(pickup) || !located(dropoff) {
+		return 0
+	}
+
+	return geo.DistanceMiles(
+		geo.LatLng{Lat: *pickup.Latitude, Lng: *pickup.Longitude},
+		geo.LatLng{Lat: *dropoff.Latitude, Lng: *dropoff.Longitude},
+	)
+}
+
+// Round rounds an amount to the cent
+func Round(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+func amount(value *float64) float64 {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
+
+func located(waypoint *tripEntities.WaypointEntity) bool {
+	return waypoint != nil && waypoint.Latitude != nil && waypoint.Longitude != nil
+}
+
+// riderUUID is the rider a waypoint was added for, kept in its data
+func riderUUID(waypoint *tripEntities.WaypointEntity) string {
+	switch value := waypoint.Data["rider_uuid"].(type) {
+	case string:
+		return value
+	case *string:
+		if value != nil {
+			return *value
+		}
+	}
+	return ""
+}
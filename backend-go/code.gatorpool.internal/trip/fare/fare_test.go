@@ -0,0 +1,187 @@
+package fare
+
+import (
+	"testing"
+
+	configEntities "code.gatorpool.internal/config/entities"
+	driverEntities "code.gatorpool.internal/driver/entities"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+)
+
+func rider(userUUID string, accepted bool, payGas bool, payFood bool) *tripEntities.TripRiderEntity {
+	return &tripEntities.TripRiderEntity{
+		UserUUID: ptr.String(userUUID),
+		Accepted: ptr.Bool(accepted),
+		Willing: &tripEntities.TripRiderWillingEntity{
+			PayGas:  ptr.Bool(payGas),
+			PayFood: ptr.Bool(payFood),
+		},
+	}
+}
+
+func TestDivide(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Total    float64
+		Weights  []float64
+		Expected []float64
+	}{
+		{"Even split", 30, []float64{1, 1, 1}, []float64{10, 10, 10}},
+		{"Leftover cent goes to someone", 10, []float64{1, 1, 1}, []float64{3.34, 3.33, 3.33}},
+		{"Weighted", 30, []float64{1, 2}, []float64{10, 20}},
+		{"Zero weights pay nothing", 20, []float64{1, 0, 1}, []float64{10, 0, 10}},
+		{"Nobody to pay", 20, []float64{0, 0}, []float64{0, 0}},
+		{"Nothing to pay", 0, []float64{1, 1}, []float64{0, 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			parts := Divide(tt.Total, tt.Weights)
+			assert.Equal(t, tt.Expected, parts)
+		})
+	}
+}
+
+func TestShares(t *testing.T) {
+	trip := &tripEntities.TripEntity{
+		Fare: &tripEntities.TripFareEntity{
+			Gas:  ptr.Float64(20),
+			Food: ptr.Float64(10),
+			Trip: ptr.Float64(30),
+		},
+		Riders: []*tripEntities.TripRiderEntity{
+			rider("jack", true, true, true),
+			rider("jill", true, true, false),
+			rider("requested", false, true, true),
+		},
+	}
+
+	Split(trip)
+	assert.Len(t, trip.Fare.Shares, 2)
+
+	jack := FindShare(trip, "jack")
+	assert.Equal(t, 10.0, *jack.Gas)
+	assert.Equal(t, 10.0, *jack.Food)
+	assert.Equal(t, 15.0, *jack.Trip)
+	assert.Equal(t, 35.0, *jack.Total)
+
+	jill := FindShare(trip, "jill")
+	assert.Equal(t, 0.0, *jill.Food)
+	assert.Equal(t, 25.0, *jill.Total)
+
+	assert.Nil(t, FindShare(trip, "requested"))
+
+	// Once Jill leaves, Jack pays for everything
+	trip.Riders = trip.Riders[:1]
+	Split(trip)
+	assert.Equal(t, 60.0, *FindShare(trip, "jack").Total)
+}
+
+func TestDistanceShares(t *testing.T) {
+	point := func(waypointType string, waypointFor string, lat float64, lng float64) *tripEntities.WaypointEntity {
+		return &tripEntities.WaypointEntity{
+			Type:      ptr.String(waypointType),
+			For:       ptr.String(waypointFor),
+			Latitude:  ptr.Float64(lat),
+			Longitude: ptr.Float64(lng),
+		}
+	}
+
+	// Gainesville to Miami, with one rider getting out halfway
+	halfway := point("dropoff", "rider", 27.7, -81.25)
+	halfway.Data = map[string]interface{}{"rider_uuid": "jill"}
+
+	trip := &tripEntities.TripEntity{
+		Waypoints: []*tripEntities.WaypointEntity{
+			point("pickup", "driver", 29.65, -82.32),
+			point("destination", "driver", 25.76, -80.19),
+			halfway,
+		},
+		Fare: &tripEntities.TripFareEntity{
+			Trip:  ptr.Float64(30),
+			Split: ptr.String(SplitDistance),
+		},
+		Riders: []*tripEntities.TripRiderEntity{
+			rider("jack", true, false, false),
+			rider("jill", true, false, false),
+		},
+	}
+
+	Split(trip)
+	jack := FindShare(trip, "jack")
+	jill := FindShare(trip, "jill")
+	assert.InDelta(t, 20, *jack.Total, 0.5)
+	assert.InDelta(t, 10, *jill.Total, 0.5)
+	assert.Equal(t, 30.0, Round(*jack.Total+*jill.Total))
+	assert.Greater(t, *jack.Miles, *jill.Miles)
+}
+
+func TestValidSplit(t *testing.T) {
+	assert.Nil(t, ValidSplit(""))
+	assert.Nil(t, ValidSplit(SplitDistance))
+	assert.Equal(t, ErrUnknownSplit, ValidSplit("by_weight"))
+}
+
+func TestNewSettings(t *testing.T) {
+	settings := NewSettings(nil)
+	assert.Equal(t, DefaultFuelPrice, settings.FuelPrice)
+	assert.Equal(t, CapWarn, settings.CapAction)
+
+	settings = NewSettings(&configEntities.FareConfigEntity{
+		FuelPrice:   ptr.Float64(4),
+		MPG:         ptr.Float64(-1),
+		RouteFactor: ptr.Float64(0.5),
+		CapAction:   ptr.String(CapReject),
+	})
+	assert.Equal(t, 4.0, settings.FuelPrice)
+	assert.Equal(t, DefaultMPG, settings.MPG)
+	assert.Equal(t, DefaultRouteFactor, settings.RouteFactor)
+	assert.Equal(t, CapReject, settings.CapAction)
+}
+
+func TestSuggest(t *testing.T) {
+	settings := NewSettings(&configEntities.FareConfigEntity{
+		FuelPrice:  ptr.Float64(3),
+		MaxPerMile: ptr.Float64(0.4),
+	})
+
+	suggestion := Suggest(100, 30, settings)
+	assert.Equal(t, 10.0, suggestion.Gas)
+	assert.Equal(t, 40.0, suggestion.Max)
+
+	// Vehicles without an MPG use the default
+	assert.Equal(t, DefaultMPG, VehicleMPG(&driverEntities.VehicleEntity{}, settings))
+	assert.Equal(t, 40.0, VehicleMPG(&driverEntities.VehicleEntity{MPG: ptr.Float64(40)}, settings))
+}
+
+func TestCheckCap(t *testing.T) {
+	newFare := func(gas float64, trip float64, food float64) *tripEntities.TripFareEntity {
+		return &tripEntities.TripFareEntity{Gas: ptr.Float64(gas), Trip: ptr.Float64(trip), Food: ptr.Float64(food)}
+	}
+
+	tests := []struct {
+		Name     string
+		Fare     *tripEntities.TripFareEntity
+		Action   string
+		Exceeded bool
+		Err      error
+	}{
+		{"Under the cap", newFare(20, 20, 0), CapReject, false, nil},
+		{"Exactly the cap", newFare(25, 25, 0), CapReject, false, nil},
+		{"Food doesn't count", newFare(20, 20, 100), CapReject, false, nil},
+		{"Over the cap warns", newFare(30, 30, 0), CapWarn, true, nil},
+		{"Over the cap rejects", newFare(30, 30, 0), CapReject, true, ErrOverCap},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			settings := NewSettings(&configEntities.FareConfigEntity{CapAction: ptr.String(tt.Action)})
+			check, err := CheckCap(tt.Fare, 100, settings)
+			assert.Equal(t, tt.Err, err)
+			assert.Equal(t, tt.Exceeded, check.Exceeded)
+			assert.Equal(t, 50.0, check.Max)
+		})
+	}
+}
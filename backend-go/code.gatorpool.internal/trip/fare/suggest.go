@@ -0,0 +1,157 @@
+package fare
+
+import (
+	"context"
+	"errors"
+	"math"
+
+	configEntities "code.gatorpool.internal/config/entities"
+	datastores "code.gatorpool.internal/datastores/mongo"
+	driverEntities "code.gatorpool.internal/driver/entities"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/geo"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// Used when the config doesn't set them
+	DefaultFuelPrice   = 3.25
+	DefaultMPG         = 25.0
+	DefaultMaxPerMile  = 0.50
+	DefaultRouteFactor = 1.2
+
+	// What happens to a fare over the per mile cap
+	CapWarn   = "warn"
+	CapReject = "reject"
+)
+
+var ErrOverCap = errors.New("fare is more than the per mile cap")
+
+// Settings are the inputs for suggesting and capping fares
+type Settings struct {
+	FuelPrice   float64
+	MPG         float64
+	MaxPerMile  float64
+	RouteFactor float64
+	CapAction   string
+}
+
+// Suggestion is the fare we'd suggest for a trip, and the most it's allowed to be
+type Suggestion struct {
+	Miles     float64 `json:"miles"`
+	MPG       float64 `json:"mpg"`
+	FuelPrice float64 `json:"fuel_price"`
+	Gas       float64 `json:"gas"`
+	Max       float64 `json:"max"`
+}
+
+// Check is how a fare compares to the cap
+type Check struct {
+	Miles    float64 `json:"miles"`
+	PerMile  float64 `json:"per_mile"`
+	Max      float64 `json:"max"`
+	Exceeded bool    `json:"exceeded"`
+}
+
+// NewSettings fills in whatever the config leaves unset with the defaults
+func NewSettings(config *configEntities.FareConfigEntity) Settings {
+	settings := Settings{
+		FuelPrice:   DefaultFuelPrice,
+		MPG:         DefaultMPG,
+		MaxPerMile:  DefaultMaxPerMile,
+		RouteFactor: DefaultRouteFactor,
+		CapAction:   CapWarn,
+	}
+	if config == nil {
+		return settings
+	}
+
+	if config.FuelPrice != nil && *config.FuelPrice > 0 {
+		settings.FuelPrice = *config.FuelPrice
+	}
+	if config.MPG != nil && *config.MPG > 0 {
+		settings.MPG = *config.MPG
+	}
+	if config.MaxPerMile != nil && *config.MaxPerMile > 0 {
+		settings.MaxPerMile = *config.MaxPerMile
+	}
+	if config.RouteFactor != nil && *config.RouteFactor >= 1 {
+		settings.RouteFactor = *config.RouteFactor
+	}
+	if config.CapAction != nil && *config.CapAction == CapReject {
+		settings.CapAction = CapReject
+	}
+
+	return settings
+}
+
+// LoadSettings reads the fare settings from the app config
+func LoadSettings(ctx context.Context) (Settings, error) {
+	var config configEntities.ConfigEntity
+	err := datastores.GetMongoDatabase(ctx).Collection(datastores.Config).FindOne(ctx, bson.M{"app_id": "gatorpool"}).Decode(&config)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return NewSettings(nil), err
+	}
+	return NewSettings(config.Fare), nil
+}
+
+// RouteMiles estimates the road distance through the points from the straight line distance
+// between them
+func RouteMiles(points []geo.LatLng, settings Settings) float64 {
+	miles := 0.0
+	for i := 1; i < len(points); i++ {
+		miles += geo.DistanceMiles(points[i-1], points[i])
+	}
+	return miles * settings.RouteFactor
+}
+
+// VehicleMPG is the vehicle's miles per gallon if it has one, or the configured default
+func VehicleMPG(vehicle *driverEntities.VehicleEntity, settings Settings) float64 {
+	if vehicle != nil && vehicle.MPG != nil && *vehicle.MPG > 0 {
+		return *vehicle.MPG
+	}
+	return settings.MPG
+}
+
+// Suggest is the fare for driving the miles in a car that gets mpg. Gas is what the fuel costs,
+// and max is the most the gas and trip fare can add up to.
+func Suggest(miles float64, mpg float64, settings Settings) Suggestion {
+	if mpg <= 0 {
+		mpg = settings.MPG
+	}
+
+	return Suggestion{
+		Miles:     math.Round(miles*10) / 10,
+		MPG:       mpg,
+		FuelPrice: settings.FuelPrice,
+		Gas:       Round(miles / mpg * settings.FuelPrice),
+		Max:       Round(miles * settings.MaxPerMile),
+	}
+}
+
+// CheckCap compares the fare's gas and trip components to the per mile cap. Food isn't part of
+// the ride so it doesn't count. ErrOverCap is returned when the fare is over and the cap rejects.
+func CheckCap(fare *tripEntities.TripFareEntity, miles float64, settings Settings) (Check, error) {
+	check := Check{
+		Miles: math.Round(miles*10) / 10,
+		Max:   Round(miles * settings.MaxPerMile),
+	}
+	if fare == nil || miles <= 0 {
+		return check, nil
+	}
+
+	total := amount(fare.Gas) + amount(fare.Trip)
+	check.PerMile = Round(total / miles)
+	check.Exceeded = Round(total) > check.Max
+
+	if check.Exceeded && settings.CapAction == CapReject {
+		return check, ErrOverCap
+	}
+	return check, nil
+}
+
+// TripMiles is the estimated road distance of the driver's route
+func TripMiles(trip *tripEntities.TripEntity, settings Settings) float64 {
+	return RouteMiles(geo.Route(trip), settings)
+}
@@ -0,0 +1,194 @@
+package geo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// Radius of the earth in miles, used to turn miles into radians for $centerSphere
+	EarthRadiusMiles = 3963.2
+
+	// Search radius used when the rider or driver doesn't ask for one
+	DefaultRadiusMiles = 50.0
+
+	// Largest search or drop off radius we allow
+	MaxRadiusMiles = 150.0
+)
+
+type LatLng struct {
+	Lat float64
+	Lng float64
+}
+
+// Match is how far a trip's pickup and destination are from where the searcher wants to go, and how
+// many extra miles the driver would drive to take them
+type Match struct {
+	PickupMiles  float64 `json:"pickup_miles"`
+	DropOffMiles float64 `json:"dropoff_miles"`
+	DetourMiles  float64 `json:"detour_miles"`
+}
+
+// Miles is the total distance between the search and the trip
+func (m Match) Miles() float64 {
+	return m.PickupMiles + m.DropOffMiles
+}
+
+// Valid reports whether the coordinates are a real point on the map
+func Valid(lat float64, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+// Point returns the GeoJSON point for the coordinates
+func Point(lat float64, lng float64) *tripEntities.GeoPointEntity {
+	return &tripEntities.GeoPointEntity{
+		Type:        "Point",
+		Coordinates: []float64{lng, lat},
+	}
+}
+
+// Locate sets the GeoJSON point on each waypoint from its latitude and longitude
+func Locate(waypoints ...*tripEntities.WaypointEntity) {
+	for _, waypoint := range waypoints {
+		if waypoint == nil || waypoint.Latitude == nil || waypoint.Longitude == nil {
+			continue
+		}
+		waypoint.Location = Point(*waypoint.Latitude, *waypoint.Longitude)
+	}
+}
+
+// Radius returns the requested radius in miles, falling back to the default and capped at the max
+func Radius(requested *float64) float64 {
+	if requested == nil || *requested <= 0 {
+		return DefaultRadiusMiles
+	}
+	return math.Min(*requested, MaxRadiusMiles)
+}
+
+// WithinMiles returns a $geoWithin filter for points within the radius of the coordinates
+func WithinMiles(point LatLng, miles float64) bson.M {
+	return bson.M{
+		"$geoWithin": bson.M{
+			"$centerSphere": bson.A{
+				bson.A{point.Lng, point.Lat},
+				miles / EarthRadiusMiles,
+			},
+		},
+	}
+}
+
+// DistanceMiles returns the great circle distance between two points
+func DistanceMiles(a LatLng, b LatLng) float64 {
+	toRadians := func(deg float64) float64 {
+		return deg * math.Pi / 180
+	}
+
+	dLat := toRadians(b.Lat - a.Lat)
+	dLng := toRadians(b.Lng - a.Lng)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(a.Lat))*math.Cos(toRadians(b.Lat))*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * EarthRadiusMiles * math.Asin(math.Sqrt(h))
+}
+
+// FindWaypoint returns the first waypoint on the trip with the given type and for
+func FindWaypoint(trip *tripEntities.TripEntity, waypointType string, waypointFor string) *tripEntities.WaypointEntity {
+	for _, waypoint := range trip.Waypoints {
+		if waypoint == nil || waypoint.Type == nil || waypoint.For == nil {
+			continue
+		}
+		if *waypoint.Type == waypointType && *waypoint.For == waypointFor {
+			return waypoint
+		}
+	}
+	return nil
+}
+
+// MatchTrip works out how far the driver's pickup and destination are from the search. The pickup
+// has to be within the searcher's radius, and the drop off within both the searcher's radius and
+// the trip's max drop off radius.
+func MatchTrip(trip *tripEntities.TripEntity, from LatLng, to LatLng, radius float64) (Match, bool) {
+	pickup := FindWaypoint(trip, "pickup", "driver")
+	destination := FindWaypoint(trip, "destination", "driver")
+	if pickup == nil || destination == nil || pickup.Latitude == nil || destination.Latitude == nil {
+		return Match{}, false
+	}
+
+	match := Match{
+		PickupMiles:  DistanceMiles(from, LatLng{*pickup.Latitude, *pickup.Longitude}),
+		DropOffMiles: DistanceMiles(to, LatLng{*destination.Latitude, *destination.Longitude}),
+	}
+
+	dropOffRadius := radius
+	if trip.MaxRadiusDropOff != nil && *trip.MaxRadiusDropOff > 0 {
+		dropOffRadius = math.Min(radius, *trip.MaxRadiusDropOff)
+	}
+
+	if match.PickupMiles > radius || match.DropOffMiles > dropOffRadius {
+		return Match{}, false
+	}
+
+	match.DetourMiles = DetourMiles(Route(trip), from, to)
+
+	return match, true
+}
+
+// SortByDistance sorts the trips closest first using the matches keyed by trip uuid
+func SortByDistance(trips []tripEntities.TripEntity, matches map[string]Match) {
+	sort.SliceStable(trips, func(i, j int) bool {
+		return matches[*trips[i].TripUUID].Miles() < matches[*trips[j].TripUUID].Miles()
+	})
+}
+
+// Backfill sets the GeoJSON waypoint points and route on trips created before they were stored.
+// Each trip it visits is marked with geo_backfilled_at, so ones without enough points for a route
+// aren't looked at again on every start.
+func Backfill(ctx context.Context) error {
+
+	tripsCollection := datastores.GetMongoDatabase(ctx).Collection(datastores.Trips)
+
+	cursor, err := tripsCollection.Find(ctx, bson.M{
+		"geo_backfilled_at": bson.M{"$exists": false},
+		"$or": []bson.M{
+			{"waypoints": bson.M{"$elemMatch": bson.M{
+				"location": bson.M{"$exists": false},
+				"latitude": bson.M{"$exists": true},
+			}}},
+			{"route": bson.M{"$exists": false}},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var trip tripEntities.TripEntity
+		if err := cursor.Decode(&trip); err != nil {
+			return err
+		}
+
+		Locate(trip.Waypoints...)
+		Trace(&trip)
+
+		set := bson.M{"waypoints": trip.Waypoints, "geo_backfilled_at": time.Now()}
+		if trip.Route != nil {
+			set["route"] = trip.Route
+		}
+
+		_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": trip.TripUUID}, bson.M{"$set": set})
+		if err != nil {
+			fmt.Println("Error backfilling trip locations: ", err)
+		}
+	}
+
+	return cursor.Err()
+}
@@ -0,0 +1,91 @@
+package geo
+
+import (
+	"testing"
+
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	gainesville = LatLng{29.6516, -82.3248}
+	orlando     = LatLng{28.5384, -81.3789}
+	miami       = LatLng{25.7617, -80.1918}
+)
+
+func waypoint(waypointType string, point LatLng) *tripEntities.WaypointEntity {
+	return &tripEntities.WaypointEntity{
+		Type:      ptr.String(waypointType),
+		For:       ptr.String("driver"),
+		Latitude:  ptr.Float64(point.Lat),
+		Longitude: ptr.Float64(point.Lng),
+	}
+}
+
+func TestDistanceMiles(t *testing.T) {
+	assert.InDelta(t, 0, DistanceMiles(gainesville, gainesville), 0.001)
+	assert.InDelta(t, 97, DistanceMiles(gainesville, orlando), 3)
+	assert.InDelta(t, 299, DistanceMiles(gainesville, miami), 5)
+}
+
+func TestPointAndLocate(t *testing.T) {
+	w := waypoint("pickup", gainesville)
+	Locate(w, nil, &tripEntities.WaypointEntity{})
+
+	assert.Equal(t, "Point", w.Location.Type)
+	assert.Equal(t, []float64{gainesville.Lng, gainesville.Lat}, w.Location.Coordinates)
+}
+
+func TestRadius(t *testing.T) {
+	assert.Equal(t, DefaultRadiusMiles, Radius(nil))
+	assert.Equal(t, DefaultRadiusMiles, Radius(ptr.Float64(0)))
+	assert.Equal(t, 20.0, Radius(ptr.Float64(20)))
+	assert.Equal(t, MaxRadiusMiles, Radius(ptr.Float64(1000)))
+}
+
+func TestMatchTrip(t *testing.T) {
+	trip := &tripEntities.TripEntity{
+		Waypoints: []*tripEntities.WaypointEntity{
+			waypoint("pickup", gainesville),
+			waypoint("destination", miami),
+		},
+	}
+
+	tests := []struct {
+		Name    string
+		From    LatLng
+		To      LatLng
+		Radius  float64
+		MaxDrop *float64
+		Matches bool
+	}{
+		{"Same pickup and destination", gainesville, miami, 50, nil, true},
+		{"Pickup outside the radius", orlando, miami, 50, nil, false},
+		{"Pickup inside a bigger radius", orlando, miami, 120, nil, true},
+		{"Drop off outside the trip max", gainesville, orlando, 150, ptr.Float64(20), false},
+		{"Drop off inside the trip max", gainesville, miami, 150, ptr.Float64(20), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			trip.MaxRadiusDropOff = tt.MaxDrop
+			_, ok := MatchTrip(trip, tt.From, tt.To, tt.Radius)
+			assert.Equal(t, tt.Matches, ok)
+		})
+	}
+}
+
+func TestSortByDistance(t *testing.T) {
+	trips := []tripEntities.TripEntity{
+		{TripUUID: ptr.String("far")},
+		{TripUUID: ptr.String("near")},
+	}
+
+	SortByDistance(trips, map[string]Match{
+		"far":  {PickupMiles: 30, DropOffMiles: 10},
+		"near": {PickupMiles: 5, DropOffMiles: 5},
+	})
+
+	assert.Equal(t, "near", *trips[0].TripUUID)
+}
@@ -0,0 +1,170 @@
+package geo
+
+import (
+	"math"
+	"sort"
+
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	// Detour a rider can ask the driver to make when they don't say
+	DefaultDetourMiles = 15.0
+
+	// Largest detour we search for
+	MaxDetourMiles = 60.0
+)
+
+// Route returns the points the driver passes through, in order
+func Route(trip *tripEntities.TripEntity) []LatLng {
+	route := []LatLng{}
+	for _, waypoint := range trip.Waypoints {
+		if waypoint == nil || waypoint.Latitude == nil || waypoint.Longitude == nil {
+			continue
+		}
+		if waypoint.For != nil && *waypoint.For == "rider" {
+			continue
+		}
+		route = append(route, LatLng{*waypoint.Latitude, *waypoint.Longitude})
+	}
+	return route
+}
+
+// Trace sets the GeoJSON route on the trip from its waypoints. A line needs two distinct points,
+// so trips that start and end in the same place don't get one.
+func Trace(trip *tripEntities.TripEntity) {
+	trip.Route = nil
+
+	coordinates := [][]float64{}
+	for _, point := range Route(trip) {
+		if n := len(coordinates); n > 0 && coordinates[n-1][0] == point.Lng && coordinates[n-1][1] == point.Lat {
+			continue
+		}
+		coordinates = append(coordinates, []float64{point.Lng, point.Lat})
+	}
+
+	if len(coordinates) < 2 {
+		return
+	}
+
+	trip.Route = &tripEntities.GeoLineEntity{
+		Type:        "LineString",
+		Coordinates: coordinates,
+	}
+}
+
+// Detour returns the requested detour in miles, falling back to the default and capped at the max
+func Detour(requested *float64) float64 {
+	if requested == nil || *requested <= 0 {
+		return DefaultDetourMiles
+	}
+	return math.Min(*requested, MaxDetourMiles)
+}
+
+// DetourMiles returns how many extra miles the driver has to go to pick up at pickup and drop off
+// at dropoff, inserting both into the cheapest legs of the route with the pickup coming first
+func DetourMiles(route []LatLng, pickup LatLng, dropoff LatLng) float64 {
+	if len(route) < 2 {
+		return math.Inf(1)
+	}
+
+	// Extra miles to go through point on the leg starting at route[i]
+	insert := func(i int, point LatLng) float64 {
+		return DistanceMiles(route[i], point) + DistanceMiles(point, route[i+1]) - DistanceMiles(route[i], route[i+1])
+	}
+
+	best := math.Inf(1)
+	for i := 0; i < len(route)-1; i++ {
+		// Both on the same leg
+		sameLeg := DistanceMiles(route[i], pickup) + DistanceMiles(pickup, dropoff) + DistanceMiles(dropoff, route[i+1]) - DistanceMiles(route[i], route[i+1])
+		best = math.Min(best, sameLeg)
+
+		for j := i + 1; j < len(route)-1; j++ {
+			best = math.Min(best, insert(i, pickup)+insert(j, dropoff))
+		}
+	}
+
+	return math.Max(best, 0)
+}
+
+// Circle returns a GeoJSON polygon approximating a circle around the point, for $geoIntersects
+func Circle(center LatLng, miles float64) bson.M {
+	const sides = 24
+
+	lat := center.Lat * math.Pi / 180
+	lng := center.Lng * math.Pi / 180
+	angular := miles / EarthRadiusMiles
+
+	ring := bson.A{}
+	for i := 0; i < sides; i++ {
+		bearing := 2 * math.Pi * float64(i) / sides
+
+		pLat := math.Asin(math.Sin(lat)*math.Cos(angular) + math.Cos(lat)*math.Sin(angular)*math.Cos(bearing))
+		pLng := lng + math.Atan2(math.Sin(bearing)*math.Sin(angular)*math.Cos(lat), math.Cos(angular)-math.Sin(lat)*math.Sin(pLat))
+
+		ring = append(ring, bson.A{pLng * 180 / math.Pi, pLat * 180 / math.Pi})
+	}
+	ring = append(ring, ring[0])
+
+	return bson.M{
+		"type":        "Polygon",
+		"coordinates": bson.A{ring},
+	}
+}
+
+// NearRoute returns a filter for trips whose route passes close enough to the point that a detour
+// of the given miles could reach it. Going to a point x miles off the route and back costs at least
+// 2x, so only routes within half the detour can match.
+func NearRoute(point LatLng, detour float64) bson.M {
+	return bson.M{
+		"route": bson.M{
+			"$geoIntersects": bson.M{
+				"$geometry": Circle(point, detour/2),
+			},
+		},
+	}
+}
+
+// MatchRoute matches a trip when the pickup and drop off are near the driver's endpoints, or close
+// enough to the driver's route that the detour is within maxDetour. The trip's max drop off radius
+// also caps how far out of their way the driver goes.
+func MatchRoute(trip *tripEntities.TripEntity, from LatLng, to LatLng, radius float64, maxDetour float64) (Match, bool) {
+	match, ok := MatchTrip(trip, from, to, radius)
+	if ok {
+		return match, true
+	}
+
+	route := Route(trip)
+	if len(route) < 2 {
+		return Match{}, false
+	}
+
+	if trip.MaxRadiusDropOff != nil && *trip.MaxRadiusDropOff > 0 {
+		maxDetour = math.Min(maxDetour, *trip.MaxRadiusDropOff)
+	}
+
+	match = Match{
+		PickupMiles:  DistanceMiles(from, route[0]),
+		DropOffMiles: DistanceMiles(to, route[len(route)-1]),
+		DetourMiles:  DetourMiles(route, from, to),
+	}
+
+	if match.DetourMiles > maxDetour {
+		return Match{}, false
+	}
+
+	return match, true
+}
+
+// SortByDetour sorts the trips with the smallest detour first, closest endpoints breaking ties
+func SortByDetour(trips []tripEntities.TripEntity, matches map[string]Match) {
+	sort.SliceStable(trips, func(i, j int) bool {
+		a := matches[*trips[i].TripUUID]
+		b := matches[*trips[j].TripUUID]
+		if a.DetourMiles != b.DetourMiles {
+			return a.DetourMiles < b.DetourMiles
+		}
+		return a.Miles() < b.Miles()
+	})
+}
@@ -0,0 +1,98 @@
+package geo
+
+import (
+	"testing"
+
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	ocala = LatLng{29.1872, -82.1401}
+	tampa = LatLng{27.9506, -82.4572}
+)
+
+func TestDetourMiles(t *testing.T) {
+	route := []LatLng{gainesville, tampa}
+
+	tests := []struct {
+		Name    string
+		Pickup  LatLng
+		DropOff LatLng
+		Min     float64
+		Max     float64
+	}{
+		{"Same trip has no detour", gainesville, tampa, 0, 0.001},
+		{"Ocala is on the way to Tampa", gainesville, ocala, 0, 10},
+		{"Orlando is out of the way", gainesville, orlando, 40, 80},
+		{"Going backwards costs the whole leg", ocala, gainesville, 60, 80},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			detour := DetourMiles(route, tt.Pickup, tt.DropOff)
+			assert.GreaterOrEqual(t, detour, tt.Min)
+			assert.LessOrEqual(t, detour, tt.Max)
+		})
+	}
+}
+
+func TestDetourMilesPolyline(t *testing.T) {
+	// Gainesville to Miami with a stop in Orlando, the rider hops on in Orlando
+	route := []LatLng{gainesville, orlando, miami}
+	assert.InDelta(t, 0, DetourMiles(route, orlando, miami), 0.001)
+}
+
+func TestTrace(t *testing.T) {
+	trip := &tripEntities.TripEntity{
+		Waypoints: []*tripEntities.WaypointEntity{
+			waypoint("pickup", gainesville),
+			{Type: ptr.String("pickup"), For: ptr.String("rider"), Latitude: ptr.Float64(ocala.Lat), Longitude: ptr.Float64(ocala.Lng)},
+			waypoint("destination", tampa),
+		},
+	}
+
+	Trace(trip)
+	assert.Equal(t, "LineString", trip.Route.Type)
+	assert.Equal(t, [][]float64{{gainesville.Lng, gainesville.Lat}, {tampa.Lng, tampa.Lat}}, trip.Route.Coordinates)
+
+	// A trip that starts and ends in the same place has no line
+	trip.Waypoints[2] = waypoint("destination", gainesville)
+	Trace(trip)
+	assert.Nil(t, trip.Route)
+}
+
+func TestCircle(t *testing.T) {
+	circle := Circle(ocala, 10)
+	ring := circle["coordinates"].(bson.A)[0].(bson.A)
+
+	assert.Equal(t, ring[0], ring[len(ring)-1])
+	for _, point := range ring {
+		coordinates := point.(bson.A)
+		edge := LatLng{coordinates[1].(float64), coordinates[0].(float64)}
+		assert.InDelta(t, 10, DistanceMiles(ocala, edge), 0.01)
+	}
+}
+
+func TestMatchRoute(t *testing.T) {
+	trip := &tripEntities.TripEntity{
+		Waypoints: []*tripEntities.WaypointEntity{
+			waypoint("pickup", gainesville),
+			waypoint("destination", tampa),
+		},
+	}
+
+	match, ok := MatchRoute(trip, gainesville, ocala, DefaultRadiusMiles, DefaultDetourMiles)
+	assert.True(t, ok)
+	assert.Less(t, match.DetourMiles, DefaultDetourMiles)
+
+	_, ok = MatchRoute(trip, gainesville, orlando, DefaultRadiusMiles, DefaultDetourMiles)
+	assert.False(t, ok)
+
+	// The driver only goes a few miles out of their way
+	trip.MaxRadiusDropOff = ptr.Float64(1)
+	_, ok = MatchRoute(trip, gainesville, ocala, DefaultRadiusMiles, DefaultDetourMiles)
+	assert.False(t, ok)
+}
@@ -10,7 +10,13 @@ import (
 	datastores "code.gatorpool.internal/datastores/mongo"
 	dispatch "code.gatorpool.internal/fulfillment/dispatch"
 	warningEntities "code.gatorpool.internal/fulfillment/entities"
+	"code.gatorpool.internal/notify"
+	"code.gatorpool.internal/trip/chat"
 	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/history"
+	"code.gatorpool.internal/trip/lifecycle"
+	"code.gatorpool.internal/trip/location"
+	"code.gatorpool.internal/trip/stream"
 	"code.gatorpool.internal/util"
 	"code.gatorpool.internal/util/ptr"
 	"github.com/go-chi/chi"
@@ -43,30 +49,49 @@ func CancelTripDriverFlow(req *http.Request, res http.ResponseWriter, ctx contex
 		})
 	}
 
-	if *trip.AssignedDriver.UserUUID != *account.UserUUID {
+	if trip.AssignedDriver == nil || *trip.AssignedDriver.UserUUID != *account.UserUUID {
 		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
 			"error": "unauthorized",
 		})
 	}
 
-	trip.Status = ptr.String("cancelled")
-	trip.UpdatedAt = ptr.Time(time.Now())
+	err = lifecycle.Apply(trip, lifecycle.StatusCancelled, lifecycle.RoleDriver)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	riderUUIDs := tripRiderUUIDs(trip)
+	participants := stream.Participants(trip)
 	trip.AssignedDriver = nil
 
-	_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": tripUUID}, bson.M{"$set": trip})
+	_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": tripUUID}, bson.M{"$set": bson.M{
+		"status":     trip.Status,
+		"updated_at": trip.UpdatedAt,
+	}})
 	if err != nil {
 		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
 
-	currentTime := time.Now()
+	// Trips can be cancelled while they're active, so location sharing stops here too
+	if err := location.Stop(ctx, tripUUID); err != nil {
+		fmt.Println("Error deleting trip locations: ", err)
+	}
+	if err := chat.Expire(ctx, tripUUID, time.Now()); err != nil {
+		fmt.Println("Error expiring trip messages: ", err)
+	}
+
+	for _, riderUUID := range riderUUIDs {
+		notifyTrip(ctx, notify.EventTripCancelled, riderUUID, trip, &account)
+	}
+	stream.Publish(stream.EventTripCancelled, trip, participants...)
+	recordTripEvents(ctx, history.New(trip, history.EventCancelled, *account.UserUUID, ""))
 
 	// if the trip is cancelled 3 or more days before the trip, dont do anything
-	issueWarning := true
-	if currentTime.AddDate(0, 0, 3).Before(*trip.Datetime) {
-		issueWarning = false
-	}
+	issueWarning := lateCancellation(*trip.Datetime)
 
 	go func() {
 		util.JSONResponse(res, http.StatusOK, map[string]interface{}{
@@ -78,23 +103,33 @@ func CancelTripDriverFlow(req *http.Request, res http.ResponseWriter, ctx contex
 
 
 	if issueWarning {
-		warning := &warningEntities.WarningEntity{
-			WarningUUID: ptr.String(uuid.NewRandom().String()),
-			UserUUID: account.UserUUID,
-			Type: ptr.String("warning"),
-			Points: ptr.Int(1),
-			IssuedAt: ptr.Time(time.Now()),
-			Reason: ptr.String("Trip cancelled"),
-			IssuedBy: ptr.String(*account.UserUUID),
-			Resolved: ptr.Bool(false),
-			ResolvesAt: ptr.Time(time.Now().Add(time.Hour * 24 * 30)),
-			ResolvedAt: nil,
-			CreatedAt: ptr.Time(time.Now()),
-			UpdatedAt: ptr.Time(time.Now()),
-		}
-
-		dispatch.DispatchWarningEvent(warning, *trip.AssignedDriver.UserUUID)
+		dispatchCancellationWarning(*account.UserUUID, "Trip cancelled")
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// lateCancellation reports whether cancelling a trip leaving at datetime earns a warning
+func lateCancellation(datetime time.Time) bool {
+	return !time.Now().AddDate(0, 0, 3).Before(datetime)
+}
+
+// dispatchCancellationWarning issues the warning for cancelling or leaving a trip too close to when it leaves
+func dispatchCancellationWarning(userUUID string, reason string) {
+	warning := &warningEntities.WarningEntity{
+		WarningUUID: ptr.String(uuid.NewRandom().String()),
+		UserUUID: &userUUID,
+		Type: ptr.String("warning"),
+		Points: ptr.Int(1),
+		IssuedAt: ptr.Time(time.Now()),
+		Reason: ptr.String(reason),
+		IssuedBy: ptr.String(userUUID),
+		Resolved: ptr.Bool(false),
+		ResolvesAt: ptr.Time(time.Now().Add(time.Hour * 24 * 30)),
+		ResolvedAt: nil,
+		CreatedAt: ptr.Time(time.Now()),
+		UpdatedAt: ptr.Time(time.Now()),
+	}
+
+	dispatch.DispatchWarningEvent(warning, userUUID)
+}
@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/notify"
+	"code.gatorpool.internal/trip/chat"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/stream"
+	"code.gatorpool.internal/util"
+	"github.com/go-chi/chi"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type SendTripMessageBody struct {
+	Body *string `json:"body"`
+}
+
+// GetTripMessages returns the trip's chat, newest first. Older pages are fetched by passing the
+// created_at of the last message as ?before=
+func GetTripMessages(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	var before *time.Time
+	if value := req.URL.Query().Get("before"); value != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+				"error": "invalid before",
+			})
+		}
+		before = &parsed
+	}
+
+	limit, _ := strconv.Atoi(req.URL.Query().Get("limit"))
+
+	trip, errResponse := findChatTrip(ctx, chi.URLParam(req, "trip_uuid"), *account.UserUUID, res)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	messages, err := chat.History(ctx, *trip.TripUUID, before, limit)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	unread, err := chat.Unread(ctx, *trip.TripUUID, *account.UserUUID)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
+		"messages": messages,
+		"unread":   unread,
+		"hasMore":  len(messages) == chat.PageSize(limit),
+		"open":     chat.Open(trip),
+		"success":  true,
+	})
+}
+
+// SendTripMessage posts a message to the trip's chat and pushes it to the other members
+func SendTripMessage(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	var body SendTripMessageBody
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil || body.Body == nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid body",
+		})
+	}
+
+	text, err := chat.Validate(*body.Body)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	trip, errResponse := findChatTrip(ctx, chi.URLParam(req, "trip_uuid"), *account.UserUUID, res)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	if !chat.Open(trip) {
+		return util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
+			"error": "the trip is over, its chat is read only",
+		})
+	}
+
+	message, err := chat.Send(ctx, *trip.TripUUID, *account.UserUUID, text)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	members := chat.Members(trip)
+	stream.Default.Publish(stream.Event{
+		Type:         stream.EventMessageSent,
+		TripUUID:     *trip.TripUUID,
+		Data:         message,
+		Participants: members,
+	})
+
+	// Members who aren't connected to the stream get a notification instead. Only this server's
+	// streams are known, so someone connected to another one may get both.
+	for _, memberUUID := range members {
+		if memberUUID == *account.UserUUID || stream.Default.Connected(memberUUID) {
+			continue
+		}
+
+		event := notify.TripEvent(notify.EventTripMessage, memberUUID, trip, map[string]string{
+			"NAME":    notify.FirstName(&account),
+			"MESSAGE": text,
+		})
+		if err := notify.Enqueue(ctx, event); err != nil {
+			fmt.Println("Error queueing "+notify.EventTripMessage+" notification: ", err)
+		}
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"message": message,
+		"success": true,
+	})
+}
+
+// ReadTripMessages marks every message on the trip the user has been sent as read
+func ReadTripMessages(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	trip, errResponse := findChatTrip(ctx, chi.URLParam(req, "trip_uuid"), *account.UserUUID, res)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	now := time.Now()
+	read, err := chat.MarkRead(ctx, *trip.TripUUID, *account.UserUUID, now)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	// Let the senders know their messages were seen
+	if read > 0 {
+		stream.Default.Publish(stream.Event{
+			Type:     stream.EventMessagesRead,
+			TripUUID: *trip.TripUUID,
+			Data: map[string]interface{}{
+				"user_uuid": *account.UserUUID,
+				"read_at":   now,
+			},
+			Participants: chat.Members(trip),
+		})
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"read":    read,
+		"success": true,
+	})
+}
+
+// findChatTrip returns the trip if the user is its driver or an accepted rider
+func findChatTrip(ctx context.Context, tripUUID string, userUUID string, res http.ResponseWriter) (*tripEntities.TripEntity, *http.Response) {
+	var trip *tripEntities.TripEntity
+	err := datastores.GetMongoDatabase(ctx).Collection(datastores.Trips).FindOne(ctx, bson.M{"trip_uuid": tripUUID}).Decode(&trip)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, util.JSONResponse(res, http.StatusNotFound, map[string]interface{}{
+				"error": "trip not found",
+			})
+		}
+		return nil, util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if !chat.IsMember(trip, userUUID) {
+		return nil, util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "unauthorized",
+		})
+	}
+
+	return trip, nil
+}
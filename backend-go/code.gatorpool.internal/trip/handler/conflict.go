@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/trip/conflict"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/history"
+	"code.gatorpool.internal/trip/lifecycle"
+	"code.gatorpool.internal/trip/stream"
+	"code.gatorpool.internal/util"
+	"github.com/go-chi/chi"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AcceptTripConflict is a rider agreeing to the driver's changes and staying on the trip
+func AcceptTripConflict(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	trip, errResponse := findConflictTrip(ctx, chi.URLParam(req, "trip_uuid"), res)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	conflictUUID := chi.URLParam(req, "conflict_uuid")
+	now := time.Now()
+
+	response, err := conflict.Respond(trip, conflictUUID, *account.UserUUID, conflict.StatusAccepted, now)
+	if errResponse := conflictErrorResponse(res, err); errResponse != nil {
+		return errResponse
+	}
+
+	// Only this rider's response, and only while it's still pending, so other riders responding and
+	// the driver making more changes at the same time aren't overwritten
+	result, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Trips).UpdateOne(ctx, bson.M{
+		"trip_uuid": trip.TripUUID,
+		"status":    lifecycle.StatusPending,
+		"conflicts": bson.M{"$elemMatch": bson.M{
+			"conflict_uuid": conflictUUID,
+			"riders":        bson.M{"$elemMatch": bson.M{"user_uuid": account.UserUUID, "status": conflict.StatusPending}},
+		}},
+	}, bson.M{"$set": bson.M{
+		"conflicts.$[c].riders.$[r].status":       response.Status,
+		"conflicts.$[c].riders.$[r].responded_at": response.RespondedAt,
+	}}, options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+		bson.M{"c.conflict_uuid": conflictUUID},
+		bson.M{"r.user_uuid": account.UserUUID, "r.status": conflict.StatusPending},
+	}}))
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	if result.MatchedCount == 0 {
+		return conflictErrorResponse(res, conflict.ErrAlreadyResponded)
+	}
+
+	stream.Publish(stream.EventTripUpdated, trip)
+	recordTripEvents(ctx, history.New(trip, history.EventChangesAccepted, *account.UserUUID, *account.UserUUID))
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"conflicts": conflict.Pending(trip, *account.UserUUID),
+		"success":   true,
+	})
+}
+
+// LeaveTripConflict is a rider leaving the trip over the driver's changes
+func LeaveTripConflict(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	trip, errResponse := findConflictTrip(ctx, chi.URLParam(req, "trip_uuid"), res)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	// leaveTrip marks this and every other change they haven't responded to as left
+	_, err := conflict.Check(trip, chi.URLParam(req, "conflict_uuid"), *account.UserUUID)
+	if errResponse := conflictErrorResponse(res, err); errResponse != nil {
+		return errResponse
+	}
+
+	_, err = leaveTrip(ctx, trip, &account)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// findConflictTrip returns the trip if riders can still respond to changes, which is until it starts
+func findConflictTrip(ctx context.Context, tripUUID string, res http.ResponseWriter) (*tripEntities.TripEntity, *http.Response) {
+	var trip *tripEntities.TripEntity
+	err := datastores.GetMongoDatabase(ctx).Collection(datastores.Trips).FindOne(ctx, bson.M{"trip_uuid": tripUUID}).Decode(&trip)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, util.JSONResponse(res, http.StatusNotFound, map[string]interface{}{
+				"error": "trip not found",
+			})
+		}
+		return nil, util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if trip.Status == nil || *trip.Status != lifecycle.StatusPending {
+		return nil, util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
+			"error": "changes can only be responded to before the trip starts",
+		})
+	}
+
+	return trip, nil
+}
+
+func conflictErrorResponse(res http.ResponseWriter, err error) *http.Response {
+	switch err {
+	case nil:
+		return nil
+	case conflict.ErrConflictNotFound:
+		return util.JSONResponse(res, http.StatusNotFound, map[string]interface{}{
+			"error": err.Error(),
+		})
+	case conflict.ErrNotAffected:
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": err.Error(),
+		})
+	default:
+		return util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
@@ -10,7 +10,13 @@ import (
 	accountEntities "code.gatorpool.internal/account/entities"
 	datastores "code.gatorpool.internal/datastores/mongo"
 	driverEntities "code.gatorpool.internal/driver/entities"
+	"code.gatorpool.internal/rider/alerts"
 	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/fare"
+	"code.gatorpool.internal/trip/geo"
+	"code.gatorpool.internal/trip/history"
+	"code.gatorpool.internal/trip/lifecycle"
+	"code.gatorpool.internal/trip/seats"
 	"code.gatorpool.internal/util"
 	"code.gatorpool.internal/util/ptr"
 	"github.com/pborman/uuid"
@@ -47,15 +53,21 @@ type CreateTripBodyRequest struct {
 		Minimal bool `json:"minimal"`
 		Silent  bool `json:"silent"`
 	} `json:"talking_preferences"`
-	Carpool bool `json:"carpool"`
-	Fare    struct {
+	Carpool     bool   `json:"carpool"`
+	VehicleUUID string `json:"vehicle_uuid"`
+	Fare        struct {
 		Gas  float64 `json:"gas"`
 		Trip float64 `json:"trip"`
 		Food float64 `json:"food"`
+
+		// How the fare is split between riders, equal if empty
+		Split string `json:"split"`
 	} `json:"fare"`
 	RiderRequirements struct {
 		FemalesOnly bool `json:"females_only"`
 	} `json:"rider_requirements"`
+	// Optional, repeats the trip weekly
+	Recurrence *CreateTripRecurrenceBody `json:"recurrence"`
 }
 
 func CreateTrip(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
@@ -68,6 +80,10 @@ func CreateTrip(req *http.Request, res http.ResponseWriter, ctx context.Context)
 		})
 	}
 
+	if errResponse := checkStanding(ctx, res, *account.UserUUID, true); errResponse != nil {
+		return errResponse
+	}
+
 	var requestBody RequestBody
 	err := json.NewDecoder(req.Body).Decode(&requestBody)
 	if err != nil {
@@ -94,6 +110,12 @@ func CreateTrip(req *http.Request, res http.ResponseWriter, ctx context.Context)
 		})
 	}
 
+	if !geo.Valid(body.From.Lat, body.From.Lng) || !geo.Valid(body.To.Lat, body.To.Lng) {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid coordinates",
+		})
+	}
+
 	newTripUuid := uuid.NewRandom().String()
 
 	fromWaypoint := &tripEntities.WaypointEntity{
@@ -105,7 +127,6 @@ func CreateTrip(req *http.Request, res http.ResponseWriter, ctx context.Context)
 		Latitude:  ptr.Float64(body.From.Lat),
 		Longitude: ptr.Float64(body.From.Lng),
 		Expected:  ptr.Time(time.UnixMilli(body.From.Expected)),
-		Actual:    ptr.Time(time.UnixMilli(body.From.Expected)),
 		GeoText:   ptr.String(body.From.Text),
 	}
 
@@ -118,10 +139,11 @@ func CreateTrip(req *http.Request, res http.ResponseWriter, ctx context.Context)
 		Latitude:  ptr.Float64(body.To.Lat),
 		Longitude: ptr.Float64(body.To.Lng),
 		Expected:  ptr.Time(time.UnixMilli(body.To.Expected)),
-		Actual:    ptr.Time(time.UnixMilli(body.To.Expected)),
 		GeoText:   ptr.String(body.To.Text),
 	}
 
+	geo.Locate(fromWaypoint, toWaypoint)
+
 	assignedDriver := &tripEntities.TripAssignedDriverEntity{
 		UserUUID:   driver.DriverUUID,
 		Address:    toWaypoint,
@@ -145,6 +167,16 @@ func CreateTrip(req *http.Request, res http.ResponseWriter, ctx context.Context)
 		})
 	}
 
+	if err := fare.ValidSplit(body.Fare.Split); err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	split := fare.SplitEqual
+	if body.Fare.Split != "" {
+		split = body.Fare.Split
+	}
+
 	aggregatedFare := body.Fare.Gas + body.Fare.Trip + body.Fare.Food
 
 	payGasRiderRequirement := false
@@ -169,12 +201,13 @@ func CreateTrip(req *http.Request, res http.ResponseWriter, ctx context.Context)
 		Datetime:        ptr.Time(datetime),
 		CurrentLocation: fromWaypoint,
 		Riders:          []*tripEntities.TripRiderEntity{},
-		Status:          ptr.String("pending"),
+		Status:          ptr.String(lifecycle.StatusPending),
 		Fare: &tripEntities.TripFareEntity{
 			Gas:        ptr.Float64(body.Fare.Gas),
 			Trip:       ptr.Float64(body.Fare.Trip),
 			Food:       ptr.Float64(body.Fare.Food),
 			Aggregated: ptr.Float64(aggregatedFare),
+			Split:      ptr.String(split),
 		},
 		RiderRequirements: &tripEntities.TripRiderRequirementsEntity{
 			PayGas:  &payGasRiderRequirement,
@@ -190,11 +223,36 @@ func CreateTrip(req *http.Request, res http.ResponseWriter, ctx context.Context)
 			Talking: &tripEntities.TripMiscellaneousTalkingOptionsEntity{},
 		},
 		Conflicts:        []*tripEntities.TripConflictEntity{},
-		MaxRadiusDropOff: ptr.Float64(float64(body.Radius)),
+		MaxRadiusDropOff: ptr.Float64(geo.Radius(ptr.Float64(float64(body.Radius)))),
 		CreatedAt:        ptr.Time(time.Now()),
 		UpdatedAt:        ptr.Time(time.Now()),
 	}
 
+	// Snapshot the vehicle so the trip keeps its seat count even if the driver edits their vehicles later
+	vehicle := seats.FindVehicle(&driver, body.VehicleUUID)
+	if vehicle == nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "vehicle not found",
+		})
+	}
+
+	seats.Snapshot(newTrip, vehicle)
+	fare.Split(newTrip)
+	geo.Trace(newTrip)
+
+	// Fares over the per mile cap are flagged, or turned away if the cap is set to reject
+	settings, err := fare.LoadSettings(ctx)
+	if err != nil {
+		fmt.Println("Error loading fare settings: ", err)
+	}
+	fareCheck, err := fare.CheckCap(newTrip.Fare, fare.TripMiles(newTrip, settings), settings)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error":      err.Error(),
+			"fare_check": fareCheck,
+		})
+	}
+
 	if body.TalkingPreferences.Minimal {
 		newTrip.Miscellaneous.Talking.Type = ptr.String("minimal")
 	}
@@ -213,6 +271,10 @@ func CreateTrip(req *http.Request, res http.ResponseWriter, ctx context.Context)
 		}
 	}
 
+	if body.Recurrence != nil {
+		return createTripSeries(ctx, res, account, newTrip, body.Recurrence, fareCheck)
+	}
+
 	_, err = tripsCollection.InsertOne(ctx, newTrip)
 	if err != nil {
 		fmt.Println("Error inserting trip: ", err)
@@ -221,8 +283,14 @@ func CreateTrip(req *http.Request, res http.ResponseWriter, ctx context.Context)
 		})
 	}
 
+	recordTripEvents(ctx, history.New(newTrip, history.EventCreated, *account.UserUUID, ""))
+
+	// Let riders with a saved search alert know about the trip
+	go alerts.Notify(context.Background(), newTrip)
+
 	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
-		"trip_uuid": newTripUuid,
-		"success":   true,
+		"trip_uuid":  newTripUuid,
+		"fare_check": fareCheck,
+		"success":    true,
 	})
 }
@@ -9,13 +9,21 @@ import (
 
 	accountEntities "code.gatorpool.internal/account/entities"
 	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/notify"
 	riderEntities "code.gatorpool.internal/rider/entities"
 	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/geo"
+	"code.gatorpool.internal/trip/history"
+	"code.gatorpool.internal/trip/lifecycle"
+	"code.gatorpool.internal/trip/seats"
+	"code.gatorpool.internal/trip/stream"
 	"code.gatorpool.internal/util"
 	"code.gatorpool.internal/util/ptr"
 	"github.com/go-chi/chi"
 	"github.com/pborman/uuid"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type RiderRequestTripBody struct {
@@ -51,6 +59,10 @@ func RiderRequestTrip(req *http.Request, res http.ResponseWriter, ctx context.Co
 		})
 	}
 
+	if errResponse := checkStanding(ctx, res, *account.UserUUID, true); errResponse != nil {
+		return errResponse
+	}
+
 	db := datastores.GetMongoDatabase(context.Background())
 
 	tripsCollection := db.Collection(datastores.Trips)
@@ -81,6 +93,12 @@ func RiderRequestTrip(req *http.Request, res http.ResponseWriter, ctx context.Co
 		})
 	}
 
+	if !geo.Valid(body.From.Lat, body.From.Lng) || !geo.Valid(body.To.Lat, body.To.Lng) {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid coordinates",
+		})
+	}
+
 	newTripUuid := uuid.NewRandom().String()
 
 	fromWaypoint := &tripEntities.WaypointEntity{
@@ -111,6 +129,8 @@ func RiderRequestTrip(req *http.Request, res http.ResponseWriter, ctx context.Co
 		Expected:  &expectedTime,
 	}
 
+	geo.Locate(fromWaypoint, toWaypoint)
+
 	femalesOnly := false
 	if body.FemalesOnly && *account.Gender == "female" {
 		femalesOnly = true
@@ -141,7 +161,7 @@ func RiderRequestTrip(req *http.Request, res http.ResponseWriter, ctx context.Co
 		Datetime:        ptr.Time(datetime),
 		CurrentLocation: fromWaypoint,
 		Riders:          []*tripEntities.TripRiderEntity{tripRiderEntity},
-		Status:          ptr.String("pending"),
+		Status:          ptr.String(lifecycle.StatusPending),
 		DriverRequirements: &tripEntities.TripDriverRequirementsEntity{
 			FemalesOnly: ptr.Bool(femalesOnly),
 		},
@@ -163,11 +183,15 @@ func RiderRequestTrip(req *http.Request, res http.ResponseWriter, ctx context.Co
 			Talking: &tripEntities.TripMiscellaneousTalkingOptionsEntity{},
 		},
 		Conflicts:        []*tripEntities.TripConflictEntity{},
-		MaxRadiusDropOff: ptr.Float64(float64(50)),
+		MaxRadiusDropOff: ptr.Float64(geo.DefaultRadiusMiles),
 		CreatedAt:        ptr.Time(time.Now()),
 		UpdatedAt:        ptr.Time(time.Now()),
 	}
 
+	// The rider who posted the trip takes the only seat until a driver is assigned
+	seats.Snapshot(newTrip, nil)
+	geo.Trace(newTrip)
+
 	_, err = tripsCollection.InsertOne(ctx, newTrip)
 	if err != nil {
 		fmt.Println("Error inserting trip: ", err)
@@ -176,6 +200,8 @@ func RiderRequestTrip(req *http.Request, res http.ResponseWriter, ctx context.Co
 		})
 	}
 
+	recordTripEvents(ctx, history.New(newTrip, history.EventCreated, *account.UserUUID, ""))
+
 	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
 		"trip_uuid": newTripUuid,
 		"success": true,
@@ -188,6 +214,10 @@ func DriverFlowRiderRequestTrip(req *http.Request, res http.ResponseWriter, ctx
 	
 	tripUUID := chi.URLParam(req, "trip_uuid")
 
+	if errResponse := checkStanding(ctx, res, *account.UserUUID, false); errResponse != nil {
+		return errResponse
+	}
+
 	db := datastores.GetMongoDatabase(context.Background())
 
 	tripsCollection := db.Collection(datastores.Trips)
@@ -214,47 +244,21 @@ func DriverFlowRiderRequestTrip(req *http.Request, res http.ResponseWriter, ctx
 		}
 	}
 
-	newWaypoint := &tripEntities.WaypointEntity{
-		Type: ptr.String("pickup"),
-		For:  ptr.String("rider"),
-		Data: map[string]interface{}{
-			"rider_uuid": account.UserUUID,
-			"first_name": account.FirstName,
-			"last_name": account.LastName,
-		},
-		Latitude:  trip.Waypoints[0].Latitude,
-		Longitude: trip.Waypoints[0].Longitude,
-		Name: trip.Waypoints[0].Name,
-		Address: trip.Waypoints[0].Address,
-		Address2: trip.Waypoints[0].Address2,
-		City: trip.Waypoints[0].City,
-		State: trip.Waypoints[0].State,
-		Zip: trip.Waypoints[0].Zip,
-		GeoText: trip.Waypoints[0].GeoText,
-		Expected: trip.Waypoints[0].Expected,
-		Actual: trip.Waypoints[0].Actual,
-	}
-
-	tripRiderEntity := &tripEntities.TripRiderEntity{
-		UserUUID: account.UserUUID,
-		Address: newWaypoint,
-		Accepted: ptr.Bool(false),
-		AcceptedAt: nil,
-		Rating: nil,
-		Review: nil,
-		Willing: &tripEntities.TripRiderWillingEntity{
-			PayFood: trip.RiderRequirements.PayFood,
-			PayGas: trip.RiderRequirements.PayGas,
-			Custom: map[string]interface{}{},
-		},
-		CreatedAt: ptr.Time(time.Now()),
-	}
+	tripRiderEntity := newRiderRequest(&trip, &account)
 
 	trip.Riders = append(trip.Riders, tripRiderEntity)
 
-	_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": tripUUID}, bson.M{"$set": bson.M{"riders": trip.Riders}})
+	// Pushed rather than set, so requests and accepts at the same time don't overwrite each other
+	result, err := tripsCollection.UpdateOne(ctx, bson.M{
+		"trip_uuid":        tripUUID,
+		"riders.user_uuid": bson.M{"$ne": *account.UserUUID},
+	}, bson.M{"$push": bson.M{"riders": tripRiderEntity}})
 	if err != nil {
 		fmt.Println("Error updating trip: ", err)
+	} else if result.ModifiedCount > 0 {
+		notifyTrip(ctx, notify.EventTripRequested, tripDriverUUID(&trip), &trip, &account)
+		stream.Publish(stream.EventRiderRequested, &trip)
+		recordTripEvents(ctx, history.New(&trip, history.EventRequested, *account.UserUUID, *account.UserUUID))
 	}
 
 	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
@@ -285,19 +289,75 @@ func DriverFlowAcceptRiderRequest(req *http.Request, res http.ResponseWriter, ct
 		})
 	}
 
-	for i, rider := range trip.Riders {
+	var requestingRider *tripEntities.TripRiderEntity
+	for _, rider := range trip.Riders {
 		if *rider.UserUUID == riderUUID {
-			trip.Riders[i].Accepted = ptr.Bool(true)
-			trip.Riders[i].AcceptedAt = ptr.Time(time.Now())
+			requestingRider = rider
 			break
 		}
 	}
 
-	_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": tripUUID}, bson.M{"$set": bson.M{"riders": trip.Riders}})
-	if err != nil {
+	if requestingRider == nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "rider request not found",
+		})
+	}
+
+	if requestingRider.Accepted != nil && *requestingRider.Accepted {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "rider has already been accepted",
+		})
+	}
+
+	// Trips created before seats were tracked get their seat counts filled in first
+	if trip.SeatsRemaining == nil {
+		seats.Recount(&trip)
+		capacity := seats.TripCapacity(&trip)
+		trip.Seats = &capacity
+
+		_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": tripUUID}, bson.M{"$set": bson.M{"seats": trip.Seats, "seats_remaining": trip.SeatsRemaining}})
+		if err != nil {
+			fmt.Println("Error updating trip: ", err)
+		}
+	}
+
+	// Only accept the rider while there is still a seat left, so two accepts can't both take the last one
+	acceptedAt := time.Now()
+	err = tripsCollection.FindOneAndUpdate(ctx, bson.M{
+		"trip_uuid":       tripUUID,
+		"seats_remaining": bson.M{"$gt": 0},
+		"riders": bson.M{"$elemMatch": bson.M{
+			"user_uuid": riderUUID,
+			"accepted":  false,
+		}},
+	}, bson.M{
+		"$set": bson.M{
+			"riders.$.accepted":    true,
+			"riders.$.accepted_at": acceptedAt,
+		},
+		"$inc": bson.M{"seats_remaining": -1},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&trip)
+	if err == mongo.ErrNoDocuments {
+		return util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
+			"error": "there are no seats left on this trip",
+		})
+	} else if err != nil {
 		fmt.Println("Error updating trip: ", err)
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": "error updating trip",
+		})
+	}
+
+	// The new rider takes a share of the fare, split from the trip as it is after the accept so
+	// riders accepted at the same time all get one
+	if err := saveFareShares(ctx, tripsCollection, &trip); err != nil {
+		fmt.Println("Error updating fare shares: ", err)
 	}
 
+	notifyTrip(ctx, notify.EventRequestAccepted, riderUUID, &trip, &account)
+	stream.Publish(stream.EventRiderAccepted, &trip)
+	recordTripEvents(ctx, history.New(&trip, history.EventAccepted, *account.UserUUID, riderUUID))
+
 	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
 		"success": true,
 		"trip": trip,
@@ -327,20 +387,60 @@ func DriverFlowRejectRiderRequest(req *http.Request, res http.ResponseWriter, ct
 		})
 	}
 
-	for i, rider := range trip.Riders {
-		if *rider.UserUUID == riderUUID {
-			trip.Riders = append(trip.Riders[:i], trip.Riders[i+1:]...)
-			break
-		}
-	}
-
-	_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": tripUUID}, bson.M{"$set": bson.M{"riders": trip.Riders}})
+	rejected, err := pullRider(ctx, tripsCollection, &trip, riderUUID)
 	if err != nil {
 		fmt.Println("Error updating trip: ", err)
+	} else if rejected {
+		notifyTrip(ctx, notify.EventRequestRejected, riderUUID, &trip, &account)
+		stream.Publish(stream.EventRiderRejected, &trip, riderUUID)
+		recordTripEvents(ctx, history.New(&trip, history.EventRejected, *account.UserUUID, riderUUID))
 	}
 
 	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
 		"success": true,
 		"trip": trip,
 	})	
-}
\ No newline at end of file
+}
+
+// newRiderRequest returns a request for the account to ride on the trip, picked up where the driver starts
+func newRiderRequest(trip *tripEntities.TripEntity, account *accountEntities.AccountEntity) *tripEntities.TripRiderEntity {
+
+	newWaypoint := &tripEntities.WaypointEntity{
+		Type: ptr.String("pickup"),
+		For:  ptr.String("rider"),
+		Data: map[string]interface{}{
+			"rider_uuid": account.UserUUID,
+			"first_name": account.FirstName,
+			"last_name": account.LastName,
+		},
+		Latitude:  trip.Waypoints[0].Latitude,
+		Longitude: trip.Waypoints[0].Longitude,
+		Name: trip.Waypoints[0].Name,
+		Address: trip.Waypoints[0].Address,
+		Address2: trip.Waypoints[0].Address2,
+		City: trip.Waypoints[0].City,
+		State: trip.Waypoints[0].State,
+		Zip: trip.Waypoints[0].Zip,
+		GeoText: trip.Waypoints[0].GeoText,
+		Expected: trip.Waypoints[0].Expected,
+		Actual: trip.Waypoints[0].Actual,
+	}
+	geo.Locate(newWaypoint)
+
+	tripRiderEntity := &tripEntities.TripRiderEntity{
+		UserUUID: account.UserUUID,
+		Address: newWaypoint,
+		Accepted: ptr.Bool(false),
+		AcceptedAt: nil,
+		Rating: nil,
+		Review: nil,
+		Willing: &tripEntities.TripRiderWillingEntity{
+			PayFood: trip.RiderRequirements.PayFood,
+			PayGas: trip.RiderRequirements.PayGas,
+			Custom: map[string]interface{}{},
+		},
+		CreatedAt: ptr.Time(time.Now()),
+	}
+
+	return tripRiderEntity
+}
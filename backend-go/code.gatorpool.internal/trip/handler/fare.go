@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	datastores "code.gatorpool.internal/datastores/mongo"
+	driverEntities "code.gatorpool.internal/driver/entities"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/fare"
+	"code.gatorpool.internal/trip/geo"
+	"code.gatorpool.internal/trip/seats"
+	"code.gatorpool.internal/util"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type SuggestFareBody struct {
+	From struct {
+		Lat float64 `json:"lat"`
+		Lng float64 `json:"lng"`
+	} `json:"from"`
+	To struct {
+		Lat float64 `json:"lat"`
+		Lng float64 `json:"lng"`
+	} `json:"to"`
+
+	// Optional, uses the vehicle's MPG if the account is a driver. Defaults to their first vehicle.
+	VehicleUUID string `json:"vehicle_uuid"`
+}
+
+// SuggestFare returns what a trip between two points should cost, so drivers have something to
+// go on and riders can tell whether a price is fair
+func SuggestFare(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	var body SuggestFareBody
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil || !geo.Valid(body.From.Lat, body.From.Lng) || !geo.Valid(body.To.Lat, body.To.Lng) {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid body",
+		})
+	}
+
+	settings, err := fare.LoadSettings(ctx)
+	if err != nil {
+		fmt.Println("Error loading fare settings: ", err)
+	}
+
+	var driver driverEntities.DriverEntity
+	err = datastores.GetMongoDatabase(ctx).Collection(datastores.Drivers).FindOne(ctx, bson.M{"driver_uuid": account.UserUUID}).Decode(&driver)
+	if err != nil && err != mongo.ErrNoDocuments {
+		fmt.Println("Error finding driver: ", err)
+	}
+
+	miles := fare.RouteMiles([]geo.LatLng{
+		{Lat: body.From.Lat, Lng: body.From.Lng},
+		{Lat: body.To.Lat, Lng: body.To.Lng},
+	}, settings)
+	mpg := fare.VehicleMPG(seats.FindVehicle(&driver, body.VehicleUUID), settings)
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"suggestion": fare.Suggest(miles, mpg, settings),
+		"success":    true,
+	})
+}
+
+// saveFareShares splits the fare between the trip's accepted riders and saves the shares, as long as
+// those are still the accepted riders. If another accept or removal got in first, it reloads the trip
+// and splits again, so neither write loses the other's rider.
+func saveFareShares(ctx context.Context, tripsCollection *mongo.Collection, trip *tripEntities.TripEntity) error {
+	for attempt := 0; attempt < 3; attempt++ {
+		if trip.Fare == nil {
+			return nil
+		}
+		fare.Split(trip)
+
+		result, err := tripsCollection.UpdateOne(ctx, acceptedRidersFilter(trip), bson.M{"$set": bson.M{"fare.shares": trip.Fare.Shares}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 1 {
+			return nil
+		}
+
+		if err := tripsCollection.FindOne(ctx, bson.M{"trip_uuid": trip.TripUUID}).Decode(trip); err != nil {
+			return err
+		}
+	}
+
+	return errors.New("accepted riders kept changing while splitting the fare")
+}
+
+// acceptedRidersFilter matches the trip only while exactly the riders accepted on it now are accepted
+func acceptedRidersFilter(trip *tripEntities.TripEntity) bson.M {
+	accepted := []string{}
+	for _, rider := range trip.Riders {
+		if rider.Accepted != nil && *rider.Accepted && rider.UserUUID != nil {
+			accepted = append(accepted, *rider.UserUUID)
+		}
+	}
+
+	// No one else accepted...
+	filter := bson.M{
+		"trip_uuid": trip.TripUUID,
+		"riders": bson.M{"$not": bson.M{"$elemMatch": bson.M{
+			"accepted":  true,
+			"user_uuid": bson.M{"$nin": accepted},
+		}}},
+	}
+
+	// ...and everyone here still is
+	all := []bson.M{}
+	for _, userUUID := range accepted {
+		all = append(all, bson.M{"riders": bson.M{"$elemMatch": bson.M{"user_uuid": userUUID, "accepted": true}}})
+	}
+	if len(all) > 0 {
+		filter["$and"] = all
+	}
+
+	return filter
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/account/roles"
+	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/trip/conflict"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/history"
+	"code.gatorpool.internal/util"
+	"github.com/go-chi/chi"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetTripTimeline returns the events on the trip that the caller can see, oldest first
+func GetTripTimeline(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	tripUUID := chi.URLParam(req, "trip_uuid")
+
+	var trip *tripEntities.TripEntity
+	err := datastores.GetMongoDatabase(ctx).Collection(datastores.Trips).FindOne(ctx, bson.M{"trip_uuid": tripUUID}).Decode(&trip)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return util.JSONResponse(res, http.StatusNotFound, map[string]interface{}{
+				"error": "trip not found",
+			})
+		}
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	events, err := history.Timeline(ctx, tripUUID)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	// Moderators see the whole timeline when they're sorting out a dispute
+	if !roles.Has(&account, roles.Moderator) {
+		events, ok = history.Visible(trip, events, *account.UserUUID)
+		if !ok {
+			return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+				"error": "unauthorized",
+			})
+		}
+	}
+
+	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
+		"events":  events,
+		"success": true,
+	})
+}
+
+// editedEvent is an edit to the trip, with the fields it changed
+func editedEvent(before *tripEntities.TripEntity, after *tripEntities.TripEntity, actorUUID string) *tripEntities.TripEventEntity {
+	event := history.New(after, history.EventEdited, actorUUID, "")
+	event.OldValues, event.ChangedValues = conflict.Diff(before, after)
+	return event
+}
+
+// recordTripEvents adds to the trips' timelines. The change has already been saved by then, so a
+// failure is logged rather than failing the request
+func recordTripEvents(ctx context.Context, events ...*tripEntities.TripEventEntity) {
+	if err := history.Record(ctx, events...); err != nil {
+		fmt.Println("Error recording trip events: ", err)
+	}
+}
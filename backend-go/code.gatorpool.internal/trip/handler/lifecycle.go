@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/trip/conflict"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/history"
+	"code.gatorpool.internal/trip/housekeeping"
+	"code.gatorpool.internal/trip/lifecycle"
+	"code.gatorpool.internal/trip/stream"
+	"code.gatorpool.internal/util"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/go-chi/chi"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func StartTrip(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+	return transitionTrip(req, res, ctx, lifecycle.StatusActive)
+}
+
+func CompleteTrip(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+	return transitionTrip(req, res, ctx, lifecycle.StatusCompleted)
+}
+
+func transitionTrip(req *http.Request, res http.ResponseWriter, ctx context.Context, to string) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	tripUUID := chi.URLParam(req, "trip_uuid")
+
+	db := datastores.GetMongoDatabase(ctx)
+	tripsCollection := db.Collection(datastores.Trips)
+
+	var trip *tripEntities.TripEntity
+	err := tripsCollection.FindOne(ctx, bson.M{"trip_uuid": tripUUID}).Decode(&trip)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return util.JSONResponse(res, http.StatusNotFound, map[string]interface{}{
+				"error": "trip not found",
+			})
+		}
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	role := lifecycle.RoleFor(trip, *account.UserUUID)
+	if role == "" {
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "unauthorized",
+		})
+	}
+
+	// Riders have to accept the driver's changes or leave before the trip can start
+	if to == lifecycle.StatusActive {
+		if blocking := conflict.Blocking(trip); len(blocking) > 0 {
+			return util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
+				"error":          "some riders haven't accepted the changes to the trip yet",
+				"pending_riders": blocking,
+			})
+		}
+	}
+
+	err = lifecycle.Apply(trip, to, role)
+	if err == lifecycle.ErrRoleNotAllowed {
+		return util.JSONResponse(res, http.StatusForbidden, map[string]interface{}{
+			"error": err.Error(),
+		})
+	} else if err != nil {
+		return util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	// Record when the trip actually started or ended on the waypoints
+	now := time.Now()
+	if to == lifecycle.StatusActive {
+		trip.StartedAt = ptr.Time(now)
+	} else {
+		trip.CompletedAt = ptr.Time(now)
+	}
+
+	for _, waypoint := range trip.Waypoints {
+		if waypoint.Type == nil {
+			continue
+		}
+
+		if to == lifecycle.StatusActive && *waypoint.Type == "pickup" {
+			waypoint.Actual = ptr.Time(now)
+		}
+
+		if to == lifecycle.StatusCompleted && (*waypoint.Type == "destination" || *waypoint.Type == "dropoff") {
+			waypoint.Actual = ptr.Time(now)
+			trip.CurrentLocation = waypoint
+		}
+	}
+
+	_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": tripUUID}, bson.M{"$set": bson.M{
+		"status":           trip.Status,
+		"waypoints":        trip.Waypoints,
+		"current_location": trip.CurrentLocation,
+		"started_at":       trip.StartedAt,
+		"completed_at":     trip.CompletedAt,
+		"updated_at":       trip.UpdatedAt,
+	}})
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if to == lifecycle.StatusCompleted {
+		housekeeping.Completed(ctx, trip)
+		recordTripEvents(ctx, history.New(trip, history.EventCompleted, *account.UserUUID, ""))
+	} else {
+		stream.Publish(stream.EventTripStarted, trip)
+		recordTripEvents(ctx, history.New(trip, history.EventStarted, *account.UserUUID, ""))
+	}
+
+	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"trip":    trip,
+	})
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	datastores "code.gatorpool.internal/datastores/mongo"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/lifecycle"
+	"code.gatorpool.internal/trip/location"
+	"code.gatorpool.internal/util"
+	"github.com/go-chi/chi"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ShareLocationBody struct {
+	Lat      *float64 `json:"lat"`
+	Lng      *float64 `json:"lng"`
+	Heading  *float64 `json:"heading"`
+	Speed    *float64 `json:"speed"`
+	Accuracy *float64 `json:"accuracy"`
+
+	// When the device took the reading, defaults to now
+	RecordedAt *time.Time `json:"recorded_at"`
+}
+
+// ShareLocation records where the driver is while the trip is active
+func ShareLocation(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	var body ShareLocationBody
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil || body.Lat == nil || body.Lng == nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid body",
+		})
+	}
+
+	trip, errResponse := findLocationTrip(ctx, chi.URLParam(req, "trip_uuid"), res)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	if lifecycle.RoleFor(trip, *account.UserUUID) != lifecycle.RoleDriver {
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "unauthorized",
+		})
+	}
+
+	now := time.Now()
+	ping := location.Ping{
+		Lat:        *body.Lat,
+		Lng:        *body.Lng,
+		Heading:    body.Heading,
+		Speed:      body.Speed,
+		Accuracy:   body.Accuracy,
+		RecordedAt: now,
+	}
+	if body.RecordedAt != nil {
+		ping.RecordedAt = *body.RecordedAt
+	}
+
+	if err := location.Validate(ping, now); err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	err = location.Record(ctx, *trip.TripUUID, *account.UserUUID, ping)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
+
+// GetDriverLocation returns where the driver was last seen, and how long ago, to accepted riders
+func GetDriverLocation(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	trip, errResponse := findLocationTrip(ctx, chi.URLParam(req, "trip_uuid"), res)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	if !lifecycle.AcceptedRider(trip, *account.UserUUID) && lifecycle.RoleFor(trip, *account.UserUUID) != lifecycle.RoleDriver {
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "unauthorized",
+		})
+	}
+
+	position, err := location.Latest(ctx, *trip.TripUUID, time.Now())
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if position == nil {
+		return util.JSONResponse(res, http.StatusNotFound, map[string]interface{}{
+			"error": "the driver hasn't shared their location yet",
+		})
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"location": position,
+		"success":  true,
+	})
+}
+
+// findLocationTrip returns the trip if it's active, locations are only shared while it is
+func findLocationTrip(ctx context.Context, tripUUID string, res http.ResponseWriter) (*tripEntities.TripEntity, *http.Response) {
+	var trip *tripEntities.TripEntity
+	err := datastores.GetMongoDatabase(ctx).Collection(datastores.Trips).FindOne(ctx, bson.M{"trip_uuid": tripUUID}).Decode(&trip)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, util.JSONResponse(res, http.StatusNotFound, map[string]interface{}{
+				"error": "trip not found",
+			})
+		}
+		return nil, util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if trip.Status == nil || *trip.Status != lifecycle.StatusActive {
+		return nil, util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
+			"error": "location is only shared while the trip is active",
+		})
+	}
+
+	return trip, nil
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/notify"
+	tripEntities "code.gatorpool.internal/trip/entities"
+)
+
+// notifyTrip tells userUUID that the account did something to the trip. The change has already
+// been saved, so a notification that can't be queued is only logged.
+func notifyTrip(ctx context.Context, eventType string, userUUID string, trip *tripEntities.TripEntity, account *accountEntities.AccountEntity) {
+	if userUUID == "" || (account != nil && account.UserUUID != nil && *account.UserUUID == userUUID) {
+		return
+	}
+
+	event := notify.TripEvent(eventType, userUUID, trip, map[string]string{
+		"NAME": notify.FirstName(account),
+	})
+
+	if err := notify.Enqueue(ctx, event); err != nil {
+		fmt.Println("Error queueing "+eventType+" notification: ", err)
+	}
+}
+
+// tripRiderUUIDs is everyone riding or asking to ride the trip, including the rider who posted it
+func tripRiderUUIDs(trip *tripEntities.TripEntity) []string {
+	uuids := []string{}
+	seen := map[string]bool{}
+
+	add := func(userUUID *string) {
+		if userUUID == nil || seen[*userUUID] {
+			return
+		}
+		seen[*userUUID] = true
+		uuids = append(uuids, *userUUID)
+	}
+
+	if trip.PostedByType != nil && *trip.PostedByType == "rider" {
+		add(trip.PostedBy)
+	}
+	for _, rider := range trip.Riders {
+		if rider != nil {
+			add(rider.UserUUID)
+		}
+	}
+
+	return uuids
+}
+
+// tripDriverUUID is the driver of the trip, or whoever posted it if no driver has been assigned
+func tripDriverUUID(trip *tripEntities.TripEntity) string {
+	if trip.AssignedDriver != nil && trip.AssignedDriver.UserUUID != nil {
+		return *trip.AssignedDriver.UserUUID
+	}
+	if trip.PostedBy != nil {
+		return *trip.PostedBy
+	}
+	return ""
+}
@@ -0,0 +1,266 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	datastores "code.gatorpool.internal/datastores/mongo"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/lifecycle"
+	"code.gatorpool.internal/trip/rating"
+	"code.gatorpool.internal/util"
+	"github.com/go-chi/chi"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RateTripBody struct {
+	Rating float64 `json:"rating"`
+	Review string  `json:"review"`
+}
+
+// RiderRateDriver lets an accepted rider rate the driver of a completed trip
+func RiderRateDriver(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	tripUUID := chi.URLParam(req, "trip_uuid")
+
+	body, errResponse := decodeRateTripBody(req, res)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	db := datastores.GetMongoDatabase(ctx)
+	tripsCollection := db.Collection(datastores.Trips)
+
+	trip, errResponse := findRateableTrip(ctx, tripsCollection, tripUUID, res)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	if lifecycle.RoleFor(trip, *account.UserUUID) != lifecycle.RoleRider || !lifecycle.AcceptedRider(trip, *account.UserUUID) {
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "you were not a rider on this trip",
+		})
+	}
+
+	// Only set the rating if the rider hasn't rated yet, so duplicate requests can't race each other
+	ratedAt := time.Now()
+	result, err := tripsCollection.UpdateOne(ctx, bson.M{
+		"trip_uuid": tripUUID,
+		"riders": bson.M{"$elemMatch": bson.M{
+			"user_uuid": *account.UserUUID,
+			"rating":    nil,
+		}},
+	}, bson.M{"$set": bson.M{
+		"riders.$.rating":   body.Rating,
+		"riders.$.review":   body.Review,
+		"riders.$.rated_at": ratedAt,
+	}})
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if result.ModifiedCount == 0 {
+		return util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
+			"error": "you have already rated this driver",
+		})
+	}
+
+	average, err := addRating(ctx, db.Collection(datastores.Drivers), bson.M{"driver_uuid": *trip.AssignedDriver.UserUUID}, body.Rating)
+	if err != nil {
+		// Take the rating back off the trip so the rider can try again
+		unrate(ctx, tripsCollection, tripUUID, *account.UserUUID, "", ratedAt)
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"success":       true,
+		"driver_rating": average,
+	})
+}
+
+// DriverRateRider lets the driver of a completed trip rate one of its accepted riders
+func DriverRateRider(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	tripUUID := chi.URLParam(req, "trip_uuid")
+	riderUUID := chi.URLParam(req, "rider_uuid")
+
+	body, errResponse := decodeRateTripBody(req, res)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	db := datastores.GetMongoDatabase(ctx)
+	tripsCollection := db.Collection(datastores.Trips)
+
+	trip, errResponse := findRateableTrip(ctx, tripsCollection, tripUUID, res)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	if lifecycle.RoleFor(trip, *account.UserUUID) != lifecycle.RoleDriver {
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "you are not the driver of this trip",
+		})
+	}
+
+	if !lifecycle.AcceptedRider(trip, riderUUID) {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "rider was not on this trip",
+		})
+	}
+
+	ratedAt := time.Now()
+	result, err := tripsCollection.UpdateOne(ctx, bson.M{
+		"trip_uuid": tripUUID,
+		"riders": bson.M{"$elemMatch": bson.M{
+			"user_uuid":     riderUUID,
+			"driver_rating": nil,
+		}},
+	}, bson.M{"$set": bson.M{
+		"riders.$.driver_rating":   body.Rating,
+		"riders.$.driver_review":   body.Review,
+		"riders.$.driver_rated_at": ratedAt,
+	}})
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if result.ModifiedCount == 0 {
+		return util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
+			"error": "you have already rated this rider",
+		})
+	}
+
+	average, err := addRating(ctx, db.Collection(datastores.Riders), bson.M{"rider_uuid": riderUUID}, body.Rating)
+	if err != nil {
+		// Take the rating back off the trip so the driver can try again
+		unrate(ctx, tripsCollection, tripUUID, riderUUID, "driver_", ratedAt)
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"success":      true,
+		"rider_rating": average,
+	})
+}
+
+func decodeRateTripBody(req *http.Request, res http.ResponseWriter) (*RateTripBody, *http.Response) {
+	var body RateTripBody
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil {
+		return nil, util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid body",
+		})
+	}
+
+	body.Review, err = rating.Validate(body.Rating, body.Review)
+	if err != nil {
+		return nil, util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return &body, nil
+}
+
+// findRateableTrip returns the trip if it was completed within the rating window
+func findRateableTrip(ctx context.Context, tripsCollection *mongo.Collection, tripUUID string, res http.ResponseWriter) (*tripEntities.TripEntity, *http.Response) {
+	var trip *tripEntities.TripEntity
+	err := tripsCollection.FindOne(ctx, bson.M{"trip_uuid": tripUUID}).Decode(&trip)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, util.JSONResponse(res, http.StatusNotFound, map[string]interface{}{
+				"error": "trip not found",
+			})
+		}
+		return nil, util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if err := rating.Rateable(trip, time.Now()); err != nil {
+		return nil, util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return trip, nil
+}
+
+// unrate takes a rating back off the trip when it couldn't be added to the average. prefix is
+// "driver_" for the driver's rating of a rider. Only the rating made at ratedAt is removed.
+func unrate(ctx context.Context, tripsCollection *mongo.Collection, tripUUID string, riderUUID string, prefix string, ratedAt time.Time) {
+	_, err := tripsCollection.UpdateOne(ctx, bson.M{
+		"trip_uuid": tripUUID,
+		"riders": bson.M{"$elemMatch": bson.M{
+			"user_uuid":         riderUUID,
+			prefix + "rated_at": ratedAt,
+		}},
+	}, bson.M{"$unset": bson.M{
+		"riders.$." + prefix + "rating":   "",
+		"riders.$." + prefix + "review":   "",
+		"riders.$." + prefix + "rated_at": "",
+	}})
+	if err != nil {
+		fmt.Println("Error removing trip rating: ", err)
+	}
+}
+
+// addRating folds a new rating into the running average stored on a driver or rider document
+func addRating(ctx context.Context, collection *mongo.Collection, filter bson.M, rating float64) (*float64, error) {
+
+	count := bson.M{"$ifNull": bson.A{"$rating_count", 0}}
+	average := bson.M{"$ifNull": bson.A{"$rating", 0}}
+
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"rating": bson.M{"$divide": bson.A{
+				bson.M{"$add": bson.A{bson.M{"$multiply": bson.A{average, count}}, rating}},
+				bson.M{"$add": bson.A{count, 1}},
+			}},
+			"rating_count": bson.M{"$add": bson.A{count, 1}},
+		}}},
+	}
+
+	var updated struct {
+		Rating *float64 `bson:"rating"`
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return updated.Rating, nil
+}
@@ -4,13 +4,21 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"time"
 
 	accountEntities "code.gatorpool.internal/account/entities"
 	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/notify"
+	"code.gatorpool.internal/trip/conflict"
 	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/history"
+	"code.gatorpool.internal/trip/stream"
 	"code.gatorpool.internal/util"
+	"code.gatorpool.internal/util/ptr"
 	"github.com/go-chi/chi"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 func DriverFlowRemoveRider(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
@@ -36,16 +44,13 @@ func DriverFlowRemoveRider(req *http.Request, res http.ResponseWriter, ctx conte
 		})
 	}
 
-	for i, rider := range trip.Riders {
-		if *rider.UserUUID == riderUUID {
-			trip.Riders = append(trip.Riders[:i], trip.Riders[i+1:]...)
-			break
-		}
-	}
-
-	_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": tripUUID}, bson.M{"$set": bson.M{"riders": trip.Riders}})
+	removed, err := pullRider(ctx, tripsCollection, &trip, riderUUID)
 	if err != nil {
 		fmt.Println("Error updating trip: ", err)
+	} else if removed {
+		notifyTrip(ctx, notify.EventRiderRemoved, riderUUID, &trip, &account)
+		stream.Publish(stream.EventRiderRemoved, &trip, riderUUID)
+		recordTripEvents(ctx, history.New(&trip, history.EventRemoved, *account.UserUUID, riderUUID))
 	}
 
 	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
@@ -70,14 +75,7 @@ func RiderFlowRemoveRequest(req *http.Request, res http.ResponseWriter, ctx cont
 		fmt.Println("Error finding trip: ", err)
 	}
 
-	for i, rider := range trip.Riders {
-		if *rider.UserUUID == *account.UserUUID {
-			trip.Riders = append(trip.Riders[:i], trip.Riders[i+1:]...)
-			break
-		}
-	}
-
-	_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": tripUUID}, bson.M{"$set": bson.M{"riders": trip.Riders}})
+	_, err = leaveTrip(ctx, &trip, &account)
 	if err != nil {
 		fmt.Println("Error updating trip: ", err)
 	}
@@ -85,4 +83,92 @@ func RiderFlowRemoveRequest(req *http.Request, res http.ResponseWriter, ctx cont
 	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
 		"success": true,
 	})	
-}
\ No newline at end of file
+}
+
+// leaveTrip takes the account off the trip and reports whether they were on it. Leaving over a
+// change they haven't accepted that put their share of the fare up materially is noted as such.
+func leaveTrip(ctx context.Context, trip *tripEntities.TripEntity, account *accountEntities.AccountEntity) (bool, error) {
+	tripsCollection := datastores.GetMongoDatabase(ctx).Collection(datastores.Trips)
+
+	excused := conflict.Excused(trip, *account.UserUUID)
+	pending := conflict.Pending(trip, *account.UserUUID)
+
+	left, err := pullRider(ctx, tripsCollection, trip, *account.UserUUID)
+	if err != nil || !left {
+		return false, err
+	}
+
+	// The changes they hadn't responded to are marked as why they left
+	if len(pending) > 0 {
+		conflictUUIDs := []string{}
+		for _, tripConflict := range pending {
+			if tripConflict.ConflictUUID != nil {
+				conflictUUIDs = append(conflictUUIDs, *tripConflict.ConflictUUID)
+			}
+		}
+
+		now := time.Now()
+		_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": trip.TripUUID}, bson.M{"$set": bson.M{
+			"conflicts.$[c].riders.$[r].status":       conflict.StatusLeft,
+			"conflicts.$[c].riders.$[r].responded_at": now,
+		}}, options.Update().SetArrayFilters(options.ArrayFilters{Filters: []interface{}{
+			bson.M{"c.conflict_uuid": bson.M{"$in": conflictUUIDs}},
+			bson.M{"r.user_uuid": account.UserUUID, "r.status": conflict.StatusPending},
+		}}))
+		if err != nil {
+			fmt.Println("Error resolving trip conflicts: ", err)
+		} else {
+			conflict.Resolve(trip, *account.UserUUID, conflict.StatusLeft, now)
+		}
+	}
+
+	notifyTrip(ctx, notify.EventRiderLeft, tripDriverUUID(trip), trip, account)
+	stream.Publish(stream.EventRiderLeft, trip, *account.UserUUID)
+
+	event := history.New(trip, history.EventLeft, *account.UserUUID, *account.UserUUID)
+	if excused {
+		event.Reason = ptr.String("Didn't accept changes to the trip")
+	}
+	recordTripEvents(ctx, event)
+
+	return true, nil
+}
+// pullRider takes the rider off the trip and reports whether they were on it. An accepted rider
+// gives their seat back, and the fare is split again between the riders left. The trip is
+// reloaded as it is after.
+func pullRider(ctx context.Context, tripsCollection *mongo.Collection, trip *tripEntities.TripEntity, riderUUID string) (bool, error) {
+	after := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	pull := bson.M{"riders": bson.M{"user_uuid": riderUUID}}
+
+	err := tripsCollection.FindOneAndUpdate(ctx, bson.M{
+		"trip_uuid":       trip.TripUUID,
+		"seats_remaining": bson.M{"$ne": nil},
+		"riders":          bson.M{"$elemMatch": bson.M{"user_uuid": riderUUID, "accepted": true}},
+	}, bson.M{
+		"$pull": pull,
+		"$inc":  bson.M{"seats_remaining": 1},
+	}, after).Decode(trip)
+
+	// A request that was never accepted didn't take a seat, and trips from before seats were
+	// tracked are counted when the next rider is accepted
+	if err == mongo.ErrNoDocuments {
+		err = tripsCollection.FindOneAndUpdate(ctx, bson.M{
+			"trip_uuid": trip.TripUUID,
+			"$or": bson.A{
+				bson.M{"riders": bson.M{"$elemMatch": bson.M{"user_uuid": riderUUID, "accepted": bson.M{"$ne": true}}}},
+				bson.M{"riders.user_uuid": riderUUID, "seats_remaining": nil},
+			},
+		}, bson.M{"$pull": pull}, after).Decode(trip)
+	}
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	if err := saveFareShares(ctx, tripsCollection, trip); err != nil {
+		fmt.Println("Error updating fare shares: ", err)
+	}
+
+	return true, nil
+}
@@ -9,8 +9,14 @@ import (
 
 	accountEntities "code.gatorpool.internal/account/entities"
 	datastores "code.gatorpool.internal/datastores/mongo"
+	driverEntities "code.gatorpool.internal/driver/entities"
+	"code.gatorpool.internal/notify"
 	riderEntities "code.gatorpool.internal/rider/entities"
 	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/fare"
+	"code.gatorpool.internal/trip/history"
+	"code.gatorpool.internal/trip/seats"
+	"code.gatorpool.internal/trip/stream"
 	"code.gatorpool.internal/util"
 	"code.gatorpool.internal/util/ptr"
 	"github.com/go-chi/chi"
@@ -73,6 +79,10 @@ func RiderFlowDriverRequestTrip(req *http.Request, res http.ResponseWriter, ctx
 	
 	tripUUID := chi.URLParam(req, "trip_uuid")
 
+	if errResponse := checkStanding(ctx, res, *account.UserUUID, false); errResponse != nil {
+		return errResponse
+	}
+
 	db := datastores.GetMongoDatabase(context.Background())
 
 	tripsCollection := db.Collection(datastores.Trips)
@@ -116,6 +126,10 @@ func RiderFlowDriverRequestTrip(req *http.Request, res http.ResponseWriter, ctx
 	_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": tripUUID}, bson.M{"$set": bson.M{"driver_requests": trip.DriverRequests}})
 	if err != nil {
 		fmt.Println("Error updating trip: ", err)
+	} else if trip.PostedBy != nil {
+		notifyTrip(ctx, notify.EventDriverOffered, *trip.PostedBy, &trip, &account)
+		stream.Publish(stream.EventDriverRequested, &trip)
+		recordTripEvents(ctx, history.New(&trip, history.EventRequested, *account.UserUUID, *account.UserUUID))
 	}
 
 	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
@@ -176,8 +190,18 @@ func RiderFlowRiderAcceptDriverRequest(req *http.Request, res http.ResponseWrite
 
 	trip.AssignedDriver = newAssignedDriver
 
+	driversCollection := db.Collection(datastores.Drivers)
+	var driver driverEntities.DriverEntity
+	err = driversCollection.FindOne(ctx, bson.M{"driver_uuid": driverRequest.UserUUID}).Decode(&driver)
+	if err != nil {
+		fmt.Println("Error finding driver: ", err)
+	}
+
+	seats.Snapshot(&trip, seats.FindVehicle(&driver, ""))
+
 	// set the fare
 	trip.Fare = driverRequest.Fare
+	fare.Split(&trip)
 	// remove the driver request from the trip
 	for i, driverRequest1 := range trip.DriverRequests {
 		if *driverRequest1.UserUUID == driverUUID {
@@ -186,9 +210,13 @@ func RiderFlowRiderAcceptDriverRequest(req *http.Request, res http.ResponseWrite
 		}
 	}
 
-	_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": tripUUID}, bson.M{"$set": bson.M{"driver_requests": trip.DriverRequests, "assigned_driver": trip.AssignedDriver, "fare": trip.Fare}})
+	_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": tripUUID}, bson.M{"$set": bson.M{"driver_requests": trip.DriverRequests, "assigned_driver": trip.AssignedDriver, "fare": trip.Fare, "vehicle": trip.Vehicle, "seats": trip.Seats, "seats_remaining": trip.SeatsRemaining}})
 	if err != nil {
 		fmt.Println("Error updating trip: ", err)
+	} else {
+		notifyTrip(ctx, notify.EventOfferAccepted, driverUUID, &trip, &account)
+		stream.Publish(stream.EventDriverAccepted, &trip)
+		recordTripEvents(ctx, history.New(&trip, history.EventAccepted, *account.UserUUID, driverUUID))
 	}
 
 	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
@@ -245,6 +273,9 @@ func RiderFlowRiderRemoveDriver(req *http.Request, res http.ResponseWriter, ctx
 	_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": tripUUID}, bson.M{"$set": bson.M{"assigned_driver": trip.AssignedDriver, "fare": trip.Fare}})
 	if err != nil {
 		fmt.Println("Error updating trip: ", err)
+	} else {
+		stream.Publish(stream.EventDriverRemoved, &trip, driverUUID)
+		recordTripEvents(ctx, history.New(&trip, history.EventRemoved, *account.UserUUID, driverUUID))
 	}
 
 	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
@@ -301,6 +332,10 @@ func RiderFlowRiderRejectDriverRequest(req *http.Request, res http.ResponseWrite
 	_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": tripUUID}, bson.M{"$set": bson.M{"driver_requests": trip.DriverRequests}})
 	if err != nil {
 		fmt.Println("Error updating trip: ", err)
+	} else {
+		notifyTrip(ctx, notify.EventOfferRejected, driverUUID, &trip, &account)
+		stream.Publish(stream.EventDriverRejected, &trip, driverUUID)
+		recordTripEvents(ctx, history.New(&trip, history.EventRejected, *account.UserUUID, driverUUID))
 	}
 
 	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
@@ -386,6 +421,9 @@ func RiderFlowDriverRemoveFromTripFreeform(req *http.Request, res http.ResponseW
 			})
 		}
 
+		stream.Publish(stream.EventDriverRemoved, &trip, *account.UserUUID)
+		recordTripEvents(ctx, history.New(&trip, history.EventLeft, *account.UserUUID, *account.UserUUID))
+
 		return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
 			"success": true,
 		})
@@ -405,6 +443,10 @@ func RiderFlowDriverRemoveFromTripFreeform(req *http.Request, res http.ResponseW
 				{Key: "driver_requests", Value: trip.DriverRequests},
 			}},
 		})
+		if err == nil {
+			stream.Publish(stream.EventDriverRemoved, &trip, *account.UserUUID)
+			recordTripEvents(ctx, history.New(&trip, history.EventLeft, *account.UserUUID, *account.UserUUID))
+		}
 
 		return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
 			"success": true,
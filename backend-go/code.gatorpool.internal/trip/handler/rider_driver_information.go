@@ -105,6 +105,7 @@ type RiderProfile struct {
 	Phone string `json:"phone"`
 	Email string `json:"email"`
 	Gender string `json:"gender"`
+	Rating *float64 `json:"rating"`
 	TripUUID string `json:"trip_uuid"`
 	UserUUID string `json:"user_uuid"`
 }
@@ -166,6 +167,7 @@ func GetTripArrayRiderInformation(userUUID string, tripUUIDs []string) ([]*Rider
 			ProfilePicture: profilePictureURL,
 			Email: *riderAccount.Email,
 			Gender: *riderAccount.Gender,
+			Rating: riderProfile.Rating,
 			TripUUID: *trip.TripUUID,
 			UserUUID: userUUID,
 		})
@@ -5,10 +5,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"time"
 
 	accountEntities "code.gatorpool.internal/account/entities"
 	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/notify"
+	"code.gatorpool.internal/trip/conflict"
 	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/fare"
+	"code.gatorpool.internal/trip/stream"
 	"code.gatorpool.internal/util"
 	"code.gatorpool.internal/util/ptr"
 	"github.com/go-chi/chi"
@@ -58,22 +63,88 @@ func SaveTripSentBody(req *http.Request, res http.ResponseWriter, ctx context.Co
 		})
 	}
 
+	if body.Trip == nil || body.Trip.Fare == nil || body.Trip.Fare.Food == nil || body.Trip.Fare.Gas == nil || body.Trip.Fare.Trip == nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid body",
+		})
+	}
+
+	// What the riders agreed to, to compare the edit against
+	before := *trip
+
+	// Keep the split the trip already has unless the driver picked another
+	var split *string
+	if trip.Fare != nil {
+		split = trip.Fare.Split
+	}
+	if body.Trip.Fare.Split != nil {
+		if err := fare.ValidSplit(*body.Trip.Fare.Split); err != nil {
+			return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+		split = body.Trip.Fare.Split
+	}
+
 	trip.Fare = body.Trip.Fare
+	trip.Fare.Split = split
 
 	trip.Fare.Aggregated = ptr.Float64(*trip.Fare.Food + *trip.Fare.Gas + *trip.Fare.Trip)
+	fare.Split(trip)
+
+	settings, err := fare.LoadSettings(ctx)
+	if err != nil {
+		fmt.Println("Error loading fare settings: ", err)
+	}
+	fareCheck, err := fare.CheckCap(trip.Fare, fare.TripMiles(trip, settings), settings)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error":      err.Error(),
+			"fare_check": fareCheck,
+		})
+	}
 
 	trip.Miscellaneous = body.Trip.Miscellaneous
 	trip.Carpool = body.Trip.Carpool
 
-	_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": tripUUID}, bson.M{"$set": trip})
+	trip.UpdatedAt = ptr.Time(time.Now())
+
+	// Only what the driver edits is written, so riders joining, leaving or responding to a change
+	// meanwhile aren't overwritten
+	update := bson.M{"$set": bson.M{
+		"fare":          trip.Fare,
+		"miscellaneous": trip.Miscellaneous,
+		"carpool":       trip.Carpool,
+		"updated_at":    trip.UpdatedAt,
+	}}
+
+	// Accepted riders have to accept the changes or leave
+	if tripConflict := conflict.New(&before, trip, *account.UserUUID, time.Now()); tripConflict != nil {
+		trip.Conflicts = append(trip.Conflicts, tripConflict)
+		update["$push"] = bson.M{"conflicts": tripConflict}
+	}
+
+	_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": tripUUID}, update)
 	if err != nil {
 		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
 			"error": err.Error(),
 		})
 	}
 
+	// The shares were split between the riders as they were when the trip was loaded
+	if err := saveFareShares(ctx, tripsCollection, trip); err != nil {
+		fmt.Println("Error updating fare shares: ", err)
+	}
+
+	for _, riderUUID := range tripRiderUUIDs(trip) {
+		notifyTrip(ctx, notify.EventTripEdited, riderUUID, trip, &account)
+	}
+	stream.Publish(stream.EventTripEdited, trip)
+	recordTripEvents(ctx, editedEvent(&before, trip, *account.UserUUID))
+
 	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
 		"trip": trip,
+		"fare_check": fareCheck,
 		"success": true,
 	})
 }
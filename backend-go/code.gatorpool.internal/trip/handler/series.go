@@ -0,0 +1,595 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/notify"
+	"code.gatorpool.internal/rider/alerts"
+	"code.gatorpool.internal/trip/chat"
+	"code.gatorpool.internal/trip/conflict"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/fare"
+	"code.gatorpool.internal/trip/history"
+	"code.gatorpool.internal/trip/lifecycle"
+	"code.gatorpool.internal/trip/recurrence"
+	"code.gatorpool.internal/trip/seats"
+	"code.gatorpool.internal/trip/stream"
+	"code.gatorpool.internal/util"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/go-chi/chi"
+	"github.com/pborman/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type CreateTripRecurrenceBody struct {
+	// 0 is Sunday
+	Weekdays []int  `json:"weekdays"`
+	Until    string `json:"until"`
+	Timezone string `json:"timezone"`
+}
+
+// createTripSeries saves the trip as the template of a new series and creates its first occurrences.
+// The trip's fare has already been checked against the cap.
+func createTripSeries(ctx context.Context, res http.ResponseWriter, account accountEntities.AccountEntity, trip *tripEntities.TripEntity, body *CreateTripRecurrenceBody, fareCheck fare.Check) *http.Response {
+
+	until, err := time.Parse(time.RFC3339, body.Until)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid recurrence until",
+		})
+	}
+
+	rule := &tripEntities.TripRecurrenceEntity{
+		Weekdays: body.Weekdays,
+		Until:    ptr.Time(until),
+		Timezone: ptr.String(body.Timezone),
+	}
+	if body.Timezone == "" {
+		rule.Timezone = ptr.String(recurrence.DefaultTimezone)
+	}
+
+	err = recurrence.Validate(rule, *trip.Datetime)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	seriesUUID := uuid.NewRandom().String()
+
+	trip.TripUUID = nil
+	trip.SeriesUUID = ptr.String(seriesUUID)
+
+	series := &tripEntities.TripSeriesEntity{
+		SeriesUUID: ptr.String(seriesUUID),
+		DriverUUID: account.UserUUID,
+		Recurrence: rule,
+		Template:   trip,
+		Riders:     []*tripEntities.TripRiderEntity{},
+		Status:     ptr.String(recurrence.StatusActive),
+		CreatedAt:  ptr.Time(time.Now()),
+		UpdatedAt:  ptr.Time(time.Now()),
+	}
+
+	_, err = datastores.GetMongoDatabase(ctx).Collection(datastores.TripSeries).InsertOne(ctx, series)
+	if err != nil {
+		fmt.Println("Error inserting trip series: ", err)
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": "error inserting trip series",
+		})
+	}
+
+	// Always create at least the first trip, even if it's further out than the horizon
+	through := time.Now().Add(recurrence.Horizon)
+	if trip.Datetime.After(through) {
+		through = *trip.Datetime
+	}
+
+	trips, err := recurrence.Generate(ctx, series, through)
+	if err != nil || len(trips) == 0 {
+		fmt.Println("Error generating trip series: ", err)
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": "error generating trips",
+		})
+	}
+
+	go alerts.Notify(context.Background(), trips...)
+
+	tripUUIDs := []string{}
+	for _, trip := range trips {
+		tripUUIDs = append(tripUUIDs, *trip.TripUUID)
+	}
+
+	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
+		"trip_uuid":   tripUUIDs[0],
+		"trip_uuids":  tripUUIDs,
+		"series_uuid": seriesUUID,
+		"fare_check":  fareCheck,
+		"success":     true,
+	})
+}
+
+// GetTripSeries returns the series and its upcoming occurrences
+func GetTripSeries(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	series, errResponse := findTripSeries(ctx, chi.URLParam(req, "series_uuid"), res)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	// Only the driver sees who requested every occurrence
+	if *series.DriverUUID != *account.UserUUID {
+		series.Riders = nil
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "datetime", Value: 1}})
+	cursor, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Trips).Find(ctx, bson.M{
+		"series_uuid": series.SeriesUUID,
+		"datetime":    bson.M{"$gte": time.Now()},
+	}, opts)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	defer cursor.Close(ctx)
+
+	trips := []tripEntities.TripEntity{}
+	if err = cursor.All(ctx, &trips); err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
+		"series":  series,
+		"trips":   trips,
+		"success": true,
+	})
+}
+
+// SaveTripSeries edits the series template and every upcoming occurrence that hasn't started
+func SaveTripSeries(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	series, errResponse := findTripSeries(ctx, chi.URLParam(req, "series_uuid"), res)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	if *series.DriverUUID != *account.UserUUID {
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "unauthorized",
+		})
+	}
+
+	var body SaveTripSentBodyRequest
+	err := json.NewDecoder(req.Body).Decode(&body)
+	if err != nil || body.Trip == nil || body.Trip.Fare == nil || body.Trip.Fare.Food == nil || body.Trip.Fare.Gas == nil || body.Trip.Fare.Trip == nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "invalid body",
+		})
+	}
+
+	if body.Trip.Fare.Split != nil {
+		if err := fare.ValidSplit(*body.Trip.Fare.Split); err != nil {
+			return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	edited := *body.Trip.Fare
+	edited.Aggregated = ptr.Float64(*edited.Food + *edited.Gas + *edited.Trip)
+
+	// Every trip gets its own copy of the fare, since each has its own riders to split it between
+	edit := func(trip *tripEntities.TripEntity) {
+		tripFare := edited
+		if tripFare.Split == nil && trip.Fare != nil {
+			tripFare.Split = trip.Fare.Split
+		}
+		trip.Fare = &tripFare
+		fare.Split(trip)
+		trip.Miscellaneous = body.Trip.Miscellaneous
+		trip.Carpool = body.Trip.Carpool
+		trip.UpdatedAt = ptr.Time(time.Now())
+	}
+
+	edit(series.Template)
+
+	// Every occurrence follows the template's route, so checking the template covers them all
+	settings, err := fare.LoadSettings(ctx)
+	if err != nil {
+		fmt.Println("Error loading fare settings: ", err)
+	}
+	fareCheck, err := fare.CheckCap(series.Template.Fare, fare.TripMiles(series.Template, settings), settings)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error":      err.Error(),
+			"fare_check": fareCheck,
+		})
+	}
+
+	db := datastores.GetMongoDatabase(ctx)
+
+	_, err = db.Collection(datastores.TripSeries).UpdateOne(ctx, bson.M{"series_uuid": series.SeriesUUID}, bson.M{"$set": bson.M{
+		"template":   series.Template,
+		"updated_at": time.Now(),
+	}})
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	trips, err := upcomingOccurrences(ctx, *series.SeriesUUID)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	tripsCollection := db.Collection(datastores.Trips)
+	riderUUIDs := map[string]bool{}
+	for _, trip := range trips {
+		before := *trip
+		edit(trip)
+
+		if tripConflict := conflict.New(&before, trip, *account.UserUUID, time.Now()); tripConflict != nil {
+			trip.Conflicts = append(trip.Conflicts, tripConflict)
+		}
+
+		// Carpool changes how many seats the trip has
+		seats.Snapshot(trip, trip.Vehicle)
+
+		_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": trip.TripUUID}, bson.M{"$set": bson.M{
+			"fare":            trip.Fare,
+			"miscellaneous":   trip.Miscellaneous,
+			"carpool":         trip.Carpool,
+			"seats":           trip.Seats,
+			"seats_remaining": trip.SeatsRemaining,
+			"conflicts":       trip.Conflicts,
+			"updated_at":      trip.UpdatedAt,
+		}})
+		if err != nil {
+			fmt.Println("Error updating trip in series: ", err)
+			continue
+		}
+
+		stream.Publish(stream.EventTripEdited, trip)
+		recordTripEvents(ctx, editedEvent(&before, trip, *account.UserUUID))
+		for _, riderUUID := range tripRiderUUIDs(trip) {
+			riderUUIDs[riderUUID] = true
+		}
+	}
+
+	// Riders hear once about the series, not about every occurrence
+	for riderUUID := range riderUUIDs {
+		notifyTrip(ctx, notify.EventSeriesEdited, riderUUID, series.Template, &account)
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"series":     series,
+		"updated":    len(trips),
+		"fare_check": fareCheck,
+		"success":    true,
+	})
+}
+
+// CancelTripSeries stops the series and cancels every upcoming occurrence that hasn't started
+func CancelTripSeries(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	series, errResponse := findTripSeries(ctx, chi.URLParam(req, "series_uuid"), res)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	if *series.DriverUUID != *account.UserUUID {
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "unauthorized",
+		})
+	}
+
+	if *series.Status == recurrence.StatusCancelled {
+		return util.JSONResponse(res, http.StatusConflict, map[string]interface{}{
+			"error": "series has already been cancelled",
+		})
+	}
+
+	db := datastores.GetMongoDatabase(ctx)
+
+	_, err := db.Collection(datastores.TripSeries).UpdateOne(ctx, bson.M{"series_uuid": series.SeriesUUID}, bson.M{"$set": bson.M{
+		"status":     recurrence.StatusCancelled,
+		"updated_at": time.Now(),
+	}})
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	trips, err := upcomingOccurrences(ctx, *series.SeriesUUID)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	tripsCollection := db.Collection(datastores.Trips)
+	issueWarning := false
+	cancelled := []string{}
+	riderUUIDs := map[string]bool{}
+	for _, trip := range trips {
+		if lifecycle.Apply(trip, lifecycle.StatusCancelled, lifecycle.RoleDriver) != nil {
+			continue
+		}
+
+		_, err = tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": trip.TripUUID}, bson.M{"$set": bson.M{
+			"status":     trip.Status,
+			"updated_at": trip.UpdatedAt,
+		}})
+		if err != nil {
+			fmt.Println("Error cancelling trip in series: ", err)
+			continue
+		}
+
+		cancelled = append(cancelled, *trip.TripUUID)
+		if err := chat.Expire(ctx, *trip.TripUUID, *trip.UpdatedAt); err != nil {
+			fmt.Println("Error expiring trip messages: ", err)
+		}
+		stream.Publish(stream.EventTripCancelled, trip)
+		recordTripEvents(ctx, history.New(trip, history.EventCancelled, *account.UserUUID, ""))
+		for _, riderUUID := range tripRiderUUIDs(trip) {
+			riderUUIDs[riderUUID] = true
+		}
+		if lateCancellation(*trip.Datetime) {
+			issueWarning = true
+		}
+	}
+
+	// Riders hear once about the series, not about every occurrence
+	for _, rider := range series.Riders {
+		if rider != nil && rider.UserUUID != nil {
+			riderUUIDs[*rider.UserUUID] = true
+		}
+	}
+	for riderUUID := range riderUUIDs {
+		notifyTrip(ctx, notify.EventSeriesCancelled, riderUUID, series.Template, &account)
+	}
+
+	// One warning for the series, however many occurrences were close
+	if issueWarning {
+		dispatchCancellationWarning(*account.UserUUID, "Trip cancelled")
+	}
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"cancelled":     cancelled,
+		"issue_warning": issueWarning,
+		"success":       true,
+	})
+}
+
+// RequestTripSeries requests a seat on every upcoming occurrence of the series, and on every
+// occurrence created after this
+func RequestTripSeries(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	if errResponse := checkStanding(ctx, res, *account.UserUUID, false); errResponse != nil {
+		return errResponse
+	}
+
+	series, errResponse := findTripSeries(ctx, chi.URLParam(req, "series_uuid"), res)
+	if errResponse != nil {
+		return errResponse
+	}
+
+	if *series.Status != recurrence.StatusActive {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "series has been cancelled",
+		})
+	}
+
+	db := datastores.GetMongoDatabase(ctx)
+
+	// Only add the rider if they aren't on the series yet, so duplicate requests can't race each other
+	result, err := db.Collection(datastores.TripSeries).UpdateOne(ctx, bson.M{
+		"series_uuid":      series.SeriesUUID,
+		"riders.user_uuid": bson.M{"$ne": *account.UserUUID},
+	}, bson.M{"$push": bson.M{
+		"riders": newRiderRequest(series.Template, &account),
+	}})
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if result.ModifiedCount == 0 {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "you have already requested this series",
+		})
+	}
+
+	trips, err := upcomingOccurrences(ctx, *series.SeriesUUID)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	requested := []string{}
+	full := []string{}
+	for _, trip := range trips {
+		// Occurrences that are already full are skipped, the rider stays on the series for the rest
+		if seats.Remaining(trip) <= 0 {
+			full = append(full, *trip.TripUUID)
+			continue
+		}
+
+		rider := newRiderRequest(trip, &account)
+		result, err := db.Collection(datastores.Trips).UpdateOne(ctx, bson.M{
+			"trip_uuid":        trip.TripUUID,
+			"riders.user_uuid": bson.M{"$ne": *account.UserUUID},
+		}, bson.M{"$push": bson.M{
+			"riders": rider,
+		}})
+		if err != nil {
+			fmt.Println("Error requesting trip in series: ", err)
+			continue
+		}
+
+		if result.ModifiedCount > 0 {
+			trip.Riders = append(trip.Riders, rider)
+			requested = append(requested, *trip.TripUUID)
+			stream.Publish(stream.EventRiderRequested, trip)
+			recordTripEvents(ctx, history.New(trip, history.EventRequested, *account.UserUUID, *account.UserUUID))
+		}
+	}
+
+	// The driver hears once about the series, not about every occurrence
+	notifyTrip(ctx, notify.EventSeriesRequested, *series.DriverUUID, series.Template, &account)
+
+	return util.JSONGzipResponse(res, http.StatusOK, map[string]interface{}{
+		"requested": requested,
+		"full":      full,
+		"success":   true,
+	})
+}
+
+// RemoveTripSeriesRequest stops requesting every occurrence, and withdraws the requests that
+// haven't been accepted yet
+func RemoveTripSeriesRequest(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	seriesUUID := chi.URLParam(req, "series_uuid")
+
+	db := datastores.GetMongoDatabase(ctx)
+
+	result, err := db.Collection(datastores.TripSeries).UpdateOne(ctx, bson.M{"series_uuid": seriesUUID}, bson.M{"$pull": bson.M{
+		"riders": bson.M{"user_uuid": *account.UserUUID},
+	}})
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if result.ModifiedCount == 0 {
+		return util.JSONResponse(res, http.StatusBadRequest, map[string]interface{}{
+			"error": "you have not requested this series",
+		})
+	}
+
+	trips, err := upcomingOccurrences(ctx, seriesUUID)
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	_, err = db.Collection(datastores.Trips).UpdateMany(ctx, bson.M{
+		"series_uuid": seriesUUID,
+		"status":      lifecycle.StatusPending,
+		"datetime":    bson.M{"$gt": time.Now()},
+	}, bson.M{"$pull": bson.M{
+		"riders": bson.M{"user_uuid": *account.UserUUID, "accepted": false},
+	}})
+	if err != nil {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	// Only the requests that hadn't been accepted were withdrawn
+	events := []*tripEntities.TripEventEntity{}
+	for _, trip := range trips {
+		for _, rider := range trip.Riders {
+			if rider != nil && rider.UserUUID != nil && *rider.UserUUID == *account.UserUUID && (rider.Accepted == nil || !*rider.Accepted) {
+				events = append(events, history.New(trip, history.EventLeft, *account.UserUUID, *account.UserUUID))
+			}
+		}
+	}
+	recordTripEvents(ctx, events...)
+
+	return util.JSONResponse(res, http.StatusOK, map[string]interface{}{
+		"success": true,
+	})
+}
+
+func findTripSeries(ctx context.Context, seriesUUID string, res http.ResponseWriter) (*tripEntities.TripSeriesEntity, *http.Response) {
+	var series *tripEntities.TripSeriesEntity
+	err := datastores.GetMongoDatabase(ctx).Collection(datastores.TripSeries).FindOne(ctx, bson.M{"series_uuid": seriesUUID}).Decode(&series)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, util.JSONResponse(res, http.StatusNotFound, map[string]interface{}{
+				"error": "series not found",
+			})
+		}
+		return nil, util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+	return series, nil
+}
+
+// upcomingOccurrences returns the occurrences of the series that haven't left yet
+func upcomingOccurrences(ctx context.Context, seriesUUID string) ([]*tripEntities.TripEntity, error) {
+	cursor, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Trips).Find(ctx, bson.M{
+		"series_uuid": seriesUUID,
+		"status":      lifecycle.StatusPending,
+		"datetime":    bson.M{"$gt": time.Now()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	trips := []*tripEntities.TripEntity{}
+	err = cursor.All(ctx, &trips)
+	return trips, err
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"code.gatorpool.internal/fulfillment/warnings"
+	"code.gatorpool.internal/util"
+)
+
+// checkStanding stops banned users from posting or requesting trips, and users with too many
+// warnings from posting them. It returns nil when the user can go ahead
+func checkStanding(ctx context.Context, res http.ResponseWriter, userUUID string, posting bool) *http.Response {
+	standing, err := warnings.StandingOf(ctx, userUUID, time.Now())
+	if err != nil {
+		fmt.Println("Error checking standing: ", err)
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+
+	if standing.Ban != nil {
+		return util.JSONResponse(res, http.StatusForbidden, map[string]interface{}{
+			"error": warnings.BanError(standing.Ban).Error(),
+		})
+	}
+
+	if posting && !standing.CanCreateTrip {
+		return util.JSONResponse(res, http.StatusForbidden, map[string]interface{}{
+			"error": warnings.ErrTooManyWarnings.Error(),
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	accountEntities "code.gatorpool.internal/account/entities"
+	"code.gatorpool.internal/trip/stream"
+	"code.gatorpool.internal/util"
+)
+
+// How often a comment is sent on an idle stream, so proxies don't close it
+const streamHeartbeat = time.Second * 25
+
+// StreamTrips pushes changes to the trips the user is on as Server-Sent Events, until they
+// disconnect. ?trip_uuid= only streams one trip.
+func StreamTrips(req *http.Request, res http.ResponseWriter, ctx context.Context) *http.Response {
+
+	// Get the account object from context
+	account, ok := req.Context().Value("account").(accountEntities.AccountEntity) // No pointer
+	if !ok {
+		fmt.Println("Account object is missing in context")
+		return util.JSONResponse(res, http.StatusUnauthorized, map[string]interface{}{
+			"error": "no account in context",
+		})
+	}
+
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		return util.JSONResponse(res, http.StatusInternalServerError, map[string]interface{}{
+			"error": "streaming is not supported",
+		})
+	}
+
+	tripUUID := req.URL.Query().Get("trip_uuid")
+
+	events, stop := stream.Default.Subscribe(*account.UserUUID)
+	defer stop()
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(res, ": connected\n\n")
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-heartbeat.C:
+			fmt.Fprint(res, ": ping\n\n")
+			flusher.Flush()
+
+		case event := <-events:
+			if tripUUID != "" && event.TripUUID != tripUUID {
+				continue
+			}
+
+			data, err := json.Marshal(event)
+			if err != nil {
+				fmt.Println("Error encoding trip event: ", err)
+				continue
+			}
+
+			fmt.Fprintf(res, "event: %s\ndata: %s\n\n", event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
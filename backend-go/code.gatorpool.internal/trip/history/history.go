@@ -0,0 +1,131 @@
+package history
+
+import (
+	"context"
+	"time"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/pborman/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	EventCreated         = "created"
+	EventRequested       = "requested"
+	EventAccepted        = "accepted"
+	EventRejected        = "rejected"
+	EventRemoved         = "removed"
+	EventLeft            = "left"
+	EventEdited          = "edited"
+	EventChangesAccepted = "changes_accepted"
+	EventStarted         = "started"
+	EventCancelled       = "cancelled"
+	EventCompleted       = "completed"
+	EventExpired         = "expired"
+)
+
+// New builds an event on the trip by the actor. Subject is the rider or driver the event is about,
+// the actor themselves when they request or leave, and is left empty for events about the whole trip
+func New(trip *tripEntities.TripEntity, eventType string, actorUUID string, subjectUUID string) *tripEntities.TripEventEntity {
+	event := &tripEntities.TripEventEntity{
+		EventUUID: ptr.String(uuid.NewRandom().String()),
+		TripUUID:  trip.TripUUID,
+		Type:      ptr.String(eventType),
+		ActorUUID: ptr.String(actorUUID),
+		CreatedAt: ptr.Time(time.Now()),
+	}
+	if subjectUUID != "" {
+		event.SubjectUUID = ptr.String(subjectUUID)
+	}
+	return event
+}
+
+// Record appends events to their trips' history. There is deliberately no way to change or
+// remove an event once it's recorded
+func Record(ctx context.Context, events ...*tripEntities.TripEventEntity) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	documents := []interface{}{}
+	for _, event := range events {
+		documents = append(documents, event)
+	}
+
+	_, err := datastores.GetMongoDatabase(ctx).Collection(datastores.TripEvents).InsertMany(ctx, documents)
+	return err
+}
+
+// Timeline returns every event on the trip, oldest first
+func Timeline(ctx context.Context, tripUUID string) ([]*tripEntities.TripEventEntity, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	cursor, err := datastores.GetMongoDatabase(ctx).Collection(datastores.TripEvents).Find(ctx, bson.M{"trip_uuid": tripUUID}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []*tripEntities.TripEventEntity{}
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Visible filters the timeline down to what the user can see. Whoever posted or is driving the
+// trip sees everything. Anyone else sees events about the whole trip and events they were part
+// of, so riders and drivers who were removed can still see what happened to them. The bool is
+// false if the user has nothing to do with the trip.
+func Visible(trip *tripEntities.TripEntity, events []*tripEntities.TripEventEntity, userUUID string) ([]*tripEntities.TripEventEntity, bool) {
+	if owns(trip, userUUID) {
+		return events, true
+	}
+
+	involved := onTrip(trip, userUUID)
+	visible := []*tripEntities.TripEventEntity{}
+	for _, event := range events {
+		if is(event.ActorUUID, userUUID) || is(event.SubjectUUID, userUUID) {
+			involved = true
+			visible = append(visible, event)
+		} else if event.SubjectUUID == nil {
+			visible = append(visible, event)
+		}
+	}
+
+	if !involved {
+		return nil, false
+	}
+
+	return visible, true
+}
+
+// owns reports whether the user posted the trip or is its assigned driver
+func owns(trip *tripEntities.TripEntity, userUUID string) bool {
+	if is(trip.PostedBy, userUUID) {
+		return true
+	}
+	return trip.AssignedDriver != nil && is(trip.AssignedDriver.UserUUID, userUUID)
+}
+
+// onTrip reports whether the user is riding, or has asked to ride or drive, the trip
+func onTrip(trip *tripEntities.TripEntity, userUUID string) bool {
+	for _, rider := range trip.Riders {
+		if rider != nil && is(rider.UserUUID, userUUID) {
+			return true
+		}
+	}
+	for _, request := range trip.DriverRequests {
+		if request != nil && is(request.UserUUID, userUUID) {
+			return true
+		}
+	}
+	return false
+}
+
+func is(value *string, userUUID string) bool {
+	return value != nil && *value == userUUID
+}
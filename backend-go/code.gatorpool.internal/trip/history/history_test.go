@@ -0,0 +1,64 @@
+package history
+
+import (
+	"testing"
+
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	trip := &tripEntities.TripEntity{TripUUID: ptr.String("trip")}
+
+	event := New(trip, EventRemoved, "driver", "rider")
+	assert.Equal(t, "trip", *event.TripUUID)
+	assert.Equal(t, EventRemoved, *event.Type)
+	assert.Equal(t, "driver", *event.ActorUUID)
+	assert.Equal(t, "rider", *event.SubjectUUID)
+	assert.NotNil(t, event.EventUUID)
+	assert.NotNil(t, event.CreatedAt)
+
+	assert.Nil(t, New(trip, EventCancelled, "driver", "").SubjectUUID)
+}
+
+func TestVisible(t *testing.T) {
+	trip := &tripEntities.TripEntity{
+		TripUUID:       ptr.String("trip"),
+		PostedBy:       ptr.String("poster"),
+		AssignedDriver: &tripEntities.TripAssignedDriverEntity{UserUUID: ptr.String("driver")},
+		Riders: []*tripEntities.TripRiderEntity{
+			{UserUUID: ptr.String("rider"), Accepted: ptr.Bool(true)},
+			{UserUUID: ptr.String("other"), Accepted: ptr.Bool(true)},
+		},
+	}
+
+	created := New(trip, EventCreated, "poster", "")
+	requested := New(trip, EventRequested, "rider", "rider")
+	accepted := New(trip, EventAccepted, "driver", "rider")
+	acceptedOther := New(trip, EventAccepted, "driver", "other")
+	removed := New(trip, EventRemoved, "driver", "removed")
+	events := []*tripEntities.TripEventEntity{created, requested, accepted, acceptedOther, removed}
+
+	tests := []struct {
+		Name     string
+		UserUUID string
+		Expected []*tripEntities.TripEventEntity
+		Allowed  bool
+	}{
+		{"Poster sees everything", "poster", events, true},
+		{"Driver sees everything", "driver", events, true},
+		{"Rider sees the trip and their own events", "rider", []*tripEntities.TripEventEntity{created, requested, accepted}, true},
+		{"Other rider doesn't see who else requested", "other", []*tripEntities.TripEventEntity{created, acceptedOther}, true},
+		{"Removed rider can still see why", "removed", []*tripEntities.TripEventEntity{created, removed}, true},
+		{"Stranger sees nothing", "stranger", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			visible, allowed := Visible(trip, events, tt.UserUUID)
+			assert.Equal(t, tt.Allowed, allowed)
+			assert.Equal(t, tt.Expected, visible)
+		})
+	}
+}
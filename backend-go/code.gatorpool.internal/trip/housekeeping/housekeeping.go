@@ -0,0 +1,198 @@
+package housekeeping
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/ledger"
+	"code.gatorpool.internal/notify"
+	"code.gatorpool.internal/trip/chat"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/history"
+	"code.gatorpool.internal/trip/lifecycle"
+	"code.gatorpool.internal/trip/location"
+	"code.gatorpool.internal/trip/stream"
+	"code.gatorpool.internal/util/ptr"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// How long after a trip should have been over before it's tidied up, so drivers running late
+// can still finish it themselves
+const Grace = time.Hour * 2
+
+// DueAt is when the trip should be over: the expected arrival at the destination, or when it
+// leaves if that was never worked out
+func DueAt(trip *tripEntities.TripEntity) *time.Time {
+	for _, waypoint := range trip.Waypoints {
+		if waypoint == nil || waypoint.Type == nil || waypoint.Expected == nil {
+			continue
+		}
+		if *waypoint.Type == "destination" || *waypoint.Type == "dropoff" {
+			return waypoint.Expected
+		}
+	}
+	return trip.Datetime
+}
+
+// Overdue reports whether the trip should have been over for longer than Grace
+func Overdue(trip *tripEntities.TripEntity, now time.Time) bool {
+	due := DueAt(trip)
+	return due != nil && due.Add(Grace).Before(now)
+}
+
+// ExpireStale expires pending trips that never started and should have been over by now, and
+// returns how many it expired
+func ExpireStale(ctx context.Context, now time.Time) (int64, error) {
+	return tidy(ctx, now, lifecycle.StatusPending, lifecycle.StatusExpired)
+}
+
+// CompletePast completes trips the driver started but never finished, and returns how many it
+// completed
+func CompletePast(ctx context.Context, now time.Time) (int64, error) {
+	return tidy(ctx, now, lifecycle.StatusActive, lifecycle.StatusCompleted)
+}
+
+// tidy moves every overdue trip in one status to another on behalf of the system
+func tidy(ctx context.Context, now time.Time, from string, to string) (int64, error) {
+	tripsCollection := datastores.GetMongoDatabase(ctx).Collection(datastores.Trips)
+
+	// A trip can't be due before it leaves, so this finds every trip that might be overdue
+	cursor, err := tripsCollection.Find(ctx, bson.M{
+		"status":   from,
+		"datetime": bson.M{"$lt": now.Add(-Grace)},
+	}, options.Find().SetSort(bson.D{{Key: "datetime", Value: 1}}))
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var tidied int64
+	for cursor.Next(ctx) {
+		var trip *tripEntities.TripEntity
+		if err := cursor.Decode(&trip); err != nil {
+			return tidied, err
+		}
+
+		if !Overdue(trip, now) || lifecycle.Apply(trip, to, lifecycle.RoleSystem) != nil {
+			continue
+		}
+
+		update := bson.M{
+			"status":     trip.Status,
+			"updated_at": trip.UpdatedAt,
+		}
+		if to == lifecycle.StatusCompleted {
+			trip.CompletedAt = ptr.Time(now)
+			update["completed_at"] = trip.CompletedAt
+		}
+
+		// Only if nobody moved the trip on since it was read
+		result, err := tripsCollection.UpdateOne(ctx, bson.M{"trip_uuid": trip.TripUUID, "status": from}, bson.M{"$set": update})
+		if err != nil {
+			return tidied, err
+		}
+		if result.MatchedCount == 0 {
+			continue
+		}
+		tidied++
+
+		if to == lifecycle.StatusCompleted {
+			Completed(ctx, trip)
+			recordEvent(ctx, history.New(trip, history.EventCompleted, lifecycle.RoleSystem, ""))
+		} else {
+			Expired(ctx, trip, now)
+			recordEvent(ctx, history.New(trip, history.EventExpired, lifecycle.RoleSystem, ""))
+		}
+	}
+
+	return tidied, cursor.Err()
+}
+
+// Completed does the bookkeeping once a trip is over: it goes on everyone's past trips, riders are
+// charged, location sharing stops and the chat starts its retention window
+func Completed(ctx context.Context, trip *tripEntities.TripEntity) {
+	recordPastTrip(ctx, trip)
+	recordDebts(ctx, trip)
+
+	// The driver stops sharing their location once the trip is over
+	if err := location.Stop(ctx, *trip.TripUUID); err != nil {
+		fmt.Println("Error deleting trip locations: ", err)
+	}
+
+	// The chat is kept for a while after the trip in case anything needs to be sorted out
+	if err := chat.Expire(ctx, *trip.TripUUID, *trip.CompletedAt); err != nil {
+		fmt.Println("Error expiring trip messages: ", err)
+	}
+
+	stream.Publish(stream.EventTripCompleted, trip)
+}
+
+// Expired tidies up after a trip that never ran: location sharing stops and the chat starts its
+// retention window, the same as if it had been cancelled
+func Expired(ctx context.Context, trip *tripEntities.TripEntity, now time.Time) {
+	if err := location.Stop(ctx, *trip.TripUUID); err != nil {
+		fmt.Println("Error deleting trip locations: ", err)
+	}
+
+	if err := chat.Expire(ctx, *trip.TripUUID, now); err != nil {
+		fmt.Println("Error expiring trip messages: ", err)
+	}
+
+	stream.Publish(stream.EventTripExpired, trip)
+}
+
+// recordPastTrip adds the trip to the past trips of the driver and every accepted rider
+func recordPastTrip(ctx context.Context, trip *tripEntities.TripEntity) {
+
+	db := datastores.GetMongoDatabase(ctx)
+
+	if trip.AssignedDriver != nil && trip.AssignedDriver.UserUUID != nil {
+		_, err := db.Collection(datastores.Drivers).UpdateOne(ctx, bson.M{"driver_uuid": *trip.AssignedDriver.UserUUID}, bson.M{"$addToSet": bson.M{"past_trips": trip.TripUUID}})
+		if err != nil {
+			fmt.Println("Error updating driver past trips: ", err)
+		}
+	}
+
+	riderUUIDs := []string{}
+	for _, rider := range trip.Riders {
+		if rider.Accepted != nil && *rider.Accepted {
+			riderUUIDs = append(riderUUIDs, *rider.UserUUID)
+		}
+	}
+
+	if len(riderUUIDs) == 0 {
+		return
+	}
+
+	_, err := db.Collection(datastores.Riders).UpdateMany(ctx, bson.M{"rider_uuid": bson.M{"$in": riderUUIDs}}, bson.M{"$addToSet": bson.M{"past_trips": trip.TripUUID}})
+	if err != nil {
+		fmt.Println("Error updating rider past trips: ", err)
+	}
+}
+
+// recordDebts adds what each rider owes for the trip to the ledger, and lets them know
+func recordDebts(ctx context.Context, trip *tripEntities.TripEntity) {
+	debts, err := ledger.Record(ctx, trip)
+	if err != nil {
+		fmt.Println("Error recording trip debts: ", err)
+	}
+
+	for _, debt := range debts {
+		event := notify.TripEvent(notify.EventPaymentDue, *debt.RiderUUID, trip, map[string]string{
+			"AMOUNT": fmt.Sprintf("%.2f", *debt.Amount),
+		})
+		if err := notify.Enqueue(ctx, event); err != nil {
+			fmt.Println("Error queueing "+notify.EventPaymentDue+" notification: ", err)
+		}
+	}
+}
+
+// recordEvent adds to the trip's timeline, logging rather than failing since the trip has already moved on
+func recordEvent(ctx context.Context, event *tripEntities.TripEventEntity) {
+	if err := history.Record(ctx, event); err != nil {
+		fmt.Println("Error recording trip events: ", err)
+	}
+}
@@ -0,0 +1,71 @@
+package housekeeping
+
+import (
+	"testing"
+	"time"
+
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDueAt(t *testing.T) {
+	leaves := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	arrives := leaves.Add(time.Hour * 3)
+
+	tests := []struct {
+		Name     string
+		Trip     *tripEntities.TripEntity
+		Expected *time.Time
+	}{
+		{"Expected at the destination", &tripEntities.TripEntity{
+			Datetime: &leaves,
+			Waypoints: []*tripEntities.WaypointEntity{
+				{Type: ptr.String("pickup"), Expected: &leaves},
+				{Type: ptr.String("destination"), Expected: &arrives},
+			},
+		}, &arrives},
+		{"No expected arrival", &tripEntities.TripEntity{
+			Datetime: &leaves,
+			Waypoints: []*tripEntities.WaypointEntity{
+				{Type: ptr.String("destination")},
+			},
+		}, &leaves},
+		{"No waypoints", &tripEntities.TripEntity{Datetime: &leaves}, &leaves},
+		{"No times at all", &tripEntities.TripEntity{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, DueAt(tt.Trip))
+		})
+	}
+}
+
+func TestOverdue(t *testing.T) {
+	leaves := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+	trip := &tripEntities.TripEntity{
+		Datetime: &leaves,
+		Waypoints: []*tripEntities.WaypointEntity{
+			{Type: ptr.String("destination"), Expected: ptr.Time(leaves.Add(time.Hour))},
+		},
+	}
+
+	tests := []struct {
+		Name     string
+		Trip     *tripEntities.TripEntity
+		Now      time.Time
+		Expected bool
+	}{
+		{"Still on the way", trip, leaves.Add(time.Minute * 30), false},
+		{"Running late", trip, leaves.Add(time.Hour + Grace - time.Minute), false},
+		{"Past the grace period", trip, leaves.Add(time.Hour + Grace + time.Minute), true},
+		{"No times at all", &tripEntities.TripEntity{}, leaves.Add(time.Hour * 24), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, Overdue(tt.Trip, tt.Now))
+		})
+	}
+}
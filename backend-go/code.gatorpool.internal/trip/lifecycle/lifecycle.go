@@ -0,0 +1,137 @@
+package lifecycle
+
+import (
+	"errors"
+	"time"
+
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/util/ptr"
+)
+
+// Trip statuses stored in TripEntity.Status
+const (
+	StatusPending   = "pending"
+	StatusActive    = "active"
+	StatusCancelled = "cancelled"
+	StatusCompleted = "completed"
+
+	// Never started, and the time it would have been over has passed
+	StatusExpired = "expired"
+)
+
+// Roles that can move a trip from one status to another
+const (
+	RoleDriver = "driver"
+	RoleRider  = "rider"
+
+	// Background jobs tidying up trips nobody finished
+	RoleSystem = "system"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid trip status transition")
+	ErrRoleNotAllowed    = errors.New("you are not allowed to change the status of this trip")
+)
+
+type Transition struct {
+	From  string
+	To    string
+	Roles []string
+}
+
+/*
+	pending ---> active ---> completed
+	   |  |        |
+	   |  +--------+-------> cancelled
+	   |
+	   +-------------------> expired
+
+	Only the assigned driver can start or complete a trip. Riders can only
+	cancel trips they posted that no driver has started yet. The system
+	completes trips the driver forgot to finish, and expires pending trips
+	that never started.
+*/
+var transitions = []Transition{
+	{From: StatusPending, To: StatusActive, Roles: []string{RoleDriver}},
+	{From: StatusPending, To: StatusCancelled, Roles: []string{RoleDriver, RoleRider}},
+	{From: StatusPending, To: StatusExpired, Roles: []string{RoleSystem}},
+	{From: StatusActive, To: StatusCompleted, Roles: []string{RoleDriver, RoleSystem}},
+	{From: StatusActive, To: StatusCancelled, Roles: []string{RoleDriver}},
+}
+
+// Transitions returns the allowed transitions out of a status
+func Transitions(from string) []Transition {
+	var allowed []Transition
+	for _, transition := range transitions {
+		if transition.From == from {
+			allowed = append(allowed, transition)
+		}
+	}
+	return allowed
+}
+
+// CanTransition checks whether the role is allowed to move a trip from one status to another
+func CanTransition(from string, to string, role string) error {
+	for _, transition := range Transitions(from) {
+		if transition.To != to {
+			continue
+		}
+
+		for _, allowedRole := range transition.Roles {
+			if allowedRole == role {
+				return nil
+			}
+		}
+
+		return ErrRoleNotAllowed
+	}
+
+	return ErrInvalidTransition
+}
+
+// Apply moves the trip to the given status if the transition is allowed
+func Apply(trip *tripEntities.TripEntity, to string, role string) error {
+	from := StatusPending
+	if trip.Status != nil {
+		from = *trip.Status
+	}
+
+	if err := CanTransition(from, to, role); err != nil {
+		return err
+	}
+
+	trip.Status = ptr.String(to)
+	trip.UpdatedAt = ptr.Time(time.Now())
+
+	return nil
+}
+
+// RoleFor returns the role the user has on the trip, or an empty string if they are not on it
+func RoleFor(trip *tripEntities.TripEntity, userUUID string) string {
+	if trip.AssignedDriver != nil && trip.AssignedDriver.UserUUID != nil && *trip.AssignedDriver.UserUUID == userUUID {
+		return RoleDriver
+	}
+
+	for _, rider := range trip.Riders {
+		if rider.UserUUID != nil && *rider.UserUUID == userUUID {
+			return RoleRider
+		}
+	}
+
+	return ""
+}
+
+// AcceptedRider reports whether the user is a rider on the trip the driver has accepted
+func AcceptedRider(trip *tripEntities.TripEntity, userUUID string) bool {
+	for _, rider := range trip.Riders {
+		if rider.UserUUID != nil && *rider.UserUUID == userUUID {
+			return rider.Accepted != nil && *rider.Accepted
+		}
+	}
+	return false
+}
+
+// IsFinal returns true if the trip can no longer change status
+func IsFinal(status string) bool {
+	return len(Transitions(status)) == 0
+}
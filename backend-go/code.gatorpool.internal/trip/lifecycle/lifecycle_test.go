@@ -0,0 +1,98 @@
+package lifecycle
+
+import (
+	"testing"
+
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		Name     string
+		From     string
+		To       string
+		Role     string
+		Expected error
+	}{
+		{"Driver starts pending trip", StatusPending, StatusActive, RoleDriver, nil},
+		{"Driver completes active trip", StatusActive, StatusCompleted, RoleDriver, nil},
+		{"Rider cancels pending trip", StatusPending, StatusCancelled, RoleRider, nil},
+		{"Rider cannot start trip", StatusPending, StatusActive, RoleRider, ErrRoleNotAllowed},
+		{"Rider cannot cancel active trip", StatusActive, StatusCancelled, RoleRider, ErrRoleNotAllowed},
+		{"Pending trip cannot be completed", StatusPending, StatusCompleted, RoleDriver, ErrInvalidTransition},
+		{"Completed trip is final", StatusCompleted, StatusActive, RoleDriver, ErrInvalidTransition},
+		{"Cancelled trip is final", StatusCancelled, StatusPending, RoleDriver, ErrInvalidTransition},
+		{"System completes active trip", StatusActive, StatusCompleted, RoleSystem, nil},
+		{"System expires pending trip", StatusPending, StatusExpired, RoleSystem, nil},
+		{"Driver cannot expire trip", StatusPending, StatusExpired, RoleDriver, ErrRoleNotAllowed},
+		{"System cannot cancel trip", StatusPending, StatusCancelled, RoleSystem, ErrRoleNotAllowed},
+		{"Active trip cannot expire", StatusActive, StatusExpired, RoleSystem, ErrInvalidTransition},
+		{"Expired trip is final", StatusExpired, StatusActive, RoleDriver, ErrInvalidTransition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, CanTransition(tt.From, tt.To, tt.Role))
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	trip := &tripEntities.TripEntity{Status: ptr.String(StatusPending)}
+
+	err := Apply(trip, StatusActive, RoleDriver)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusActive, *trip.Status)
+	assert.NotNil(t, trip.UpdatedAt)
+
+	err = Apply(trip, StatusPending, RoleDriver)
+	assert.Equal(t, ErrInvalidTransition, err)
+	assert.Equal(t, StatusActive, *trip.Status)
+}
+
+func TestRoleFor(t *testing.T) {
+	trip := &tripEntities.TripEntity{
+		AssignedDriver: &tripEntities.TripAssignedDriverEntity{UserUUID: ptr.String("driver")},
+		Riders: []*tripEntities.TripRiderEntity{
+			{UserUUID: ptr.String("rider")},
+		},
+	}
+
+	assert.Equal(t, RoleDriver, RoleFor(trip, "driver"))
+	assert.Equal(t, RoleRider, RoleFor(trip, "rider"))
+	assert.Equal(t, "", RoleFor(trip, "someone-else"))
+	assert.True(t, IsFinal(StatusCompleted))
+	assert.False(t, IsFinal(StatusPending))
+}
+
+func TestAcceptedRider(t *testing.T) {
+	trip := &tripEntities.TripEntity{
+		AssignedDriver: &tripEntities.TripAssignedDriverEntity{UserUUID: ptr.String("driver")},
+		Riders: []*tripEntities.TripRiderEntity{
+			{UserUUID: ptr.String("accepted"), Accepted: ptr.Bool(true)},
+			{UserUUID: ptr.String("requested"), Accepted: ptr.Bool(false)},
+			{UserUUID: ptr.String("unanswered")},
+			{},
+		},
+	}
+
+	tests := []struct {
+		Name     string
+		UserUUID string
+		Expected bool
+	}{
+		{"Accepted rider", "accepted", true},
+		{"Request not accepted", "requested", false},
+		{"Request not answered", "unanswered", false},
+		{"Driver", "driver", false},
+		{"Not on the trip", "someone-else", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, AcceptedRider(trip, tt.UserUUID))
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package location
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	"code.gatorpool.internal/guardian/encryption"
+	"code.gatorpool.internal/guardian/secrets"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/geo"
+	"code.gatorpool.internal/util/ptr"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// A position older than this is shown as stale
+	StaleAfter = time.Minute * 2
+
+	// Readings from further back than this, or this far ahead of the server's clock, are refused
+	MaxAge   = time.Minute * 10
+	MaxAhead = time.Minute
+)
+
+var (
+	ErrInvalidCoordinates = errors.New("invalid coordinates")
+	ErrTooOld             = errors.New("location reading is too old")
+	ErrInFuture           = errors.New("location reading is in the future")
+)
+
+// Ping is a position reported by the driver's device
+type Ping struct {
+	Lat        float64
+	Lng        float64
+	Heading    *float64
+	Speed      *float64
+	Accuracy   *float64
+	RecordedAt time.Time
+}
+
+// Position is the driver's latest position, decrypted for a rider
+type Position struct {
+	Lat        float64   `json:"lat"`
+	Lng        float64   `json:"lng"`
+	Heading    *float64  `json:"heading,omitempty"`
+	Speed      *float64  `json:"speed,omitempty"`
+	Accuracy   *float64  `json:"accuracy,omitempty"`
+	RecordedAt time.Time `json:"recorded_at"`
+
+	// How long ago the reading was taken
+	StalenessSeconds int64 `json:"staleness_seconds"`
+	Stale            bool  `json:"stale"`
+}
+
+// Validate checks the ping is a real place and was taken recently
+func Validate(ping Ping, now time.Time) error {
+	if !geo.Valid(ping.Lat, ping.Lng) {
+		return ErrInvalidCoordinates
+	}
+	if ping.RecordedAt.After(now.Add(MaxAhead)) {
+		return ErrInFuture
+	}
+	if ping.RecordedAt.Before(now.Add(-MaxAge)) {
+		return ErrTooOld
+	}
+	return nil
+}
+
+// Staleness is how long ago the reading was taken, and whether that's too long to trust
+func Staleness(recordedAt time.Time, now time.Time) (time.Duration, bool) {
+	age := now.Sub(recordedAt)
+	if age < 0 {
+		age = 0
+	}
+	return age, age > StaleAfter
+}
+
+// Record encrypts the ping and saves it for the trip
+func Record(ctx context.Context, tripUUID string, driverUUID string, ping Ping) error {
+
+	version := secrets.SymmetricKeyValueLatestVersion
+
+	encrypted, err := encryption.LocationEncryption(encryption.LocationData{
+		Lat: strconv.FormatFloat(ping.Lat, 'f', -1, 64),
+		Lng: strconv.FormatFloat(ping.Lng, 'f', -1, 64),
+	}, &version, "encrypt")
+	if err != nil {
+		return err
+	}
+
+	_, err = datastores.GetMongoDatabase(ctx).Collection(datastores.TripLocations).InsertOne(ctx, &tripEntities.TripLocationEntity{
+		TripUUID:         &tripUUID,
+		DriverUUID:       &driverUUID,
+		Latitude:         &encrypted.Lat,
+		Longitude:        &encrypted.Lng,
+		EncryptedVersion: &version,
+		Heading:          ping.Heading,
+		Speed:            ping.Speed,
+		Accuracy:         ping.Accuracy,
+		RecordedAt:       ptr.Time(ping.RecordedAt),
+		ReceivedAt:       ptr.Time(time.Now()),
+	})
+	return err
+}
+
+// Latest returns the driver's most recent position on the trip, or nil if they haven't sent one
+func Latest(ctx context.Context, tripUUID string, now time.Time) (*Position, error) {
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "recorded_at", Value: -1}})
+
+	var ping tripEntities.TripLocationEntity
+	err := datastores.GetMongoDatabase(ctx).Collection(datastores.TripLocations).FindOne(ctx, bson.M{"trip_uuid": tripUUID}, opts).Decode(&ping)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if ping.Latitude == nil || ping.Longitude == nil || ping.EncryptedVersion == nil || ping.RecordedAt == nil {
+		return nil, nil
+	}
+
+	decrypted, err := encryption.LocationEncryption(encryption.LocationData{
+		Lat: *ping.Latitude,
+		Lng: *ping.Longitude,
+	}, ping.EncryptedVersion, "decrypt")
+	if err != nil {
+		return nil, err
+	}
+
+	lat, err := strconv.ParseFloat(decrypted.Lat, 64)
+	if err != nil {
+		return nil, err
+	}
+	lng, err := strconv.ParseFloat(decrypted.Lng, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	age, stale := Staleness(*ping.RecordedAt, now)
+
+	return &Position{
+		Lat:              lat,
+		Lng:              lng,
+		Heading:          ping.Heading,
+		Speed:            ping.Speed,
+		Accuracy:         ping.Accuracy,
+		RecordedAt:       *ping.RecordedAt,
+		StalenessSeconds: int64(age / time.Second),
+		Stale:            stale,
+	}, nil
+}
+
+// Stop deletes the trip's location pings, once it's over nobody can see where the driver went
+func Stop(ctx context.Context, tripUUID string) error {
+	_, err := datastores.GetMongoDatabase(ctx).Collection(datastores.TripLocations).DeleteMany(ctx, bson.M{"trip_uuid": tripUUID})
+	return err
+}
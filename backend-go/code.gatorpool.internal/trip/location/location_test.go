@@ -0,0 +1,48 @@
+package location
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		Name     string
+		Ping     Ping
+		Expected error
+	}{
+		{"Just taken", Ping{Lat: 29.6516, Lng: -82.3248, RecordedAt: now}, nil},
+		{"A few minutes ago", Ping{Lat: 29.6516, Lng: -82.3248, RecordedAt: now.Add(-time.Minute * 5)}, nil},
+		{"Slightly ahead of the server", Ping{Lat: 29.6516, Lng: -82.3248, RecordedAt: now.Add(time.Second * 30)}, nil},
+		{"Off the map", Ping{Lat: 129.6516, Lng: -82.3248, RecordedAt: now}, ErrInvalidCoordinates},
+		{"Too old", Ping{Lat: 29.6516, Lng: -82.3248, RecordedAt: now.Add(-time.Hour)}, ErrTooOld},
+		{"In the future", Ping{Lat: 29.6516, Lng: -82.3248, RecordedAt: now.Add(time.Hour)}, ErrInFuture},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, Validate(tt.Ping, now))
+		})
+	}
+}
+
+func TestStaleness(t *testing.T) {
+	now := time.Now()
+
+	age, stale := Staleness(now.Add(-time.Second*30), now)
+	assert.Equal(t, time.Second*30, age)
+	assert.False(t, stale)
+
+	age, stale = Staleness(now.Add(-time.Minute*5), now)
+	assert.Equal(t, time.Minute*5, age)
+	assert.True(t, stale)
+
+	// Clocks that are a little ahead don't give a negative age
+	age, stale = Staleness(now.Add(time.Second*10), now)
+	assert.Equal(t, time.Duration(0), age)
+	assert.False(t, stale)
+}
@@ -0,0 +1,51 @@
+package rating
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/lifecycle"
+)
+
+const (
+	// Ratings can only be left for this long after a trip is completed
+	Window = time.Hour * 24 * 14
+
+	MaxReviewLength = 1000
+)
+
+var (
+	ErrInvalidRating = errors.New("rating must be between 1 and 5")
+	ErrReviewTooLong = errors.New("review must be 1000 characters or less")
+	ErrNotCompleted  = errors.New("trip has not been completed")
+	ErrWindowClosed  = errors.New("the rating window for this trip has closed")
+)
+
+// Validate checks the rating is between 1 and 5 and returns the review trimmed
+func Validate(rating float64, review string) (string, error) {
+	if rating < 1 || rating > 5 {
+		return "", ErrInvalidRating
+	}
+
+	review = strings.TrimSpace(review)
+	if len(review) > MaxReviewLength {
+		return "", ErrReviewTooLong
+	}
+
+	return review, nil
+}
+
+// Rateable checks the trip was completed, with a driver, within the rating window
+func Rateable(trip *tripEntities.TripEntity, now time.Time) error {
+	if trip.Status == nil || *trip.Status != lifecycle.StatusCompleted || trip.AssignedDriver == nil {
+		return ErrNotCompleted
+	}
+
+	if trip.CompletedAt != nil && now.Sub(*trip.CompletedAt) > Window {
+		return ErrWindowClosed
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package rating
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/lifecycle"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Rating   float64
+		Review   string
+		Expected string
+		Err      error
+	}{
+		{"Lowest rating", 1, "", "", nil},
+		{"Highest rating", 5, "Great driver", "Great driver", nil},
+		{"Half stars", 4.5, "", "", nil},
+		{"Review is trimmed", 4, "  On time \n", "On time", nil},
+		{"Missing rating", 0, "", "", ErrInvalidRating},
+		{"Above five", 5.5, "", "", ErrInvalidRating},
+		{"Negative", -1, "", "", ErrInvalidRating},
+		{"Longest review", 3, strings.Repeat("a", MaxReviewLength), strings.Repeat("a", MaxReviewLength), nil},
+		{"Review too long", 3, strings.Repeat("a", MaxReviewLength+1), "", ErrReviewTooLong},
+		{"Spaces don't count towards the length", 3, " " + strings.Repeat("a", MaxReviewLength) + " ", strings.Repeat("a", MaxReviewLength), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			review, err := Validate(tt.Rating, tt.Review)
+			assert.Equal(t, tt.Err, err)
+			assert.Equal(t, tt.Expected, review)
+		})
+	}
+}
+
+func TestRateable(t *testing.T) {
+	now := time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC)
+	driver := &tripEntities.TripAssignedDriverEntity{UserUUID: ptr.String("driver")}
+
+	tests := []struct {
+		Name     string
+		Trip     *tripEntities.TripEntity
+		Expected error
+	}{
+		{"Just completed", &tripEntities.TripEntity{Status: ptr.String(lifecycle.StatusCompleted), AssignedDriver: driver, CompletedAt: ptr.Time(now)}, nil},
+		{"Last moment of the window", &tripEntities.TripEntity{Status: ptr.String(lifecycle.StatusCompleted), AssignedDriver: driver, CompletedAt: ptr.Time(now.Add(-Window))}, nil},
+		{"Window closed", &tripEntities.TripEntity{Status: ptr.String(lifecycle.StatusCompleted), AssignedDriver: driver, CompletedAt: ptr.Time(now.Add(-Window - time.Second))}, ErrWindowClosed},
+		{"Completed before completion times were kept", &tripEntities.TripEntity{Status: ptr.String(lifecycle.StatusCompleted), AssignedDriver: driver}, nil},
+		{"Still active", &tripEntities.TripEntity{Status: ptr.String(lifecycle.StatusActive), AssignedDriver: driver}, ErrNotCompleted},
+		{"Cancelled", &tripEntities.TripEntity{Status: ptr.String(lifecycle.StatusCancelled), AssignedDriver: driver}, ErrNotCompleted},
+		{"No status", &tripEntities.TripEntity{AssignedDriver: driver}, ErrNotCompleted},
+		{"No driver", &tripEntities.TripEntity{Status: ptr.String(lifecycle.StatusCompleted), CompletedAt: ptr.Time(now)}, ErrNotCompleted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, Rateable(tt.Trip, now))
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package recurrence
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/history"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Generate creates the series' occurrences up to through as trips and returns them
+func Generate(ctx context.Context, series *tripEntities.TripSeriesEntity, through time.Time) ([]*tripEntities.TripEntity, error) {
+
+	if series.Status == nil || *series.Status != StatusActive {
+		return nil, nil
+	}
+
+	// Mongo keeps milliseconds, and generated_until has to match exactly when it's claimed
+	through = through.Truncate(time.Millisecond)
+
+	start := *series.Template.Datetime
+
+	// Nothing has been generated yet, so the first trip is included
+	after := start.Add(-time.Nanosecond)
+	if series.GeneratedUntil != nil {
+		after = *series.GeneratedUntil
+	}
+
+	if !through.After(after) {
+		return nil, nil
+	}
+
+	occurrences, err := Occurrences(series.Recurrence, start, after, through)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build every occurrence before claiming the window, so one that can't be built leaves the window
+	// for the next run
+	trips := []*tripEntities.TripEntity{}
+	documents := []interface{}{}
+	for _, at := range occurrences {
+		trip, err := Materialize(series, at)
+		if err != nil {
+			return nil, err
+		}
+		trips = append(trips, trip)
+		documents = append(documents, trip)
+	}
+
+	db := datastores.GetMongoDatabase(ctx)
+
+	// Claim the window first so two servers don't both create the occurrences
+	claimedFrom := series.GeneratedUntil
+	result, err := db.Collection(datastores.TripSeries).UpdateOne(ctx, bson.M{
+		"series_uuid":     series.SeriesUUID,
+		"generated_until": claimedFrom,
+	}, bson.M{"$set": bson.M{
+		"generated_until": through,
+		"updated_at":      time.Now(),
+	}})
+	if err != nil {
+		return nil, err
+	}
+
+	if result.ModifiedCount == 0 {
+		return nil, nil
+	}
+
+	series.GeneratedUntil = &through
+
+	if len(documents) == 0 {
+		return trips, nil
+	}
+
+	// Occurrences have the same UUID every time they're built, so ones an earlier attempt already
+	// created are turned away by the unique index and skipped
+	_, err = db.Collection(datastores.Trips).InsertMany(ctx, documents, options.InsertMany().SetOrdered(false))
+	if err != nil {
+		existing, onlyDuplicates := duplicates(err)
+		if !onlyDuplicates {
+			// Give the window back, so the next run tries these occurrences again rather than losing them
+			_, rollbackErr := db.Collection(datastores.TripSeries).UpdateOne(ctx, bson.M{
+				"series_uuid":     series.SeriesUUID,
+				"generated_until": through,
+			}, bson.M{"$set": bson.M{"generated_until": claimedFrom}})
+			if rollbackErr != nil {
+				fmt.Println("Error giving back trip series window: ", rollbackErr)
+			}
+			series.GeneratedUntil = claimedFrom
+			return nil, err
+		}
+
+		created := []*tripEntities.TripEntity{}
+		for i, trip := range trips {
+			if !existing[i] {
+				created = append(created, trip)
+			}
+		}
+		trips = created
+	}
+
+	// The driver set up the series, so each occurrence is created by them
+	events := []*tripEntities.TripEventEntity{}
+	for _, trip := range trips {
+		events = append(events, history.New(trip, history.EventCreated, *series.DriverUUID, ""))
+	}
+	if err := history.Record(ctx, events...); err != nil {
+		fmt.Println("Error recording trip series events: ", err)
+	}
+
+	return trips, nil
+}
+
+// duplicates returns which documents of an unordered insert already existed, and whether that's the
+// only reason the insert failed
+func duplicates(err error) (map[int]bool, bool) {
+	var bulk mongo.BulkWriteException
+	if !errors.As(err, &bulk) || bulk.WriteConcernError != nil || len(bulk.WriteErrors) == 0 {
+		return nil, false
+	}
+
+	existing := map[int]bool{}
+	for _, writeErr := range bulk.WriteErrors {
+		if !mongo.IsDuplicateKeyError(writeErr) {
+			return nil, false
+		}
+		existing[writeErr.Index] = true
+	}
+	return existing, true
+}
+
+// GenerateAll tops up every active series to the horizon, and returns the trips it created
+func GenerateAll(ctx context.Context, now time.Time) ([]*tripEntities.TripEntity, error) {
+
+	through := now.Add(Horizon)
+
+	cursor, err := datastores.GetMongoDatabase(ctx).Collection(datastores.TripSeries).Find(ctx, bson.M{
+		"status": StatusActive,
+		"$or": []bson.M{
+			{"generated_until": nil},
+			{"generated_until": bson.M{"$lt": through}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	created := []*tripEntities.TripEntity{}
+
+	for cursor.Next(ctx) {
+		var series tripEntities.TripSeriesEntity
+		if err := cursor.Decode(&series); err != nil {
+			return created, err
+		}
+
+		// Series that have run their course don't need to be looked at again
+		if series.GeneratedUntil != nil && Finished(series.Recurrence, *series.GeneratedUntil) {
+			continue
+		}
+
+		trips, err := Generate(ctx, &series, through)
+		if err != nil {
+			fmt.Println("Error generating trip series "+*series.SeriesUUID+": ", err)
+		}
+		created = append(created, trips...)
+	}
+
+	return created, cursor.Err()
+}
@@ -0,0 +1,215 @@
+package recurrence
+
+import (
+	"errors"
+	"time"
+
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/trip/lifecycle"
+	"code.gatorpool.internal/trip/seats"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/pborman/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	StatusActive    = "active"
+	StatusCancelled = "cancelled"
+
+	// How far ahead occurrences are created as trips
+	Horizon = time.Hour * 24 * 7 * 4
+
+	// Longest a series can run for
+	MaxLength = time.Hour * 24 * 366
+
+	// Timezone used when the driver doesn't send one
+	DefaultTimezone = "America/New_York"
+)
+
+var (
+	ErrNoWeekdays        = errors.New("recurrence needs at least one weekday")
+	ErrInvalidWeekday    = errors.New("weekdays must be between 0 (sunday) and 6 (saturday)")
+	ErrInvalidTimezone   = errors.New("invalid timezone")
+	ErrUntilBeforeStart  = errors.New("recurrence must end after the first trip")
+	ErrTooLong           = errors.New("recurrence can be at most a year long")
+	ErrStartNotOnWeekday = errors.New("the first trip must fall on one of the recurrence weekdays")
+)
+
+// Location returns the timezone the rule's weekdays are in
+func Location(rule *tripEntities.TripRecurrenceEntity) (*time.Location, error) {
+	name := DefaultTimezone
+	if rule.Timezone != nil && *rule.Timezone != "" {
+		name = *rule.Timezone
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, ErrInvalidTimezone
+	}
+	return loc, nil
+}
+
+// Validate checks the rule makes sense for a series starting at start
+func Validate(rule *tripEntities.TripRecurrenceEntity, start time.Time) error {
+	if len(rule.Weekdays) == 0 {
+		return ErrNoWeekdays
+	}
+
+	for _, weekday := range rule.Weekdays {
+		if weekday < 0 || weekday > 6 {
+			return ErrInvalidWeekday
+		}
+	}
+
+	loc, err := Location(rule)
+	if err != nil {
+		return err
+	}
+
+	if rule.Until == nil || !cutoff(rule, loc).After(start) {
+		return ErrUntilBeforeStart
+	}
+
+	if rule.Until.Sub(start) > MaxLength {
+		return ErrTooLong
+	}
+
+	if !onWeekday(rule, start.In(loc).Weekday()) {
+		return ErrStartNotOnWeekday
+	}
+
+	return nil
+}
+
+// Occurrences returns the times the series runs after after and up to through. Every occurrence
+// leaves at the same local time of day as start, so daylight saving doesn't move the trip.
+func Occurrences(rule *tripEntities.TripRecurrenceEntity, start time.Time, after time.Time, through time.Time) ([]time.Time, error) {
+	loc, err := Location(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	local := start.In(loc)
+	end := cutoff(rule, loc)
+
+	from := local
+	if after.After(from) {
+		from = after.In(loc)
+	}
+
+	occurrences := []time.Time{}
+	for day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc); !day.After(through) && day.Before(end); day = day.AddDate(0, 0, 1) {
+		if !onWeekday(rule, day.Weekday()) {
+			continue
+		}
+
+		at := time.Date(day.Year(), day.Month(), day.Day(), local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), loc)
+		if at.Before(start) || !at.After(after) || at.After(through) || !at.Before(end) {
+			continue
+		}
+
+		occurrences = append(occurrences, at)
+	}
+
+	return occurrences, nil
+}
+
+// OccurrenceUUID is the trip UUID of the series' occurrence at the time. It comes out the same every
+// time, so an occurrence can't be created twice.
+func OccurrenceUUID(seriesUUID string, at time.Time) string {
+	name := "trip_series:" + seriesUUID + ":" + at.UTC().Truncate(time.Millisecond).Format(time.RFC3339Nano)
+	return uuid.NewSHA1(uuid.NameSpace_OID, []byte(name)).String()
+}
+
+// Materialize returns a new trip for the occurrence at, copied from the series template with its
+// times moved over and the series riders added as requests
+func Materialize(series *tripEntities.TripSeriesEntity, at time.Time) (*tripEntities.TripEntity, error) {
+	var trip tripEntities.TripEntity
+	if err := clone(series.Template, &trip); err != nil {
+		return nil, err
+	}
+
+	offset := at.Sub(*series.Template.Datetime)
+	now := time.Now()
+
+	shift := func(waypoint *tripEntities.WaypointEntity) {
+		if waypoint == nil {
+			return
+		}
+		if waypoint.Expected != nil {
+			waypoint.Expected = ptr.Time(waypoint.Expected.Add(offset))
+		}
+		waypoint.Actual = nil
+	}
+
+	trip.TripUUID = ptr.String(OccurrenceUUID(*series.SeriesUUID, at))
+	trip.SeriesUUID = series.SeriesUUID
+	trip.Datetime = ptr.Time(at)
+	trip.Status = ptr.String(lifecycle.StatusPending)
+	trip.StartedAt = nil
+	trip.CompletedAt = nil
+	trip.CreatedAt = ptr.Time(now)
+	trip.UpdatedAt = ptr.Time(now)
+
+	for _, waypoint := range trip.Waypoints {
+		shift(waypoint)
+	}
+	shift(trip.CurrentLocation)
+
+	if trip.AssignedDriver != nil {
+		shift(trip.AssignedDriver.Address)
+		trip.AssignedDriver.AssignedAt = ptr.Time(now)
+	}
+
+	trip.Riders = []*tripEntities.TripRiderEntity{}
+	for _, request := range series.Riders {
+		var rider tripEntities.TripRiderEntity
+		if err := clone(request, &rider); err != nil {
+			return nil, err
+		}
+
+		shift(rider.Address)
+		rider.Accepted = ptr.Bool(false)
+		rider.AcceptedAt = nil
+		rider.CreatedAt = ptr.Time(now)
+
+		trip.Riders = append(trip.Riders, &rider)
+	}
+
+	seats.Recount(&trip)
+
+	return &trip, nil
+}
+
+// Finished reports whether the rule has no occurrences left after the given time
+func Finished(rule *tripEntities.TripRecurrenceEntity, after time.Time) bool {
+	loc, err := Location(rule)
+	if err != nil || rule.Until == nil {
+		return true
+	}
+	return !after.Before(cutoff(rule, loc))
+}
+
+// cutoff is the start of the day after the rule's last day
+func cutoff(rule *tripEntities.TripRecurrenceEntity, loc *time.Location) time.Time {
+	until := rule.Until.In(loc)
+	return time.Date(until.Year(), until.Month(), until.Day()+1, 0, 0, 0, 0, loc)
+}
+
+func onWeekday(rule *tripEntities.TripRecurrenceEntity, weekday time.Weekday) bool {
+	for _, w := range rule.Weekdays {
+		if time.Weekday(w) == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// clone deep copies a document through bson so occurrences never share pointers with the template
+func clone(from interface{}, to interface{}) error {
+	raw, err := bson.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(raw, to)
+}
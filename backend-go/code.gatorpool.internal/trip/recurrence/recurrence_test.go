@@ -0,0 +1,121 @@
+package recurrence
+
+import (
+	"testing"
+	"time"
+
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+)
+
+func eastern(t *testing.T, value string) time.Time {
+	loc, err := time.LoadLocation(DefaultTimezone)
+	assert.NoError(t, err)
+	at, err := time.ParseInLocation("2006-01-02 15:04", value, loc)
+	assert.NoError(t, err)
+	return at
+}
+
+func TestValidate(t *testing.T) {
+	// Friday
+	start := eastern(t, "2025-01-03 17:00")
+
+	tests := []struct {
+		Name     string
+		Rule     *tripEntities.TripRecurrenceEntity
+		Expected error
+	}{
+		{"Every Friday", &tripEntities.TripRecurrenceEntity{Weekdays: []int{5}, Until: ptr.Time(start.AddDate(0, 2, 0))}, nil},
+		{"No weekdays", &tripEntities.TripRecurrenceEntity{Until: ptr.Time(start.AddDate(0, 2, 0))}, ErrNoWeekdays},
+		{"Weekday out of range", &tripEntities.TripRecurrenceEntity{Weekdays: []int{7}, Until: ptr.Time(start.AddDate(0, 2, 0))}, ErrInvalidWeekday},
+		{"Bad timezone", &tripEntities.TripRecurrenceEntity{Weekdays: []int{5}, Until: ptr.Time(start.AddDate(0, 2, 0)), Timezone: ptr.String("Mars/Olympus")}, ErrInvalidTimezone},
+		{"Ends before it starts", &tripEntities.TripRecurrenceEntity{Weekdays: []int{5}, Until: ptr.Time(start.AddDate(0, 0, -1))}, ErrUntilBeforeStart},
+		{"Longer than a year", &tripEntities.TripRecurrenceEntity{Weekdays: []int{5}, Until: ptr.Time(start.AddDate(2, 0, 0))}, ErrTooLong},
+		{"First trip isn't on a weekday", &tripEntities.TripRecurrenceEntity{Weekdays: []int{1}, Until: ptr.Time(start.AddDate(0, 2, 0))}, ErrStartNotOnWeekday},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, Validate(tt.Rule, start))
+		})
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	// Friday and Sunday, every week through the end of March
+	start := eastern(t, "2025-03-02 17:00")
+	rule := &tripEntities.TripRecurrenceEntity{
+		Weekdays: []int{0, 5},
+		Until:    ptr.Time(eastern(t, "2025-03-16 00:00")),
+		Timezone: ptr.String(DefaultTimezone),
+	}
+
+	occurrences, err := Occurrences(rule, start, start.Add(-time.Nanosecond), start.AddDate(1, 0, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		eastern(t, "2025-03-02 17:00"),
+		eastern(t, "2025-03-07 17:00"),
+		// Daylight saving starts, the trip still leaves at 5pm
+		eastern(t, "2025-03-09 17:00"),
+		eastern(t, "2025-03-14 17:00"),
+		// The last day is included
+		eastern(t, "2025-03-16 17:00"),
+	}, occurrences)
+
+	// Picking up where the last run stopped doesn't repeat occurrences
+	occurrences, err = Occurrences(rule, start, eastern(t, "2025-03-07 17:00"), eastern(t, "2025-03-14 12:00"))
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{eastern(t, "2025-03-09 17:00")}, occurrences)
+
+	assert.False(t, Finished(rule, eastern(t, "2025-03-16 12:00")))
+	assert.True(t, Finished(rule, eastern(t, "2025-03-17 00:00")))
+}
+
+func TestMaterialize(t *testing.T) {
+	start := eastern(t, "2025-01-03 17:00")
+	pickup := &tripEntities.WaypointEntity{Type: ptr.String("pickup"), Expected: ptr.Time(start)}
+
+	series := &tripEntities.TripSeriesEntity{
+		SeriesUUID: ptr.String("series"),
+		Template: &tripEntities.TripEntity{
+			Datetime:        ptr.Time(start),
+			Carpool:         ptr.Bool(true),
+			Seats:           ptr.Int(3),
+			Waypoints:       []*tripEntities.WaypointEntity{pickup},
+			CurrentLocation: pickup,
+		},
+		Riders: []*tripEntities.TripRiderEntity{
+			{UserUUID: ptr.String("rider"), Address: &tripEntities.WaypointEntity{Expected: ptr.Time(start)}, Accepted: ptr.Bool(true)},
+		},
+	}
+
+	next := start.AddDate(0, 0, 7)
+	trip, err := Materialize(series, next)
+	assert.NoError(t, err)
+
+	assert.NotNil(t, trip.TripUUID)
+
+	// Building the same occurrence again gives the same trip, and a different one doesn't
+	again, err := Materialize(series, next)
+	assert.NoError(t, err)
+	assert.Equal(t, *trip.TripUUID, *again.TripUUID)
+
+	other, err := Materialize(series, next.AddDate(0, 0, 7))
+	assert.NoError(t, err)
+	assert.NotEqual(t, *trip.TripUUID, *other.TripUUID)
+	assert.Equal(t, "series", *trip.SeriesUUID)
+	assert.Equal(t, "pending", *trip.Status)
+	assert.True(t, next.Equal(*trip.Datetime))
+	assert.True(t, next.Equal(*trip.Waypoints[0].Expected))
+	assert.True(t, next.Equal(*trip.CurrentLocation.Expected))
+
+	// Series riders are requests on every occurrence, not accepted riders
+	assert.Len(t, trip.Riders, 1)
+	assert.False(t, *trip.Riders[0].Accepted)
+	assert.True(t, next.Equal(*trip.Riders[0].Address.Expected))
+	assert.Equal(t, 3, *trip.SeatsRemaining)
+
+	// The template is left alone
+	assert.True(t, start.Equal(*series.Template.Waypoints[0].Expected))
+}
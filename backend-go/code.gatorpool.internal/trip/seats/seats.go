@@ -0,0 +1,73 @@
+package seats
+
+import (
+	driverEntities "code.gatorpool.internal/driver/entities"
+	tripEntities "code.gatorpool.internal/trip/entities"
+)
+
+// Capacity returns how many riders a vehicle can take on a trip. The driver
+// takes one seat, and a trip that isn't a carpool only ever takes one rider.
+func Capacity(vehicle *driverEntities.VehicleEntity, carpool bool) int {
+	if !carpool {
+		return 1
+	}
+
+	if vehicle == nil || vehicle.Seats == nil || *vehicle.Seats < 2 {
+		return 1
+	}
+
+	return *vehicle.Seats - 1
+}
+
+// TripCapacity returns the capacity stored on the trip, or works it out from the vehicle snapshot
+func TripCapacity(trip *tripEntities.TripEntity) int {
+	if trip.Seats != nil {
+		return *trip.Seats
+	}
+
+	return Capacity(trip.Vehicle, trip.Carpool != nil && *trip.Carpool)
+}
+
+// Accepted returns the number of riders that have been accepted on the trip
+func Accepted(trip *tripEntities.TripEntity) int {
+	accepted := 0
+	for _, rider := range trip.Riders {
+		if rider.Accepted != nil && *rider.Accepted {
+			accepted++
+		}
+	}
+	return accepted
+}
+
+// Remaining returns how many more riders can be accepted on the trip
+func Remaining(trip *tripEntities.TripEntity) int {
+	remaining := TripCapacity(trip) - Accepted(trip)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Snapshot sets the vehicle and seat counts on the trip
+func Snapshot(trip *tripEntities.TripEntity, vehicle *driverEntities.VehicleEntity) {
+	trip.Vehicle = vehicle
+	capacity := Capacity(vehicle, trip.Carpool != nil && *trip.Carpool)
+	trip.Seats = &capacity
+	Recount(trip)
+}
+
+// Recount updates the remaining seats on the trip after riders join or leave
+func Recount(trip *tripEntities.TripEntity) {
+	remaining := Remaining(trip)
+	trip.SeatsRemaining = &remaining
+}
+
+// FindVehicle returns the driver's vehicle with the given uuid, or their first vehicle if none is given
+func FindVehicle(driver *driverEntities.DriverEntity, vehicleUUID string) *driverEntities.VehicleEntity {
+	for _, vehicle := range driver.Vehicles {
+		if vehicleUUID == "" || (vehicle.VehicleUUID != nil && *vehicle.VehicleUUID == vehicleUUID) {
+			return vehicle
+		}
+	}
+	return nil
+}
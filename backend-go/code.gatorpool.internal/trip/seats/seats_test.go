@@ -0,0 +1,48 @@
+package seats
+
+import (
+	"testing"
+
+	driverEntities "code.gatorpool.internal/driver/entities"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapacity(t *testing.T) {
+	tests := []struct {
+		Name     string
+		Vehicle  *driverEntities.VehicleEntity
+		Carpool  bool
+		Expected int
+	}{
+		{"Carpool in a five seater", &driverEntities.VehicleEntity{Seats: ptr.Int(5)}, true, 4},
+		{"Non carpool takes one rider", &driverEntities.VehicleEntity{Seats: ptr.Int(5)}, false, 1},
+		{"Carpool without a vehicle", nil, true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.Name, func(t *testing.T) {
+			assert.Equal(t, tt.Expected, Capacity(tt.Vehicle, tt.Carpool))
+		})
+	}
+}
+
+func TestSnapshotAndRemaining(t *testing.T) {
+	trip := &tripEntities.TripEntity{
+		Carpool: ptr.Bool(true),
+		Riders: []*tripEntities.TripRiderEntity{
+			{UserUUID: ptr.String("a"), Accepted: ptr.Bool(true)},
+			{UserUUID: ptr.String("b"), Accepted: ptr.Bool(false)},
+		},
+	}
+
+	Snapshot(trip, &driverEntities.VehicleEntity{Seats: ptr.Int(3)})
+	assert.Equal(t, 2, *trip.Seats)
+	assert.Equal(t, 1, *trip.SeatsRemaining)
+
+	trip.Riders[1].Accepted = ptr.Bool(true)
+	Recount(trip)
+	assert.Equal(t, 0, *trip.SeatsRemaining)
+	assert.Equal(t, 0, Remaining(trip))
+}
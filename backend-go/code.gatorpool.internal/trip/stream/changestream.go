@@ -0,0 +1,59 @@
+package stream
+
+import (
+	"context"
+	"time"
+
+	datastores "code.gatorpool.internal/datastores/mongo"
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Watch publishes a trip.updated event to the broker for every trip written by any server,
+// so subscribers hear about changes made on other instances. It needs a replica set, and
+// returns when the context is done or the change stream fails.
+//
+// Only the trip as it is after the change is seen, so someone taken off a trip hears about
+// it from the event the handler published, on the server that made the change.
+func Watch(ctx context.Context, broker *Broker) error {
+
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.M{
+			"operationType": bson.M{"$in": []string{"insert", "update", "replace"}},
+		}}},
+	}
+
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+
+	changes, err := datastores.GetMongoDatabase(ctx).Collection(datastores.Trips).Watch(ctx, pipeline, opts)
+	if err != nil {
+		return err
+	}
+	defer changes.Close(ctx)
+
+	for changes.Next(ctx) {
+		var change struct {
+			FullDocument *tripEntities.TripEntity `bson:"fullDocument"`
+		}
+		if err := changes.Decode(&change); err != nil {
+			return err
+		}
+
+		trip := change.FullDocument
+		if trip == nil || trip.TripUUID == nil {
+			continue
+		}
+
+		broker.Publish(Event{
+			Type:         EventTripUpdated,
+			TripUUID:     *trip.TripUUID,
+			Trip:         trip,
+			At:           time.Now(),
+			Participants: Participants(trip),
+		})
+	}
+
+	return changes.Err()
+}
@@ -0,0 +1,162 @@
+package stream
+
+import (
+	"sync"
+	"time"
+
+	tripEntities "code.gatorpool.internal/trip/entities"
+)
+
+const (
+	EventRiderRequested  = "trip.rider_requested"
+	EventRiderAccepted   = "trip.rider_accepted"
+	EventRiderRejected   = "trip.rider_rejected"
+	EventRiderRemoved    = "trip.rider_removed"
+	EventRiderLeft       = "trip.rider_left"
+	EventDriverRequested = "trip.driver_requested"
+	EventDriverAccepted  = "trip.driver_accepted"
+	EventDriverRejected  = "trip.driver_rejected"
+	EventDriverRemoved   = "trip.driver_removed"
+	EventTripEdited      = "trip.edited"
+	EventTripCancelled   = "trip.cancelled"
+	EventTripStarted     = "trip.started"
+	EventTripCompleted   = "trip.completed"
+	EventTripExpired     = "trip.expired"
+	EventMessageSent     = "trip.message_sent"
+	EventMessagesRead    = "trip.messages_read"
+
+	// Sent by the change stream for any write to a trip, from any server
+	EventTripUpdated = "trip.updated"
+
+	// How many events a subscriber can fall behind before new ones are dropped
+	Buffer = 32
+)
+
+// Event is a change to a trip, pushed to everyone on it
+type Event struct {
+	Type     string                   `json:"type"`
+	TripUUID string                   `json:"trip_uuid"`
+	Trip     *tripEntities.TripEntity `json:"trip,omitempty"`
+	At       time.Time                `json:"at"`
+
+	// Anything else the event carries, e.g. a chat message
+	Data interface{} `json:"data,omitempty"`
+
+	// Who the event goes to, not sent to them
+	Participants []string `json:"-"`
+}
+
+// Broker fans events out to the subscribers of every participant, in this server only
+type Broker struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[chan Event]bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: map[string]map[chan Event]bool{}}
+}
+
+// Subscribe returns the events for trips the user is on. The returned function stops the
+// subscription and has to be called when the user disconnects.
+func (b *Broker) Subscribe(userUUID string) (<-chan Event, func()) {
+	events := make(chan Event, Buffer)
+
+	b.mu.Lock()
+	if b.subscribers[userUUID] == nil {
+		b.subscribers[userUUID] = map[chan Event]bool{}
+	}
+	b.subscribers[userUUID][events] = true
+	b.mu.Unlock()
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers[userUUID], events)
+			if len(b.subscribers[userUUID]) == 0 {
+				delete(b.subscribers, userUUID)
+			}
+			b.mu.Unlock()
+		})
+	}
+}
+
+// Connected reports whether the user has a stream open to this server
+func (b *Broker) Connected(userUUID string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subscribers[userUUID]) > 0
+}
+
+// Publish sends the event to every subscriber of its participants. A subscriber that isn't
+// keeping up misses the event rather than holding up everyone else.
+func (b *Broker) Publish(event Event) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	seen := map[string]bool{}
+	for _, userUUID := range event.Participants {
+		if seen[userUUID] {
+			continue
+		}
+		seen[userUUID] = true
+
+		for events := range b.subscribers[userUUID] {
+			select {
+			case events <- event:
+			default:
+			}
+		}
+	}
+}
+
+// Default is the broker the trip handlers publish to
+var Default = NewBroker()
+
+// Publish sends an event about the trip to everyone on it, and to anyone in also, e.g. a rider
+// who was just removed
+func Publish(eventType string, trip *tripEntities.TripEntity, also ...string) {
+	event := Event{
+		Type:         eventType,
+		Trip:         trip,
+		Participants: append(Participants(trip), also...),
+	}
+	if trip.TripUUID != nil {
+		event.TripUUID = *trip.TripUUID
+	}
+
+	Default.Publish(event)
+}
+
+// Participants is everyone on the trip, the driver, riders and anyone who has asked to join or
+// offered to drive
+func Participants(trip *tripEntities.TripEntity) []string {
+	participants := []string{}
+
+	add := func(userUUID *string) {
+		if userUUID != nil && *userUUID != "" {
+			participants = append(participants, *userUUID)
+		}
+	}
+
+	add(trip.PostedBy)
+	if trip.AssignedDriver != nil {
+		add(trip.AssignedDriver.UserUUID)
+	}
+	for _, rider := range trip.Riders {
+		if rider != nil {
+			add(rider.UserUUID)
+		}
+	}
+	for _, request := range trip.DriverRequests {
+		if request != nil {
+			add(request.UserUUID)
+		}
+	}
+
+	return participants
+}
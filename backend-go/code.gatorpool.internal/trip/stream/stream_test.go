@@ -0,0 +1,52 @@
+package stream
+
+import (
+	"testing"
+
+	tripEntities "code.gatorpool.internal/trip/entities"
+	"code.gatorpool.internal/util/ptr"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublish(t *testing.T) {
+	broker := NewBroker()
+
+	driver, stopDriver := broker.Subscribe("driver")
+	defer stopDriver()
+	rider, stopRider := broker.Subscribe("rider")
+	stranger, stopStranger := broker.Subscribe("stranger")
+	defer stopStranger()
+
+	trip := &tripEntities.TripEntity{
+		TripUUID:       ptr.String("trip"),
+		PostedBy:       ptr.String("driver"),
+		AssignedDriver: &tripEntities.TripAssignedDriverEntity{UserUUID: ptr.String("driver")},
+		Riders:         []*tripEntities.TripRiderEntity{{UserUUID: ptr.String("rider")}},
+	}
+
+	broker.Publish(Event{Type: EventRiderAccepted, TripUUID: "trip", Participants: Participants(trip)})
+
+	// The driver is listed twice but only hears it once
+	assert.Len(t, driver, 1)
+	assert.Len(t, rider, 1)
+	assert.Len(t, stranger, 0)
+
+	event := <-rider
+	assert.Equal(t, EventRiderAccepted, event.Type)
+	assert.False(t, event.At.IsZero())
+
+	assert.True(t, broker.Connected("rider"))
+
+	// Nothing is sent after unsubscribing
+	stopRider()
+	stopRider()
+	assert.False(t, broker.Connected("rider"))
+	broker.Publish(Event{Type: EventTripEdited, Participants: Participants(trip)})
+	assert.Len(t, rider, 0)
+
+	// A subscriber that falls behind misses events instead of blocking
+	for i := 0; i < Buffer+5; i++ {
+		broker.Publish(Event{Type: EventTripEdited, Participants: []string{"driver"}})
+	}
+	assert.Len(t, driver, Buffer)
+}